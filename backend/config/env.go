@@ -31,6 +31,39 @@ type AppEnv struct {
 	// Optimization Topics (Redis Streams)
 	OptiReqTopic string `env:"OPTI_REQ_TOPIC"`
 	OptiResTopic string `env:"OPTI_RES_TOPIC"`
+	// Consumer group shared by all backend replicas reading OptiResTopic
+	OptiResGroup string `env:"OPTI_RES_GROUP" envDefault:"backend"`
+	// Name of this replica in the consumer group, defaults to hostname-pid
+	OptiResConsumer string `env:"OPTI_RES_CONSUMER" envDefault:""`
+	// Stream receiving responses that can't be handled
+	OptiDeadLetterTopic string `env:"OPTI_DEAD_LETTER_TOPIC" envDefault:"optimization_res_dead"`
+	// Pub/sub channel forwarding handled responses to the replica holding the websocket
+	OptiDoneChannel string `env:"OPTI_DONE_CHANNEL" envDefault:"optimization_done"`
+	// Stream of intermediate results published while a job runs
+	OptiProgressTopic string `env:"OPTI_PROGRESS_TOPIC" envDefault:"optimization_progress"`
+	// Prefix of the keys flagging cancelled jobs for the optimizer
+	OptiCancelPrefix string `env:"OPTI_CANCEL_PREFIX" envDefault:"optimization_cancel:"`
+	// Key kept alive by the Python optimizer while it runs
+	OptiHeartbeatKey string `env:"OPTI_HEARTBEAT_KEY" envDefault:"optimizer_heartbeat"`
+	// Transport of optimization jobs, "redis" for the Python optimizer or "memory" to run the quick layout in the backend
+	OptiQueue string `env:"OPTI_QUEUE" envDefault:"redis"`
+	// Number of jobs run at the same time in the backend
+	OptiWorkers int `env:"OPTI_WORKERS" envDefault:"1"`
+	// Optimizations running at the same time, more are queued
+	OptiMaxRunning           int `env:"OPTI_MAX_RUNNING" envDefault:"4"`
+	OptiMaxRunningPerUser    int `env:"OPTI_MAX_RUNNING_PER_USER" envDefault:"1"`
+	OptiMaxRunningPerProject int `env:"OPTI_MAX_RUNNING_PER_PROJECT" envDefault:"2"`
+	// Queued optimizations, more are rejected
+	OptiMaxQueued           int `env:"OPTI_MAX_QUEUED" envDefault:"100"`
+	OptiMaxQueuedPerUser    int `env:"OPTI_MAX_QUEUED_PER_USER" envDefault:"3"`
+	OptiMaxQueuedPerProject int `env:"OPTI_MAX_QUEUED_PER_PROJECT" envDefault:"10"`
+	// Key of the lease held by the only backend instance running optimizations, when Redis is used
+	OptiSchedulerKey string `env:"OPTI_SCHEDULER_KEY" envDefault:"optimization_scheduler"`
+
+	// Transport of presence between backend instances, "redis" or "memory" for a single instance
+	PresenceBroker string `env:"PRESENCE_BROKER" envDefault:"redis"`
+	// Pub/sub channel relaying presence between backend instances
+	PresenceChannel string `env:"PRESENCE_CHANNEL" envDefault:"workspace_presence"`
 }
 
 func transformAppEnv(logger *zap.Logger, cfg *AppEnv, isTest bool) {
@@ -0,0 +1,84 @@
+package controller_camera
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+// Interval at which open connections check that their user can still edit the model
+const accessCheckPeriod = 30 * time.Second
+
+var (
+	errModelNotInProject = errors.New("model does not belong to the project")
+	errNotMember         = errors.New("user is not a member of the project")
+)
+
+func isAccessError(err error) bool {
+	return errors.Is(err, errModelNotInProject) || errors.Is(err, errNotMember)
+}
+
+// Checks that the model belongs to the project and that the user is a member of it, every role
+// edits its own workspace
+func (t *UpdateEventRoute) checkAccess(ctx context.Context, projectId uuid.UUID, modelId uuid.UUID, userId uuid.UUID) (db_sqlc_gen.GetUserOfProjectRow, error) {
+	model, err := t.DB.Queries.GetModelByID(ctx, db_sqlc_gen.GetModelByIDParams{
+		ID: modelId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db_sqlc_gen.GetUserOfProjectRow{}, errModelNotInProject
+	}
+	if err != nil {
+		return db_sqlc_gen.GetUserOfProjectRow{}, err
+	}
+	if model.ProjectID != projectId {
+		return db_sqlc_gen.GetUserOfProjectRow{}, errModelNotInProject
+	}
+
+	member, err := t.DB.Queries.GetUserOfProject(ctx, db_sqlc_gen.GetUserOfProjectParams{
+		UserID:    pgtype.UUID{Bytes: userId, Valid: true},
+		Projectid: projectId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db_sqlc_gen.GetUserOfProjectRow{}, errNotMember
+	}
+	if err != nil {
+		return db_sqlc_gen.GetUserOfProjectRow{}, err
+	}
+	return member, nil
+}
+
+// Closes the connection with a policy violation once the user loses access to the model,
+// a removed member must not keep editing through an open connection
+func (t *UpdateEventRoute) watchAccess(ctx context.Context, conn *wsConn, projectId uuid.UUID, modelId uuid.UUID, userId uuid.UUID) {
+	ticker := time.NewTicker(accessCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := t.checkAccess(ctx, projectId, modelId, userId)
+			if isAccessError(err) {
+				t.Logger.Info("closing websocket: access revoked",
+					zap.String("user_id", userId.String()),
+					zap.String("model_id", modelId.String()),
+					zap.Error(err),
+				)
+				conn.closeWith(websocket.ClosePolicyViolation, err.Error())
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				// The connection is kept, access is checked again on the next tick
+				t.Logger.Warn("error while checking websocket access", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package controller_camera
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/internal/history"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+	messsages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
+)
+
+var (
+	errInvalidEvent = errors.New("invalid autosave event")
+	errInvalidId    = fmt.Errorf("%w: invalid id", errInvalidEvent)
+	// The workspace was saved by another connection since the version this one knows
+	errVersionConflict = errors.New("workspace version changed")
+)
+
+// Failure of an event of a batch, none of the events of the batch are applied
+type autosaveError struct {
+	index int
+	err   error
+}
+
+func (e *autosaveError) Error() string {
+	return fmt.Sprintf("event %d: %v", e.index, e.err)
+}
+
+func (e *autosaveError) Unwrap() error {
+	return e.err
+}
+
+// Converts an event to the change it makes, the value it replaces is filled in when applied
+func autosaveHistoryEvent(event *protobufs.AutosaveEvent) (history.Event, error) {
+	switch ce := event.GetEvent().(type) {
+	case *protobufs.AutosaveEvent_Delete:
+		if _, err := uuid.Parse(ce.Delete.GetId()); err != nil {
+			return history.Event{}, fmt.Errorf("%w: camera %q", errInvalidId, ce.Delete.GetId())
+		}
+		return history.Event{Kind: history.KindCamera, TargetId: ce.Delete.GetId()}, nil
+	case *protobufs.AutosaveEvent_Upsert:
+		if ce.Upsert.GetCamera().GetId() == "" {
+			return history.Event{}, fmt.Errorf("%w: camera without id", errInvalidId)
+		}
+		marshalled, err := json.Marshal(messages_cameras.ProtoCamToCam(ce.Upsert.GetCamera()))
+		if err != nil {
+			return history.Event{}, err
+		}
+		return history.Event{Kind: history.KindCamera, TargetId: ce.Upsert.GetCamera().GetId(), After: marshalled}, nil
+	case *protobufs.AutosaveEvent_Calibrate:
+		marshalled, err := json.Marshal(history.Calibration{
+			ScaleFactor: ce.Calibrate.GetScaleFactor(),
+			ModelHeight: ce.Calibrate.GetModelHeight(),
+		})
+		if err != nil {
+			return history.Event{}, err
+		}
+		return history.Event{Kind: history.KindCalibration, After: marshalled}, nil
+	case *protobufs.AutosaveEvent_FaceDelete:
+		if ce.FaceDelete.GetId() == "" {
+			return history.Event{}, fmt.Errorf("%w: face without id", errInvalidId)
+		}
+		return history.Event{Kind: history.KindFace, TargetId: ce.FaceDelete.GetId()}, nil
+	case *protobufs.AutosaveEvent_FaceUpsert:
+		face := ce.FaceUpsert.GetCoverageFace()
+		if face.GetId() == "" {
+			return history.Event{}, fmt.Errorf("%w: face without id", errInvalidId)
+		}
+		marshalled, err := json.Marshal(messsages_trapezoids.ProtoTrapezoidToTrapezoid(face))
+		if err != nil {
+			return history.Event{}, err
+		}
+		return history.Event{Kind: history.KindFace, TargetId: face.GetId(), After: marshalled}, nil
+	default:
+		return history.Event{}, fmt.Errorf("%w: unknown event %T", errInvalidEvent, ce)
+	}
+}
+
+// Bumps the workspace version from version to version+1 in the transaction, so that the row
+// stays locked until the commit
+func bumpWorkspaceVersion(ctx context.Context, queries *db_sqlc_gen.Queries, workspaceId uuid.UUID, version int32) (int32, error) {
+	newVersion, err := queries.BumpWorkspaceVersion(ctx, db_sqlc_gen.BumpWorkspaceVersionParams{
+		WorkspaceID: workspaceId,
+		Version:     version,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errVersionConflict
+	}
+	return newVersion, err
+}
+
+// Applies all the events in one transaction and records them as one edit of the history, the
+// workspace version goes from version to version+1
+func (t *UpdateEventRoute) applyAutosaveBatch(ctx context.Context, workspaceId uuid.UUID, version int32, events []*protobufs.AutosaveEvent) (int32, history.Stacks, error) {
+	tx, err := t.DB.Pool.Begin(ctx)
+	if err != nil {
+		return 0, history.Stacks{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := t.DB.Queries.WithTx(tx)
+	newVersion, err := bumpWorkspaceVersion(ctx, queries, workspaceId, version)
+	if err != nil {
+		return 0, history.Stacks{}, err
+	}
+
+	log, err := history.Open(ctx, queries, workspaceId)
+	if err != nil {
+		return 0, history.Stacks{}, err
+	}
+	applied := make([]history.Event, 0, len(events))
+	for i, event := range events {
+		change, err := autosaveHistoryEvent(event)
+		if err != nil {
+			return 0, history.Stacks{}, &autosaveError{index: i, err: err}
+		}
+		recorded, err := log.Apply(ctx, change)
+		if err != nil {
+			return 0, history.Stacks{}, &autosaveError{index: i, err: err}
+		}
+		applied = append(applied, recorded)
+	}
+
+	stacks, err := log.RecordEdit(ctx, newVersion, applied)
+	if err != nil {
+		return 0, history.Stacks{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, history.Stacks{}, err
+	}
+	return newVersion, stacks, nil
+}
+
+// Reads the version after another connection saved the workspace, keeps the known one on error
+func (t *UpdateEventRoute) reloadVersion(ctx context.Context, workspaceId uuid.UUID, currentVersion *int32) {
+	workspace, err := t.DB.Queries.GetWorkspaceLayout(ctx, workspaceId)
+	if err != nil {
+		t.Logger.Error("error while reading workspace version", zap.Error(err))
+		return
+	}
+	*currentVersion = workspace.Version
+}
+
+func (t *UpdateEventRoute) sendAutosaveEventResponse(sess *session, resp *protobufs.AutosaveEventResponse) {
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Autosave{
+			Autosave: resp,
+		},
+	})
+}
+
+func autosaveErrorCode(err error) protobufs.WorkspaceErrorCode {
+	switch {
+	case errors.Is(err, errVersionConflict):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_STALE_VERSION
+	case errors.Is(err, errInvalidId):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_ID
+	case errors.Is(err, errInvalidEvent):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_EVENT
+	default:
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL
+	}
+}
+
+// Answers every request with a single ACK, or an error when none of its events were applied
+func (t *UpdateEventRoute) handleAutosaveEvent(
+	ctx context.Context, sess *session,
+	workspaceId uuid.UUID, currentVersion *int32, casted *protobufs.AutosaveEventRequest) {
+	if casted.GetVersion() <= uint32(*currentVersion) {
+		t.sendWorkspaceError(sess, &protobufs.WorkspaceEventError{
+			Code:               protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_STALE_VERSION,
+			Request:            protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_AUTOSAVE,
+			Message:            "version is not newer than the workspace version",
+			RequestVersion:     casted.GetVersion(),
+			LastUpdatedVersion: *currentVersion,
+		})
+		return
+	}
+
+	if len(casted.GetEvents()) == 0 {
+		t.sendAutosaveEventResponse(sess, &protobufs.AutosaveEventResponse{
+			LastUpdatedVersion: *currentVersion,
+			RequestVersion:     casted.GetVersion(),
+		})
+		return
+	}
+
+	newVersion, stacks, err := t.applyAutosaveBatch(ctx, workspaceId, *currentVersion, casted.GetEvents())
+	if err != nil {
+		code := autosaveErrorCode(err)
+		msg := err.Error()
+		switch code {
+		case protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_STALE_VERSION:
+			t.reloadVersion(ctx, workspaceId, currentVersion)
+		case protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL:
+			t.Logger.Error("error while applying autosave batch", zap.Error(err))
+			msg = "internal error"
+		default:
+			t.Logger.Warn("autosave batch rejected", zap.Error(err))
+		}
+
+		wsErr := &protobufs.WorkspaceEventError{
+			Code:               code,
+			Request:            protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_AUTOSAVE,
+			Message:            msg,
+			RequestVersion:     casted.GetVersion(),
+			LastUpdatedVersion: *currentVersion,
+		}
+		var eventErr *autosaveError
+		if errors.As(err, &eventErr) {
+			wsErr.EventIndex = proto.Uint32(uint32(eventErr.index))
+		}
+		t.sendWorkspaceError(sess, wsErr)
+		return
+	}
+
+	*currentVersion = newVersion
+	t.sendAutosaveEventResponse(sess, &protobufs.AutosaveEventResponse{
+		LastUpdatedVersion: newVersion,
+		RequestVersion:     casted.GetVersion(),
+		CanUndo:            stacks.CanUndo(),
+		CanRedo:            stacks.CanRedo(),
+	})
+}
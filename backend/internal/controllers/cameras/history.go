@@ -0,0 +1,119 @@
+package controller_camera
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Stacks of the workspace for a new connection, empty when they can't be read
+func (t *UpdateEventRoute) historyStacks(ctx context.Context, workspaceId uuid.UUID) history.Stacks {
+	stacks, err := history.LatestStacks(ctx, t.DB.Queries, workspaceId)
+	if err != nil {
+		t.Logger.Error("error while reading workspace history", zap.Error(err))
+	}
+	return stacks
+}
+
+// Undoes or redoes an edit in one transaction, the workspace version goes from version to
+// version+1. Returns the events for the client to apply the same changes.
+func (t *UpdateEventRoute) applyHistoryStep(ctx context.Context, workspaceId uuid.UUID, version int32, undo bool) (int32, []*protobufs.AutosaveEvent, history.Stacks, error) {
+	tx, err := t.DB.Pool.Begin(ctx)
+	if err != nil {
+		return 0, nil, history.Stacks{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := t.DB.Queries.WithTx(tx)
+	newVersion, err := bumpWorkspaceVersion(ctx, queries, workspaceId, version)
+	if err != nil {
+		return 0, nil, history.Stacks{}, err
+	}
+
+	log, err := history.Open(ctx, queries, workspaceId)
+	if err != nil {
+		return 0, nil, history.Stacks{}, err
+	}
+	var events []history.Event
+	var stacks history.Stacks
+	if undo {
+		events, stacks, err = log.Undo(ctx, newVersion)
+	} else {
+		events, stacks, err = log.Redo(ctx, newVersion)
+	}
+	if err != nil {
+		return 0, nil, history.Stacks{}, err
+	}
+
+	converted := make([]*protobufs.AutosaveEvent, len(events))
+	for i, e := range events {
+		if converted[i], err = history.AutosaveEvent(e); err != nil {
+			return 0, nil, history.Stacks{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, history.Stacks{}, err
+	}
+	return newVersion, converted, stacks, nil
+}
+
+func historyErrorCode(err error) protobufs.WorkspaceErrorCode {
+	switch {
+	case errors.Is(err, errVersionConflict):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_STALE_VERSION
+	case errors.Is(err, history.ErrNothingToUndo), errors.Is(err, history.ErrNothingToRedo):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_EMPTY_HISTORY
+	case errors.Is(err, history.ErrConflict):
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_HISTORY_CONFLICT
+	default:
+		return protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL
+	}
+}
+
+// Answers with the events to apply on the client, or an error when nothing was changed
+func (t *UpdateEventRoute) handleHistoryEvent(
+	ctx context.Context, sess *session,
+	workspaceId uuid.UUID, currentVersion *int32, undo bool) {
+	request := protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_REDO
+	if undo {
+		request = protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_UNDO
+	}
+
+	newVersion, events, stacks, err := t.applyHistoryStep(ctx, workspaceId, *currentVersion, undo)
+	if err != nil {
+		code := historyErrorCode(err)
+		msg := err.Error()
+		switch code {
+		case protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_STALE_VERSION:
+			t.reloadVersion(ctx, workspaceId, currentVersion)
+		case protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL:
+			t.Logger.Error("error while applying history step", zap.Error(err))
+			msg = "internal error"
+		}
+
+		t.sendWorkspaceError(sess, &protobufs.WorkspaceEventError{
+			Code:               code,
+			Request:            request,
+			Message:            msg,
+			LastUpdatedVersion: *currentVersion,
+		})
+		return
+	}
+	*currentVersion = newVersion
+
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_History{
+			History: &protobufs.HistoryResponse{
+				LastUpdatedVersion: newVersion,
+				Events:             events,
+				CanUndo:            stacks.CanUndo(),
+				CanRedo:            stacks.CanRedo(),
+			},
+		},
+	})
+}
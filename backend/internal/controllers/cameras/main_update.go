@@ -0,0 +1,62 @@
+package controller_camera
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"omnicam.com/backend/internal/revision"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Tells a connecting client that main moved since its workspace was created, later saves to
+// main are announced through the presence hub
+func (t *UpdateEventRoute) sendMainUpdateSinceBase(ctx context.Context, sess *session, modelId uuid.UUID, userId uuid.UUID, workspaceId uuid.UUID, baseVersion int32) error {
+	model, err := t.DB.Queries.GetModelByID(ctx, db_sqlc_gen.GetModelByIDParams{
+		Fields: []string{"cameras"},
+		ID:     modelId,
+	})
+	if err != nil {
+		return err
+	}
+	if model.Version <= baseVersion {
+		return nil
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(ctx, db_sqlc_gen.GetWorkspaceByIDParams{
+		Fields:      []string{"base_cameras"},
+		UserID:      userId,
+		ModelID:     modelId,
+		WorkspaceID: pgtype.UUID{Bytes: workspaceId, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	baseCameras, err := messages_cameras.UnmarshalCameras(workspace.BaseCameras)
+	if err != nil {
+		return err
+	}
+	mainCameras, err := messages_cameras.UnmarshalCameras(model.Cameras)
+	if err != nil {
+		return err
+	}
+
+	change, err := revision.CamerasChange(baseCameras, mainCameras)
+	if err != nil {
+		return err
+	}
+
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_MainUpdated{
+			MainUpdated: &protobufs.MainUpdatedResponse{
+				MainVersion:     model.Version,
+				PreviousVersion: baseVersion,
+				Cameras:         change,
+			},
+		},
+	})
+	return nil
+}
@@ -0,0 +1,50 @@
+package controller_camera
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/internal/presence"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Adds the connection to the members of the model. Their presence and the notifications about
+// the model are sent straight to the connection, and aren't replayed on resume since the
+// client reloads them on connect. The connection is closed when its workspace is.
+func (t *UpdateEventRoute) joinPresence(ctx context.Context, conn *wsConn, modelId uuid.UUID, workspaceId uuid.UUID, user db_sqlc_gen.GetUserOfProjectRow) *presence.Client {
+	return t.Presence.Join(ctx, modelId, workspaceId, &protobufs.PresenceUser{
+		UserId:    user.ID.String(),
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}, func(resp *protobufs.WorkspaceEventResponse) {
+		bytes, err := proto.Marshal(resp)
+		if err != nil {
+			t.Logger.Error("error marshalling presence", zap.Error(err))
+			return
+		}
+		if resp.GetMainUpdated() != nil || resp.GetHistory() != nil {
+			conn.trySend(bytes)
+			return
+		}
+		conn.sendVolatile(bytes)
+	}, func(reason string) {
+		conn.closeWith(websocket.CloseNormalClosure, reason)
+	})
+}
+
+func (t *UpdateEventRoute) handlePresenceEvent(ctx context.Context, sess *session, client *presence.Client, casted *protobufs.PresenceRequest) {
+	if casted.GetState() == nil {
+		t.sendWorkspaceError(sess, &protobufs.WorkspaceEventError{
+			Code:    protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_PARAMS,
+			Request: protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_PRESENCE,
+			Message: "missing presence state",
+		})
+		return
+	}
+	client.Update(ctx, casted.GetState())
+}
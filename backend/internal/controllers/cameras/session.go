@@ -0,0 +1,223 @@
+package controller_camera
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	writeWait = 10 * time.Second
+	// The client must answer pings within this time, or the connection is considered dead
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	// Responses waiting to be written before the client is considered too slow
+	sendBufferSize = 256
+	// Time during which a lost connection can be resumed
+	resumeWindow = 2 * time.Minute
+	// Responses kept for a detached session, the oldest ones are dropped
+	maxMissed = 256
+)
+
+// Websocket connection written by a single goroutine, gorilla doesn't support concurrent writers
+type wsConn struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	// Close frame written once done is closed
+	closeMessage []byte
+}
+
+func newWsConn(conn *websocket.Conn) *wsConn {
+	return &wsConn{
+		conn: conn,
+		send: make(chan []byte, sendBufferSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Writes the queued responses and pings the client until the connection is closed
+func (c *wsConn) writeLoop(logger *zap.Logger) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.conn.Close()
+	defer c.close()
+
+	for {
+		select {
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage)
+			return
+		case bytes := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.BinaryMessage, bytes); err != nil {
+				logger.Warn("error writing message", zap.Error(err))
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				logger.Warn("error writing ping", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// Queues a response, returns false if the connection is closed. A client too slow to keep
+// up is disconnected, it can resume once it reconnects.
+func (c *wsConn) trySend(bytes []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- bytes:
+		return true
+	default:
+		c.close()
+		return false
+	}
+}
+
+// Queues a message that is only useful live, it is dropped instead of disconnecting a slow client
+func (c *wsConn) sendVolatile(bytes []byte) {
+	// Keeps room for the messages that can't be lost
+	if len(c.send) >= sendBufferSize/2 {
+		return
+	}
+
+	select {
+	case <-c.done:
+	case c.send <- bytes:
+	default:
+	}
+}
+
+func (c *wsConn) close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// Closes the connection with the given close code, only the first call has an effect
+func (c *wsConn) closeWith(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeMessage = websocket.FormatCloseMessage(code, text)
+		close(c.done)
+		// Unblocks the read loop
+		c.conn.SetReadDeadline(time.Now())
+	})
+}
+
+// State of a client that outlives its connection, so that a reconnecting client gets the
+// responses sent while it was away, like the progress and result of its optimizations.
+// The registry lock is always taken before the session lock.
+type session struct {
+	id          string
+	userId      uuid.UUID
+	workspaceId uuid.UUID
+
+	mu     sync.Mutex
+	conn   *wsConn
+	missed [][]byte
+	expiry *time.Timer
+}
+
+func (s *session) send(bytes []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && s.conn.trySend(bytes) {
+		return
+	}
+	if len(s.missed) >= maxMissed {
+		s.missed = s.missed[1:]
+	}
+	s.missed = append(s.missed, bytes)
+}
+
+// Sessions of the connected clients and of the clients that may still resume
+type sessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func (r *sessionRegistry) create(userId uuid.UUID, workspaceId uuid.UUID, conn *wsConn) *session {
+	s := &session{
+		id:          uuid.NewString(),
+		userId:      userId,
+		workspaceId: workspaceId,
+		conn:        conn,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions == nil {
+		r.sessions = make(map[string]*session)
+	}
+	r.sessions[s.id] = s
+	return s
+}
+
+// Moves conn to the session of the same user and workspace, a connection still attached to it is
+// closed. The responses the client missed are queued on conn, before any new one.
+func (r *sessionRegistry) resume(id string, userId uuid.UUID, workspaceId uuid.UUID, conn *wsConn) (*session, int, bool) {
+	// Locked until the session is attached, so that it can't expire in between
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok || s.userId != userId || s.workspaceId != workspaceId {
+		return nil, 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	if s.conn != nil && s.conn != conn {
+		s.conn.close()
+	}
+	s.conn = conn
+	replayed := len(s.missed)
+	for _, bytes := range s.missed {
+		conn.trySend(bytes)
+	}
+	s.missed = nil
+	return s, replayed, true
+}
+
+// Detaches conn from the session, which is dropped if it isn't resumed in time
+func (r *sessionRegistry) release(s *session, conn *wsConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != conn {
+		// Already taken over by another connection
+		return
+	}
+
+	s.conn = nil
+	s.expiry = time.AfterFunc(resumeWindow, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.conn == nil {
+			delete(r.sessions, s.id)
+		}
+	})
+}
+
+// Drops a session that was never used, when its connection resumed another one
+func (r *sessionRegistry) remove(s *session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, s.id)
+}
@@ -2,327 +2,334 @@ package controller_camera
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/redis/go-redis/v9"
+	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/internal/presence"
 	"omnicam.com/backend/internal/utils"
 	db_client "omnicam.com/backend/pkg/db"
 	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
 	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
 	"omnicam.com/backend/pkg/messages/protobufs"
-	messsages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
 )
 
 type UpdateEventRoute struct {
-	Logger          *zap.Logger
-	Env             *config_env.AppEnv
-	DB              *db_client.DB
-	RedisClient     *redis.Client
-	Upgrader        websocket.Upgrader
-	OptimizeRespMap *sync.Map
+	Logger   *zap.Logger
+	Env      *config_env.AppEnv
+	DB       *db_client.DB
+	JobQueue optimizer.JobQueue
+	Presence *presence.Hub
+	Upgrader websocket.Upgrader
+
+	sessions sessionRegistry
 }
 
-// Camera handlers
-func (t *UpdateEventRoute) handleEventDelete(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID, deleteId string,
-) {
-	_, err := uuid.Parse(deleteId)
+func (t *UpdateEventRoute) send(sess *session, resp *protobufs.WorkspaceEventResponse) {
+	bytes, err := proto.Marshal(resp)
 	if err != nil {
+		t.Logger.Error("error marshalling response", zap.Error(err))
 		return
 	}
+	sess.send(bytes)
+}
 
-	newVersion, err := t.DB.Queries.UpdateWorkspaceCams(c, db_sqlc_gen.UpdateWorkspaceCamsParams{
-		Key:     []string{deleteId},
-		UserID:  userId,
-		ModelID: modelId,
+func (t *UpdateEventRoute) sendOptimizationEventResp(sess *session, optiResp *protobufs.OptimizationEventResp) {
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Optimize{
+			Optimize: optiResp,
+		},
 	})
-	if err != nil {
-		t.Logger.Error("error while updating workspace", zap.Error(err))
-		return
-	}
-
-	resp := &protobufs.AutosaveEventResponse{
-		LastUpdatedVersion: newVersion,
-	}
-	sendAutosaveEventResponse(t.Logger, conn, resp)
 }
 
-func (t *UpdateEventRoute) handleEventUpsert(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID, upsert *protobufs.Camera,
-) {
-	cam := messages_cameras.ProtoCamToCam(upsert)
-	marshalled, err := json.Marshal(cam)
-	if err != nil {
-		t.Logger.Error("error while marshaling camera", zap.Error(err))
-		return
-	}
-	newVersion, err := t.DB.Queries.UpdateWorkspaceCams(c, db_sqlc_gen.UpdateWorkspaceCamsParams{
-		Key:     []string{upsert.Id},
-		Value:   marshalled,
-		UserID:  userId,
-		ModelID: modelId,
+func (t *UpdateEventRoute) sendWorkspaceError(sess *session, wsErr *protobufs.WorkspaceEventError) {
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Error{
+			Error: wsErr,
+		},
 	})
-	if err != nil {
-		t.Logger.Error("error while updating workspace cameras", zap.Error(err))
-		return
+}
+
+func optimizeError(code protobufs.WorkspaceErrorCode, msg string) *protobufs.WorkspaceEventError {
+	return &protobufs.WorkspaceEventError{
+		Code:    code,
+		Request: protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_OPTIMIZE,
+		Message: msg,
 	}
+}
 
-	resp := &protobufs.AutosaveEventResponse{
-		LastUpdatedVersion: newVersion,
+func cancelOptimizeError(code protobufs.WorkspaceErrorCode, jobId string, msg string) *protobufs.WorkspaceEventError {
+	return &protobufs.WorkspaceEventError{
+		Code:    code,
+		Request: protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_CANCEL_OPTIMIZE,
+		Message: msg,
+		JobId:   jobId,
 	}
-	sendAutosaveEventResponse(t.Logger, conn, resp)
 }
 
-// Calibration handler
-func (t *UpdateEventRoute) handleCalibration(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID,
-	event *protobufs.AutosaveEvent_Calibrate,
-	inputVersion uint32,
-	currentVersion *int32,
-) {
-	if inputVersion <= uint32(*currentVersion) {
-		return // stale/duplicate
+// Reads the model mesh and the existing cameras the optimizer must keep in place from the workspace
+func (t *UpdateEventRoute) getWorkspaceInputs(ctx context.Context, modelId uuid.UUID, userId uuid.UUID, workspaceId uuid.UUID, fixedIds []string) (*protobufs.ModelMesh, []*protobufs.Camera, error) {
+	fields := []string{}
+	if len(fixedIds) > 0 {
+		fields = append(fields, "cameras")
 	}
 
-	row, err := t.DB.Queries.UpdateWorkspaceCalibration(c, db_sqlc_gen.UpdateWorkspaceCalibrationParams{
+	workspace, err := t.DB.Queries.GetWorkspaceByID(ctx, db_sqlc_gen.GetWorkspaceByIDParams{
+		Fields:      fields,
 		UserID:      userId,
 		ModelID:     modelId,
-		ScaleFactor: event.Calibrate.ScaleFactor,
-		ModelHeight: event.Calibrate.ModelHeight,
+		WorkspaceID: pgtype.UUID{Bytes: workspaceId, Valid: true},
 	})
 	if err != nil {
-		t.Logger.Error("error updating calibration", zap.Error(err))
-		return
-	}
-
-	*currentVersion = row.Version
-
-	resp := &protobufs.AutosaveEventResponse{
-		LastUpdatedVersion: row.Version,
+		return nil, nil, err
 	}
-	sendAutosaveEventResponse(t.Logger, conn, resp)
-}
 
-func (t *UpdateEventRoute) handleFaceUpsert(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID,
-	event *protobufs.AutosaveEvent_FaceUpsert,
-	inputVersion uint32,
-	currentVersion *int32) {
-	if inputVersion <= uint32(*currentVersion) {
-		return // stale/duplicate
+	mesh := optimizer.MeshFromModel(workspace.Model, workspace.ScaleFactor, workspace.ModelHeight)
+	if len(fixedIds) == 0 {
+		return mesh, nil, nil
 	}
 
-	trapezoid := messsages_trapezoids.ProtoTrapezoidToTrapezoid(event.FaceUpsert.CoverageFace)
-	marshalled, err := json.Marshal(trapezoid)
+	cameras, err := messages_cameras.UnmarshalCameras(workspace.Cameras)
 	if err != nil {
-		t.Logger.Error("error while marshaling camera", zap.Error(err))
-		return
+		return nil, nil, err
 	}
 
-	newVersion, err := t.DB.Queries.UpdateWorkspaceTargetTrapezoids(c, db_sqlc_gen.UpdateWorkspaceTargetTrapezoidsParams{
-		Key:     []string{event.FaceUpsert.CoverageFace.Id},
-		Value:   marshalled,
-		UserID:  userId,
-		ModelID: modelId,
-	})
+	fixedCameras, err := optimizer.FixedCamerasFromWorkspace(cameras, fixedIds)
 	if err != nil {
-		t.Logger.Error("error updating face", zap.Error(err))
-		return
+		return nil, nil, err
 	}
 
-	resp := &protobufs.AutosaveEventResponse{
-		LastUpdatedVersion: newVersion,
-	}
-	sendAutosaveEventResponse(t.Logger, conn, resp)
+	return mesh, fixedCameras, nil
+}
+
+func (t *UpdateEventRoute) sendOptimizationError(sess *session, jobId string, code protobufs.OptimizationErrorCode, msg string) {
+	t.sendOptimizationEventResp(sess, &protobufs.OptimizationEventResp{
+		JobId: jobId,
+		Payload: &protobufs.OptimizationEventResp_ErrorResp{
+			ErrorResp: &protobufs.ErrorOptimizationEventResp{
+				Error: msg,
+				Code:  code,
+			},
+		},
+	})
 }
 
-func (t *UpdateEventRoute) handleFaceDelete(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID,
-	event *protobufs.AutosaveEvent_FaceDelete,
-	inputVersion uint32,
-	currentVersion *int32) {
-	if inputVersion <= uint32(*currentVersion) {
-		return // stale/duplicate
+func (t *UpdateEventRoute) handleOptimizeEvent(projectId uuid.UUID, modelId uuid.UUID, userId uuid.UUID, workspaceId uuid.UUID, sess *session, casted *protobufs.OptimizationEventReq) {
+	ctx := context.Background()
+
+	if len(casted.GetCoverageFace()) == 0 {
+		t.Logger.Warn("optimization aborted: no coverage faces provided")
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_PARAMS, "no coverage faces provided"))
+		return
 	}
 
-	newVersion, err := t.DB.Queries.UpdateWorkspaceTargetTrapezoids(c, db_sqlc_gen.UpdateWorkspaceTargetTrapezoidsParams{
-		Key:     []string{event.FaceDelete.Id},
-		Value:   nil,
-		UserID:  userId,
-		ModelID: modelId,
-	})
-	fmt.Println(event.FaceDelete.Id)
+	params, err := optimizer.ParamsFromRequest(casted)
+	if err == nil {
+		err = optimizer.ValidateCameraConfigs(casted.GetCameraConfig(), len(casted.GetCoverageFace()))
+	}
 	if err != nil {
-		t.Logger.Error("error updating face", zap.Error(err))
+		t.Logger.Warn("optimization aborted", zap.Error(err))
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_PARAMS, err.Error()))
 		return
 	}
 
-	resp := &protobufs.AutosaveEventResponse{
-		LastUpdatedVersion: newVersion,
+	mesh, fixedCameras, err := t.getWorkspaceInputs(ctx, modelId, userId, workspaceId, casted.GetFixedCameraIds())
+	if errors.Is(err, optimizer.ErrInvalidParams) {
+		t.Logger.Warn("optimization aborted", zap.Error(err))
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_PARAMS, err.Error()))
+		return
 	}
-	sendAutosaveEventResponse(t.Logger, conn, resp)
-}
-
-func sendAutosaveEventResponse(logger *zap.Logger, conn *websocket.Conn, resp *protobufs.AutosaveEventResponse) {
-	bytes, err := proto.Marshal(&protobufs.WorkspaceEventResponse{
-		Resp: &protobufs.WorkspaceEventResponse_Autosave{
-			Autosave: resp,
-		},
-	})
 	if err != nil {
-		logger.Error("error marshalling response", zap.Error(err))
+		t.Logger.Error("error while getting workspace of optimization", zap.Error(err))
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL, "internal error"))
 		return
 	}
-	conn.WriteMessage(websocket.BinaryMessage, bytes)
-}
 
-func (t *UpdateEventRoute) handleAutosaveEvent(
-	c *gin.Context, conn *websocket.Conn,
-	modelId uuid.UUID, userId uuid.UUID, currentVersion *int32, casted *protobufs.AutosaveEventRequest) {
-	if casted.Version <= uint32(*currentVersion) {
-		return // stale
-	}
-	for _, camEvent := range casted.Events {
-		switch ce := camEvent.GetEvent().(type) {
-		case *protobufs.AutosaveEvent_Delete:
-			t.handleEventDelete(c, conn, modelId, userId, ce.Delete.Id)
-		case *protobufs.AutosaveEvent_Upsert:
-			t.handleEventUpsert(c, conn, modelId, userId, ce.Upsert.Camera)
-		case *protobufs.AutosaveEvent_Calibrate:
-			t.handleCalibration(c, conn, modelId, userId, ce, casted.Version, currentVersion)
-		case *protobufs.AutosaveEvent_FaceDelete:
-			fmt.Println("ggg")
-			t.handleFaceDelete(c, conn, modelId, userId, ce, casted.Version, currentVersion)
-		case *protobufs.AutosaveEvent_FaceUpsert:
-			t.handleFaceUpsert(c, conn, modelId, userId, ce, casted.Version, currentVersion)
-		}
+	camConfigs := make([]*protobufs.CameraConfig, 0, len(casted.GetCameraConfig()))
+	for _, c := range casted.GetCameraConfig() {
+		config := proto.Clone(c).(*protobufs.CameraConfig)
+		config.Amount = optimizer.CameraAmount(c)
+		camConfigs = append(camConfigs, config)
 	}
-}
 
-func (t *UpdateEventRoute) sendOptimizationEventResp(conn *websocket.Conn, optiResp *protobufs.OptimizationEventResp) {
-	// 3. Wrap it in the top-level WorkspaceEventResponse (the oneof)
-	eventResp := &protobufs.WorkspaceEventResponse{
-		Resp: &protobufs.WorkspaceEventResponse_Optimize{
-			Optimize: optiResp,
-		},
+	jobUuid := uuid.New()
+	jobId := jobUuid.String()
+
+	job := &protobufs.OptimizeJob{
+		JobId:         jobId,
+		UserId:        userId.String(),
+		ProjectId:     projectId.String(),
+		ModelId:       modelId.String(),
+		CoverageFaces: casted.GetCoverageFace(),
+		CameraConfigs: camConfigs,
+		Scale:         casted.GetScale(),
+		Params:        params,
+		FixedCameras:  fixedCameras,
+		Mesh:          mesh,
 	}
 
-	bytes, err := proto.Marshal(eventResp)
+	// Stored as JSON so that it can be served as is by the REST API
+	requestJSON, err := protojson.Marshal(job)
 	if err != nil {
-		t.Logger.Error("error marshalling response", zap.Error(err))
+		t.Logger.Error("failed to serialize optimize request", zap.Error(err))
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL, "internal error"))
 		return
 	}
-	conn.WriteMessage(websocket.BinaryMessage, bytes)
-}
-
-func (t *UpdateEventRoute) handleOptimizeEvent(projectId uuid.UUID, modelId uuid.UUID, conn *websocket.Conn, casted *protobufs.OptimizationEventReq) {
-	ctx := context.Background()
 
-	if len(casted.GetCoverageFace()) == 0 {
-		t.Logger.Warn("optimization aborted: no coverage faces provided")
+	_, err = t.DB.Queries.CreateOptimizationJob(ctx, db_sqlc_gen.CreateOptimizationJobParams{
+		ID:      jobUuid,
+		ModelID: modelId,
+		UserID:  userId,
+		Request: requestJSON,
+	})
+	if err != nil {
+		t.Logger.Error("failed to create optimization job", zap.Error(err), zap.String("job_id", jobId))
+		t.sendWorkspaceError(sess, optimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL, "internal error"))
 		return
 	}
-	faces := make([][][]float64, 0, len(casted.GetCoverageFace()))
 
-	for _, face := range casted.GetCoverageFace() {
-		var points [][]float64
-		for _, p := range face.GetPoints() {
-			// Each point is a slice of 3 floats to represent the Tuple
-			points = append(points, []float64{p.GetX(), p.GetY(), p.GetZ()})
+	waiter, err := t.JobQueue.Enqueue(ctx, job)
+	if err != nil {
+		msg := err.Error()
+		var quotaErr *optimizer.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			t.Logger.Warn("optimization job rejected", zap.Error(err), zap.String("job_id", jobId))
+		} else {
+			t.Logger.Error("failed to enqueue optimization job",
+				zap.Error(err),
+				zap.String("job_id", jobId),
+			)
+			msg = "failed to publish optimization request"
 		}
-		faces = append(faces, points)
-	}
+		t.sendOptimizationError(sess, jobId, optimizer.ErrorCode(err), msg)
 
-	camConfigs := make([]map[string]interface{}, 0, len(casted.GetCameraConfig()))
-	for _, c := range casted.GetCameraConfig() {
-		camConfigs = append(camConfigs, map[string]interface{}{
-			"name":   c.GetName(),
-			"vfov":   c.GetFov(),
-			"pixels": []float64{c.GetWidthRes(), c.GetHeightRes()}, // Maps to Tuple[float, float]
-			"amount": 1,                                            // 'amount' is required by your Pydantic model
+		_, err = t.DB.Queries.FinishOptimizationJob(ctx, db_sqlc_gen.FinishOptimizationJobParams{
+			ID:     jobUuid,
+			Status: db_sqlc_gen.OptimizationJobStatusFailed,
+			Error:  pgtype.Text{String: msg, Valid: true},
 		})
+		if err != nil {
+			t.Logger.Error("failed to mark optimization job as failed", zap.Error(err), zap.String("job_id", jobId))
+		}
+		return
 	}
 
-	jobId := uuid.New().String()
+	// The scheduler times the job out, so a result always comes
+	go func() {
+		defer t.JobQueue.Release(jobId)
+		for {
+			select {
+			case optiResp := <-waiter.Progress:
+				t.sendOptimizationEventResp(sess, optiResp)
+			case optiResp := <-waiter.Result:
+				t.sendOptimizationEventResp(sess, optiResp)
+				return
+			}
+		}
+	}()
+}
 
-	resChan := make(chan string, 1)
-	t.OptimizeRespMap.Store(jobId, resChan)
+// The cancelled response reaches the client through the goroutine waiting for the job
+func (t *UpdateEventRoute) handleCancelOptimizeEvent(ctx context.Context, sess *session, projectId uuid.UUID, modelId uuid.UUID, userId uuid.UUID, casted *protobufs.CancelOptimizationEventReq) {
+	jobId, err := uuid.Parse(casted.GetJobId())
+	if err != nil {
+		t.Logger.Warn("cancel aborted: invalid job id", zap.String("job_id", casted.GetJobId()))
+		t.sendWorkspaceError(sess, cancelOptimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INVALID_ID, casted.GetJobId(), "invalid job id"))
+		return
+	}
 
-	payload := map[string]interface{}{
-		"faces":       faces,
-		"cam_configs": camConfigs,
-		"scale":       casted.Scale,
-		"job_id":      jobId,
-		"project_id":  projectId.String(),
-		"model_id":    modelId.String(),
+	job, err := t.DB.Queries.GetOptimizationJob(ctx, db_sqlc_gen.GetOptimizationJobParams{
+		ID:        jobId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil || job.UserID != userId {
+		t.Logger.Warn("cancel aborted: job not found", zap.String("job_id", casted.GetJobId()), zap.Error(err))
+		t.sendWorkspaceError(sess, cancelOptimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_NOT_FOUND, casted.GetJobId(), "job not found"))
+		return
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		t.Logger.Error("failed to serialize optimize request",
-			zap.Error(err),
-			zap.Int("face_count", len(faces)),
-		)
+	err = optimizer.CancelJob(ctx, t.DB, t.JobQueue, jobId)
+	if errors.Is(err, optimizer.ErrJobFinished) {
+		t.sendWorkspaceError(sess, cancelOptimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_ALREADY_FINISHED, casted.GetJobId(), "optimization already finished"))
 		return
 	}
+	if err != nil {
+		t.Logger.Error("error while cancelling optimization job", zap.String("job_id", casted.GetJobId()), zap.Error(err))
+		t.sendWorkspaceError(sess, cancelOptimizeError(protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL, casted.GetJobId(), "internal error"))
+	}
+}
 
-	err = t.RedisClient.XAdd(ctx, &redis.XAddArgs{
-		Stream: t.Env.OptiReqTopic, // Use your actual env field name here
-		Values: map[string]interface{}{
-			"data": string(jsonData), // This matches what model_validate_json(data) expects
+func (t *UpdateEventRoute) sendSessionResponse(sess *session, version int32, resumed bool, replayed int, stacks history.Stacks) {
+	t.send(sess, &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Session{
+			Session: &protobufs.SessionResponse{
+				SessionId:          sess.id,
+				LastUpdatedVersion: version,
+				Resumed:            resumed,
+				Replayed:           uint32(replayed),
+				CanUndo:            stacks.CanUndo(),
+				CanRedo:            stacks.CanRedo(),
+			},
 		},
-	}).Err()
+	})
+}
 
-	if err != nil {
-		t.Logger.Error("failed to publish to redis stream",
-			zap.Error(err),
-			zap.String("job_id", jobId),
-		)
-		return
+// Moves the connection to the session of a lost connection, and replays what the client missed.
+// Returns the session the connection now belongs to.
+func (t *UpdateEventRoute) handleResumeEvent(
+	ctx context.Context, conn *wsConn, sess *session,
+	userId uuid.UUID, workspaceId uuid.UUID, currentVersion *int32, casted *protobufs.ResumeRequest) *session {
+	resumed, replayed, ok := t.sessions.resume(casted.GetSessionId(), userId, workspaceId, conn)
+	if !ok {
+		t.sendSessionResponse(sess, *currentVersion, false, 0, t.historyStacks(ctx, workspaceId))
+		return sess
+	}
+	if resumed != sess {
+		t.sessions.remove(sess)
 	}
 
-	go func() {
-		defer t.OptimizeRespMap.Delete(jobId)
-		select {
-		case rawJSON := <-resChan:
-			optiResp := &protobufs.OptimizationEventResp{}
-
-			err := protojson.Unmarshal([]byte(rawJSON), optiResp)
-			if err != nil {
-				t.Logger.Error("failed to unmarshal proto-json", zap.Error(err))
-				return
-			}
+	// The ACKs of the last batches may have been lost with the previous connection
+	workspace, err := t.DB.Queries.GetWorkspaceLayout(ctx, workspaceId)
+	if err != nil {
+		t.Logger.Error("error while reading workspace version", zap.Error(err))
+		t.sendWorkspaceError(resumed, &protobufs.WorkspaceEventError{
+			Code:               protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_INTERNAL,
+			Request:            protobufs.WorkspaceRequestType_WORKSPACE_REQUEST_TYPE_RESUME,
+			Message:            "internal error",
+			LastUpdatedVersion: *currentVersion,
+		})
+		return resumed
+	}
 
-			t.sendOptimizationEventResp(conn, optiResp)
-		case <-time.After(10 * time.Minute):
-			t.Logger.Warn("optimization timed out", zap.String("job_id", jobId))
-		}
-	}()
+	*currentVersion = workspace.Version
+	if workspace.Version > casted.GetLastAckedVersion() {
+		t.sendAutosaveEventResponse(resumed, &protobufs.AutosaveEventResponse{
+			LastUpdatedVersion: workspace.Version,
+		})
+	}
+	t.sendSessionResponse(resumed, workspace.Version, true, replayed, t.historyStacks(ctx, workspaceId))
+	return resumed
 }
 
-// Main WebSocket handler
+// Main WebSocket handler, edits the workspace of the branchId query or the default one
 func (t *UpdateEventRoute) get(c *gin.Context) {
 	strProjectId := c.Param("projectId")
 	projectId, err := utils.ParseUuidBase64(strProjectId)
 	if err != nil {
 		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
 		return
 	}
 
@@ -341,9 +348,28 @@ func (t *UpdateEventRoute) get(c *gin.Context) {
 		return
 	}
 
+	member, err := t.checkAccess(c, projectId, modelId, userId)
+	if errors.Is(err, errModelNotInProject) || errors.Is(err, errNotMember) {
+		t.Logger.Warn("websocket refused", zap.String("projectId", strProjectId), zap.String("modelId", strModelId), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while checking websocket access", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	branchId, err := utils.ParseNullUuidBase64(c.Query("branchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		return
+	}
+
 	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
-		UserID:  userId,
-		ModelID: modelId,
+		UserID:      userId,
+		ModelID:     modelId,
+		WorkspaceID: branchId,
 	})
 	if err != nil {
 		t.Logger.Error("workspace not found", zap.Error(err))
@@ -356,8 +382,30 @@ func (t *UpdateEventRoute) get(c *gin.Context) {
 		return
 	}
 
+	wsConn := newWsConn(conn)
+	go wsConn.writeLoop(t.Logger)
+
 	go func() {
-		defer conn.Close()
+		// The gin context is recycled once the handler returns
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		workspaceId := workspace.ID
+		sess := t.sessions.create(userId, workspaceId, wsConn)
+		var presenceClient *presence.Client
+		defer func() {
+			if presenceClient != nil {
+				presenceClient.Leave(ctx)
+			}
+			t.sessions.release(sess, wsConn)
+			wsConn.close()
+		}()
+		go t.watchAccess(ctx, wsConn, projectId, modelId, userId)
+
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
 
 		currentVersion := workspace.Version
 
@@ -365,12 +413,19 @@ func (t *UpdateEventRoute) get(c *gin.Context) {
 		initResp := &protobufs.AutosaveEventResponse{
 			LastUpdatedVersion: currentVersion,
 		}
-		sendAutosaveEventResponse(t.Logger, conn, initResp)
+		t.sendAutosaveEventResponse(sess, initResp)
+		t.sendSessionResponse(sess, currentVersion, false, 0, t.historyStacks(ctx, workspaceId))
+		presenceClient = t.joinPresence(ctx, wsConn, modelId, workspaceId, member)
+		if err := t.sendMainUpdateSinceBase(ctx, sess, modelId, userId, workspaceId, workspace.BaseVersion); err != nil {
+			t.Logger.Error("error while comparing workspace base with main", zap.Error(err))
+		}
 
 		for {
 			_, rawMsg, err := conn.ReadMessage()
 			if err != nil {
-				t.Logger.Error("error reading message", zap.Error(err))
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					t.Logger.Warn("websocket closed", zap.Error(err))
+				}
 				break
 			}
 
@@ -378,14 +433,35 @@ func (t *UpdateEventRoute) get(c *gin.Context) {
 			msg := &protobufs.WorkspaceEventRequest{}
 			if err := proto.Unmarshal(rawMsg, msg); err != nil {
 				t.Logger.Error("error unmarshalling event", zap.Error(err))
+				t.sendWorkspaceError(sess, &protobufs.WorkspaceEventError{
+					Code:               protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_MALFORMED_MESSAGE,
+					Message:            "message is not a WorkspaceEventRequest",
+					LastUpdatedVersion: currentVersion,
+				})
 				continue
 			}
 
 			switch casted := msg.Event.(type) {
 			case *protobufs.WorkspaceEventRequest_Autosave:
-				t.handleAutosaveEvent(c, conn, modelId, userId, &currentVersion, casted.Autosave)
+				t.handleAutosaveEvent(ctx, sess, workspaceId, &currentVersion, casted.Autosave)
 			case *protobufs.WorkspaceEventRequest_Optimize:
-				t.handleOptimizeEvent(projectId, modelId, conn, casted.Optimize)
+				t.handleOptimizeEvent(projectId, modelId, userId, workspaceId, sess, casted.Optimize)
+			case *protobufs.WorkspaceEventRequest_CancelOptimize:
+				t.handleCancelOptimizeEvent(ctx, sess, projectId, modelId, userId, casted.CancelOptimize)
+			case *protobufs.WorkspaceEventRequest_Resume:
+				sess = t.handleResumeEvent(ctx, wsConn, sess, userId, workspaceId, &currentVersion, casted.Resume)
+			case *protobufs.WorkspaceEventRequest_Presence:
+				t.handlePresenceEvent(ctx, sess, presenceClient, casted.Presence)
+			case *protobufs.WorkspaceEventRequest_Undo:
+				t.handleHistoryEvent(ctx, sess, workspaceId, &currentVersion, true)
+			case *protobufs.WorkspaceEventRequest_Redo:
+				t.handleHistoryEvent(ctx, sess, workspaceId, &currentVersion, false)
+			default:
+				t.sendWorkspaceError(sess, &protobufs.WorkspaceEventError{
+					Code:               protobufs.WorkspaceErrorCode_WORKSPACE_ERROR_CODE_UNKNOWN_MESSAGE,
+					Message:            fmt.Sprintf("unknown event %T", casted),
+					LastUpdatedVersion: currentVersion,
+				})
 			}
 		}
 	}()
@@ -113,6 +113,7 @@ func (t *GetModelRoute) getModelById(c *gin.Context) {
 			Cameras:        &cameras,
 			ScaleFactor:    data.ScaleFactor,
 			ModelHeight:    data.ModelHeight,
+			Protected:      data.Protected,
 		},
 		WorkspaceExists: workspaceExists,
 	}})
@@ -0,0 +1,261 @@
+package controller_model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/timeline"
+	"omnicam.com/backend/internal/utils"
+	db_client "omnicam.com/backend/pkg/db"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+	messages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
+)
+
+// Events sent per query while streaming the timeline
+const timelinePageSize = 500
+
+type ModelTimelineRoute struct {
+	Logger *zap.Logger
+	Env    *config_env.AppEnv
+	DB     *db_client.DB
+}
+
+// Parses the ids of the path and checks that the user is a member of the project of the model.
+// Answers the request and returns false otherwise.
+func (t *ModelTimelineRoute) authorize(c *gin.Context) (uuid.UUID, bool) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return uuid.UUID{}, false
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return uuid.UUID{}, false
+	}
+
+	username := c.GetString("username")
+	if _, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	}); err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, false
+	}
+
+	model, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
+		Fields: []string{},
+		ID:     modelId,
+	})
+	if err != nil || model.ProjectID != projectId {
+		t.Logger.Error("model not found", zap.String("modelId", strModelId), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, false
+	}
+	return modelId, true
+}
+
+// Rebuilds the model as it was at a time, at a version, or both. Without either it is the
+// current state of the model.
+func (t *ModelTimelineRoute) getModelTimelineState(c *gin.Context) {
+	modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	var at pgtype.Timestamptz
+	if strAt := c.Query("at"); strAt != "" {
+		parsed, err := time.Parse(time.RFC3339, strAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid time"})
+			return
+		}
+		at = pgtype.Timestamptz{Time: parsed, Valid: true}
+	}
+
+	var version pgtype.Int4
+	if strVersion := c.Query("version"); strVersion != "" {
+		parsed, err := strconv.Atoi(strVersion)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+		version = pgtype.Int4{Int32: int32(parsed), Valid: true}
+	}
+
+	events, err := t.DB.Queries.GetModelEventsUntil(c, db_sqlc_gen.GetModelEventsUntilParams{
+		ModelID: modelId,
+		At:      at,
+		Version: version,
+	})
+	if err != nil {
+		t.Logger.Error("error while getting model events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	state := timeline.NewState()
+	var stateVersion int32
+	for _, e := range events {
+		if err := state.Apply(timeline.Event{Kind: e.Kind, TargetId: e.TargetID, Value: e.Value}); err != nil {
+			t.Logger.Error("error while replaying model events", zap.Int64("eventId", e.ID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		stateVersion = max(stateVersion, e.Version)
+	}
+
+	camerasEncoded, err := json.Marshal(state.Cameras)
+	if err != nil {
+		t.Logger.Error("error while marshalling cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	cameras, err := messages_cameras.UnmarshalCameras(camerasEncoded)
+	if err != nil {
+		t.Logger.Error("cameras of the model events are invalid", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	facesEncoded, err := json.Marshal(state.Faces)
+	if err != nil {
+		t.Logger.Error("error while marshalling faces", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	var faces messages_trapezoids.Trapezoids
+	if err := json.Unmarshal(facesEncoded, &faces); err != nil {
+		t.Logger.Error("faces of the model events are invalid", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages_model_workspace.TimelineState{
+		Version:          stateVersion,
+		Cameras:          cameras,
+		TargetTrapezoids: faces,
+		ScaleFactor:      state.Calibration.ScaleFactor,
+		ModelHeight:      state.Calibration.ModelHeight,
+	}})
+}
+
+func timelineEventMessage(e db_sqlc_gen.GetModelEventsAfterRow) messages_model_workspace.TimelineEvent {
+	message := messages_model_workspace.TimelineEvent{
+		Id:        e.ID,
+		Version:   e.Version,
+		Kind:      e.Kind,
+		TargetId:  e.TargetID,
+		Value:     e.Value,
+		CreatedAt: e.CreatedAt.Time.Format(time.RFC3339),
+	}
+	if e.UserID.Valid {
+		userId := uuid.UUID(e.UserID.Bytes)
+		message.UserId = &userId
+	}
+	if e.Username.Valid {
+		message.Username = &e.Username.String
+	}
+	return message
+}
+
+// Writes one server-sent event and flushes it to the client
+func writeServerEvent(c *gin.Context, id string, event string, data []byte) error {
+	if id != "" {
+		if _, err := fmt.Fprintf(c.Writer, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// Streams the events of the model in order as server-sent events, for playback. Starts after
+// the event id given by the after query or the Last-Event-ID header, and ends with an "end" event.
+func (t *ModelTimelineRoute) getModelTimelineEvents(c *gin.Context) {
+	modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	strAfter := c.Query("after")
+	if strAfter == "" {
+		strAfter = c.GetHeader("Last-Event-ID")
+	}
+	var after int64
+	if strAfter != "" {
+		parsed, err := strconv.ParseInt(strAfter, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		after = parsed
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	for {
+		events, err := t.DB.Queries.GetModelEventsAfter(ctx, db_sqlc_gen.GetModelEventsAfterParams{
+			ModelID:  modelId,
+			AfterID:  after,
+			PageSize: timelinePageSize,
+		})
+		if err != nil {
+			t.Logger.Error("error while getting model events", zap.Error(err))
+			writeServerEvent(c, "", "error", []byte(`{"error":"internal error"}`))
+			return
+		}
+
+		for _, e := range events {
+			data, err := json.Marshal(timelineEventMessage(e))
+			if err != nil {
+				t.Logger.Error("error while marshalling model event", zap.Error(err))
+				writeServerEvent(c, "", "error", []byte(`{"error":"internal error"}`))
+				return
+			}
+			if err := writeServerEvent(c, strconv.FormatInt(e.ID, 10), "event", data); err != nil {
+				// The client went away
+				return
+			}
+			after = e.ID
+		}
+
+		if len(events) < timelinePageSize {
+			break
+		}
+	}
+
+	writeServerEvent(c, "", "end", []byte("{}"))
+}
+
+func (t *ModelTimelineRoute) InitModelTimelineRoute(router gin.IRouter) gin.IRouter {
+	router.GET("/projects/:projectId/models/:modelId/timeline/state", t.getModelTimelineState)
+	router.GET("/projects/:projectId/models/:modelId/timeline/events", t.getModelTimelineEvents)
+	return router
+}
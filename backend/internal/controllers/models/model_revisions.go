@@ -0,0 +1,343 @@
+package controller_model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/presence"
+	"omnicam.com/backend/internal/revision"
+	"omnicam.com/backend/internal/timeline"
+	"omnicam.com/backend/internal/utils"
+	db_client "omnicam.com/backend/pkg/db"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+type ModelRevisionRoute struct {
+	Logger   *zap.Logger
+	Env      *config_env.AppEnv
+	DB       *db_client.DB
+	Presence *presence.Hub
+}
+
+// Optional body of a revert
+type RevertRevisionRequest struct {
+	// Message of the new revision, defaults to "Revert to revision N"
+	Message string `json:"message"`
+}
+
+// Parses the ids of the path and checks that the user is a member of the project of the model.
+// Answers the request and returns false otherwise.
+func (t *ModelRevisionRoute) authorize(c *gin.Context) (uuid.UUID, db_sqlc_gen.GetUserOfProjectRow, bool) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	username := c.GetString("username")
+	userInfo, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	model, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
+		Fields: []string{},
+		ID:     modelId,
+	})
+	if err != nil || model.ProjectID != projectId {
+		t.Logger.Error("model not found", zap.String("modelId", strModelId), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+	return modelId, userInfo, true
+}
+
+func (t *ModelRevisionRoute) getModelRevisions(c *gin.Context) {
+	modelId, _, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	revisions, err := t.DB.Queries.GetModelRevisions(c, db_sqlc_gen.GetModelRevisionsParams{
+		ModelID:    modelId,
+		PageSize:   int32(pageSize),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		t.Logger.Error("error while getting model revisions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataCount, err := t.DB.Queries.CountModelRevisions(c, modelId)
+	if err != nil {
+		t.Logger.Error("error while counting model revisions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	data := make([]messages_model_workspace.Revision, 0, len(revisions))
+	for _, r := range revisions {
+		data = append(data, messages_model_workspace.Revision{
+			Revision:  r.Revision,
+			Version:   r.Version,
+			UserId:    utils.OptionalUuid(r.UserID),
+			Username:  utils.OptionalText(r.Username),
+			Message:   r.Message,
+			CreatedAt: r.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"count": dataCount,
+	})
+}
+
+func parseRevision(value string) (int32, bool) {
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed < 1 {
+		return 0, false
+	}
+	return int32(parsed), true
+}
+
+// Model of a revision, answers the request and returns false when it can't be loaded
+func (t *ModelRevisionRoute) revisionState(c *gin.Context, modelId uuid.UUID, number int32) (timeline.State, bool) {
+	snapshot, err := t.DB.Queries.GetModelRevision(c, db_sqlc_gen.GetModelRevisionParams{
+		ModelID:  modelId,
+		Revision: number,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("revision %d not found", number)})
+		return timeline.State{}, false
+	}
+	if err != nil {
+		t.Logger.Error("error while getting model revision", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return timeline.State{}, false
+	}
+
+	state, err := timeline.StateOf(snapshot.Cameras, snapshot.TargetAreaTrapezoids, snapshot.ScaleFactor, snapshot.ModelHeight)
+	if err != nil {
+		t.Logger.Error("model revision is invalid", zap.Int32("revision", number), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return timeline.State{}, false
+	}
+	return state, true
+}
+
+// Changes per camera, per face and of the calibration from one revision to another, in either
+// order
+func (t *ModelRevisionRoute) getModelRevisionsDiff(c *gin.Context) {
+	modelId, _, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	from, ok := parseRevision(c.Query("from"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from revision"})
+		return
+	}
+	to, ok := parseRevision(c.Query("to"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to revision"})
+		return
+	}
+
+	fromState, ok := t.revisionState(c, modelId, from)
+	if !ok {
+		return
+	}
+	toState, ok := t.revisionState(c, modelId, to)
+	if !ok {
+		return
+	}
+
+	diff, err := revision.Diff(fromState, toState)
+	if err != nil {
+		t.Logger.Error("error while diffing model revisions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	diff.From = from
+	diff.To = to
+
+	c.JSON(http.StatusOK, gin.H{"data": diff})
+}
+
+// Saves the model of an older revision to main as a new revision, the revisions in between are
+// kept
+func (t *ModelRevisionRoute) postRevertModelRevision(c *gin.Context) {
+	modelId, userInfo, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	number, ok := parseRevision(c.Param("revision"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	// The body is optional, an empty one reverts with the default message
+	var req RevertRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revert request"})
+		return
+	}
+	message := req.Message
+	if message == "" {
+		message = fmt.Sprintf("Revert to revision %d", number)
+	}
+
+	snapshot, err := t.DB.Queries.GetModelRevision(c, db_sqlc_gen.GetModelRevisionParams{
+		ModelID:  modelId,
+		Revision: number,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("revision %d not found", number)})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while getting model revision", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	tx, err := t.DB.Pool.Begin(c)
+	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
+	// Locked until the revert is saved, so that the announced change is the one saved
+	modelData, err := queries.GetModelStateForUpdate(c, modelId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		t.Logger.Error("model not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while getting model", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	// A protected main is only reverted by the roles that apply its merge requests
+	if modelData.Protected && !utils.CanMerge(userInfo.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "main is protected"})
+		return
+	}
+
+	saved, err := revision.Save(c, queries, modelId, userInfo.ID, revision.Change{
+		Cameras: snapshot.Cameras,
+		Faces:   snapshot.TargetAreaTrapezoids,
+		Calibration: &history.Calibration{
+			ScaleFactor: snapshot.ScaleFactor,
+			ModelHeight: snapshot.ModelHeight,
+		},
+		Message: message,
+	})
+	if err != nil {
+		t.Logger.Error("error while reverting model", zap.Int32("revision", number), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		t.Logger.Error("error while committing reverted model", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	t.announceRevert(c, modelId, modelData, saved.Version, snapshot.Cameras)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"revision": saved.Revision,
+		"version":  saved.Version,
+	}})
+}
+
+// Tells the users editing the model that main moved back, like a merge does. Unlike a merge no
+// workspace has the change yet, the one of the user who reverted included.
+func (t *ModelRevisionRoute) announceRevert(c *gin.Context, modelId uuid.UUID, previous db_sqlc_gen.GetModelStateForUpdateRow, version int32, current []byte) {
+	previousCameras, err := messages_cameras.UnmarshalCameras(previous.Cameras)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling previous model cams", zap.Error(err))
+		return
+	}
+
+	currentCameras, err := messages_cameras.UnmarshalCameras(current)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling model cams", zap.Error(err))
+		return
+	}
+
+	change, err := revision.CamerasChange(previousCameras, currentCameras)
+	if err != nil {
+		t.Logger.Error("error while diffing model cams", zap.Error(err))
+		return
+	}
+
+	t.Presence.AnnounceMainUpdate(c, modelId, uuid.Nil, &protobufs.MainUpdatedResponse{
+		MainVersion:     version,
+		PreviousVersion: previous.Version,
+		Cameras:         change,
+		UpdatedBy:       c.GetString("username"),
+	})
+}
+
+func (t *ModelRevisionRoute) InitModelRevisionRoute(router gin.IRouter) gin.IRouter {
+	router.GET("/projects/:projectId/models/:modelId/revisions", t.getModelRevisions)
+	router.GET("/projects/:projectId/models/:modelId/revisions/diff", t.getModelRevisionsDiff)
+	router.POST("/projects/:projectId/models/:modelId/revisions/:revision/revert", t.postRevertModelRevision)
+	return router
+}
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap"
 	config_env "omnicam.com/backend/config"
 	"omnicam.com/backend/internal"
+	"omnicam.com/backend/internal/revision"
 	"omnicam.com/backend/internal/utils"
 	db_client "omnicam.com/backend/pkg/db"
 	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
@@ -40,6 +41,13 @@ func (t *PostModelRoutes) post(c *gin.Context) {
 		return
 	}
 
+	userId, err := utils.GetUuidFromCtx(c, "userId")
+	if err != nil {
+		t.Logger.Error("error while getting userId form", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
 	if err := c.ShouldBind(&req); err != nil {
 		t.Logger.Debug("error while validating form", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{})
@@ -103,8 +111,17 @@ func (t *PostModelRoutes) post(c *gin.Context) {
 		t.Logger.Info("model image uploaded", zap.String("path", fsImagePath))
 	}
 
+	tx, err := t.DB.Pool.Begin(c)
+	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
 	// --- Insert into DB using web paths ---
-	data, err := t.DB.Queries.CreateModel(c, db_sqlc_gen.CreateModelParams{
+	data, err := queries.CreateModel(c, db_sqlc_gen.CreateModelParams{
 		ID:             modelId,
 		ProjectID:      projectId,
 		Name:           req.Name,
@@ -120,6 +137,22 @@ func (t *PostModelRoutes) post(c *gin.Context) {
 		return
 	}
 
+	// the new model starts from its first revision, like the models that existed before revisions
+	if _, err := queries.CreateModelRevision(c, db_sqlc_gen.CreateModelRevisionParams{
+		UserID:  userId,
+		Message: revision.MessageInitial,
+		ModelID: modelId,
+	}); err != nil {
+		t.Logger.Error("error while creating initial model revision", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		t.Logger.Error("error while committing model", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": messages_model_workspace.ModelWorkspace{
 		ModelId:     data.ID,
 		ProjectId:   data.ProjectID,
@@ -0,0 +1,91 @@
+package controller_model
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/utils"
+	db_client "omnicam.com/backend/pkg/db"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+type PutModelProtectionRoute struct {
+	Logger *zap.Logger
+	Env    *config_env.AppEnv
+	DB     *db_client.DB
+}
+
+type UpdateModelProtectionRequest struct {
+	Protected *bool `json:"protected" binding:"required"`
+}
+
+// Protects main of the model, it then only changes through approved merge requests
+func (t *PutModelProtectionRoute) put(c *gin.Context) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	username := c.GetString("username")
+	userInfo, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Debug("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	if !utils.CanMerge(userInfo.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role can't protect main"})
+		return
+	}
+
+	var req UpdateModelProtectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid protection request"})
+		return
+	}
+
+	protected, err := t.DB.Queries.SetModelProtection(c, db_sqlc_gen.SetModelProtectionParams{
+		Protected: *req.Protected,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while updating model protection", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"protected": protected}})
+}
+
+func (t *PutModelProtectionRoute) InitPutModelProtectionRoute(router gin.IRouter) gin.IRouter {
+	router.PUT("/projects/:projectId/models/:modelId/protection", t.put)
+	return router
+}
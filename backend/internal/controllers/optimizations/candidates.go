@@ -0,0 +1,406 @@
+package controller_optimizations
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_optimization "omnicam.com/backend/pkg/messages/optimization"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+type RenameCandidateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type ApplyCandidateRequest struct {
+	// Workspace version the candidate was compared against
+	Version *int32 `json:"version" binding:"required"`
+}
+
+func (t *OptimizationRoute) getCandidateRow(c *gin.Context) (db_sqlc_gen.GetCandidateLayoutRow, bool) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return db_sqlc_gen.GetCandidateLayoutRow{}, false
+	}
+
+	candidateId, err := utils.ParseUuidBase64(c.Param("candidateId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid candidate ID"})
+		return db_sqlc_gen.GetCandidateLayoutRow{}, false
+	}
+
+	candidate, err := t.DB.Queries.GetCandidateLayout(c, db_sqlc_gen.GetCandidateLayoutParams{
+		ID:        candidateId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("candidate layout not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return db_sqlc_gen.GetCandidateLayoutRow{}, false
+	}
+
+	return candidate, true
+}
+
+// Loads the workspace cameras of the user and the layout they would have with the candidate applied.
+// The workspace is the branch of the branchId query, or the default one.
+func (t *OptimizationRoute) getCandidateLayout(c *gin.Context, candidate db_sqlc_gen.GetCandidateLayoutRow) (workspaceId uuid.UUID, workspace messages_cameras.Cameras, layout messages_cameras.Cameras, version int32, ok bool) {
+	userId, err := utils.GetUuidFromCtx(c, "userId")
+	if err != nil {
+		t.Logger.Error("error while getting userId", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	branchId, err := utils.ParseNullUuidBase64(c.Query("branchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		return
+	}
+
+	row, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		Fields:      []string{"cameras"},
+		UserID:      userId,
+		ModelID:     candidate.ModelID,
+		WorkspaceID: branchId,
+	})
+	if err != nil {
+		t.Logger.Error("workspace not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return
+	}
+
+	workspace, err = messages_cameras.UnmarshalCameras(row.Cameras)
+	if err != nil {
+		t.Logger.Error("error while parsing workspace cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	cameras, err := messages_cameras.UnmarshalCameras(candidate.Cameras)
+	if err != nil {
+		t.Logger.Error("error while parsing candidate cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	return row.ID, workspace, optimizer.CandidateLayout(workspace, cameras), row.Version, true
+}
+
+func (t *OptimizationRoute) getCandidates(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	data, err := t.DB.Queries.GetCandidateLayoutsByModel(c, db_sqlc_gen.GetCandidateLayoutsByModelParams{
+		ModelID:    modelId,
+		ProjectID:  projectId,
+		PageSize:   int32(pageSize),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		t.Logger.Error("error while getting candidate layouts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataCount, err := t.DB.Queries.CountCandidateLayoutsByModel(c, db_sqlc_gen.CountCandidateLayoutsByModelParams{
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("error while counting candidate layouts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataList := make([]messages_optimization.CandidateLayout, 0, len(data))
+	for _, candidate := range data {
+		dataList = append(dataList, messages_optimization.CandidateLayout{
+			ID:             candidate.ID,
+			ModelId:        candidate.ModelID,
+			UserId:         candidate.UserID,
+			Username:       candidate.Username,
+			JobId:          candidate.OptimizationJobID,
+			Name:           candidate.Name,
+			FixedCameraIds: candidate.FixedCameraIds,
+			CreatedAt:      candidate.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:      candidate.UpdatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dataList, "count": dataCount})
+}
+
+func (t *OptimizationRoute) getCandidate(c *gin.Context) {
+	candidate, ok := t.getCandidateRow(c)
+	if !ok {
+		return
+	}
+
+	cameras, err := messages_cameras.UnmarshalCameras(candidate.Cameras)
+	if err != nil {
+		t.Logger.Error("error while parsing candidate cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages_optimization.CandidateLayout{
+		ID:             candidate.ID,
+		ModelId:        candidate.ModelID,
+		UserId:         candidate.UserID,
+		Username:       candidate.Username,
+		JobId:          candidate.OptimizationJobID,
+		Name:           candidate.Name,
+		Cameras:        cameras,
+		FixedCameraIds: candidate.FixedCameraIds,
+		CreatedAt:      candidate.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt:      candidate.UpdatedAt.Time.Format(time.RFC3339),
+	}})
+}
+
+func (t *OptimizationRoute) patchCandidate(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	candidateId, err := utils.ParseUuidBase64(c.Param("candidateId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid candidate ID"})
+		return
+	}
+
+	var req RenameCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+
+	row, err := t.DB.Queries.RenameCandidateLayout(c, db_sqlc_gen.RenameCandidateLayoutParams{
+		Name:      strings.TrimSpace(req.Name),
+		ID:        candidateId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("candidate layout not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"id":        row.ID,
+		"name":      row.Name,
+		"updatedAt": row.UpdatedAt.Time.Format(time.RFC3339),
+	}})
+}
+
+func (t *OptimizationRoute) deleteCandidate(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	candidateId, err := utils.ParseUuidBase64(c.Param("candidateId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid candidate ID"})
+		return
+	}
+
+	_, err = t.DB.Queries.DeleteCandidateLayout(c, db_sqlc_gen.DeleteCandidateLayoutParams{
+		ID:        candidateId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("candidate layout not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// Compares the workspace cameras of the user with the cameras they would have after applying the candidate
+func (t *OptimizationRoute) getCandidateDiff(c *gin.Context) {
+	candidate, ok := t.getCandidateRow(c)
+	if !ok {
+		return
+	}
+
+	_, workspace, layout, version, ok := t.getCandidateLayout(c, candidate)
+	if !ok {
+		return
+	}
+
+	camerasDiff, err := optimizer.DiffCameras(workspace, layout)
+	if err != nil {
+		t.Logger.Error("error while diffing cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": camerasDiff, "version": version})
+}
+
+// Changes to make to the workspace cameras for them to become the layout, in the order of the ids.
+// The layout keeps every workspace camera, so none is deleted.
+func layoutEvents(workspace messages_cameras.Cameras, layout messages_cameras.Cameras) ([]history.Event, error) {
+	events := []history.Event{}
+	for _, id := range slices.Sorted(maps.Keys(layout)) {
+		after, err := json.Marshal(layout[id])
+		if err != nil {
+			return nil, err
+		}
+		if cam, ok := workspace[id]; ok {
+			before, err := json.Marshal(cam)
+			if err != nil {
+				return nil, err
+			}
+			if utils.SameJSON(before, after) {
+				continue
+			}
+		}
+		events = append(events, history.Event{Kind: history.KindCamera, TargetId: string(id), After: after})
+	}
+	return events, nil
+}
+
+// Applies the candidate layout to the workspace as a single edit of its history, which can be undone
+// like an autosave. The connections to the workspace receive the changes.
+func (t *OptimizationRoute) postApplyCandidate(c *gin.Context) {
+	candidate, ok := t.getCandidateRow(c)
+	if !ok {
+		return
+	}
+
+	var req ApplyCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace version"})
+		return
+	}
+
+	workspaceId, workspace, layout, version, ok := t.getCandidateLayout(c, candidate)
+	if !ok {
+		return
+	}
+	if version != *req.Version {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace changed since the diff", "version": version})
+		return
+	}
+
+	events, err := layoutEvents(workspace, layout)
+	if err != nil {
+		t.Logger.Error("error while encoding cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"version": version, "cameras": layout}})
+		return
+	}
+
+	tx, err := t.DB.Pool.Begin(c)
+	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
+	newVersion, err := queries.BumpWorkspaceVersion(c, db_sqlc_gen.BumpWorkspaceVersionParams{
+		WorkspaceID: workspaceId,
+		Version:     version,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// An autosave landed between the read and the update
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace changed since the diff"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while bumping workspace version", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	log, err := history.Open(c, queries, workspaceId)
+	if err != nil {
+		t.Logger.Error("error while opening workspace history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	applied := make([]history.Event, 0, len(events))
+	for _, e := range events {
+		recorded, err := log.Apply(c, e)
+		if err != nil {
+			t.Logger.Error("error while applying candidate layout", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		applied = append(applied, recorded)
+	}
+	stacks, err := log.RecordEdit(c, newVersion, applied)
+	if err != nil {
+		t.Logger.Error("error while recording candidate layout", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	converted := make([]*protobufs.AutosaveEvent, len(applied))
+	for i, e := range applied {
+		if converted[i], err = history.AutosaveEvent(e); err != nil {
+			t.Logger.Error("error while converting candidate layout", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+	}
+
+	if err := tx.Commit(c); err != nil {
+		t.Logger.Error("error while committing candidate layout", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	t.Presence.AnnounceWorkspaceUpdate(c, candidate.ModelID, workspaceId, &protobufs.HistoryResponse{
+		LastUpdatedVersion: newVersion,
+		Events:             converted,
+		CanUndo:            stacks.CanUndo(),
+		CanRedo:            stacks.CanRedo(),
+	})
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"version": newVersion, "cameras": layout}})
+}
@@ -0,0 +1,224 @@
+package controller_optimizations
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/internal/presence"
+	"omnicam.com/backend/internal/utils"
+	db_client "omnicam.com/backend/pkg/db"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_optimization "omnicam.com/backend/pkg/messages/optimization"
+)
+
+type OptimizationRoute struct {
+	Logger   *zap.Logger
+	Env      *config_env.AppEnv
+	DB       *db_client.DB
+	JobQueue optimizer.JobQueue
+	Presence *presence.Hub
+}
+
+// parses projectId and modelId from the path and checks the user is a member of the project
+func (t *OptimizationRoute) authorize(c *gin.Context) (projectId uuid.UUID, modelId uuid.UUID, ok bool) {
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	strModelId := c.Param("modelId")
+	modelId, err = utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return
+	}
+
+	username := c.GetString("username")
+	_, err = t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	return projectId, modelId, true
+}
+
+func (t *OptimizationRoute) getOptimizations(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	data, err := t.DB.Queries.GetOptimizationJobsByModel(c, db_sqlc_gen.GetOptimizationJobsByModelParams{
+		ModelID:    modelId,
+		ProjectID:  projectId,
+		PageSize:   int32(pageSize),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		t.Logger.Error("error while getting optimization jobs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataCount, err := t.DB.Queries.CountOptimizationJobsByModel(c, db_sqlc_gen.CountOptimizationJobsByModelParams{
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("error while counting optimization jobs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataList := make([]messages_optimization.OptimizationJob, 0, len(data))
+	for _, job := range data {
+		dataList = append(dataList, messages_optimization.OptimizationJob{
+			ID:         job.ID,
+			ModelId:    job.ModelID,
+			UserId:     job.UserID,
+			Username:   job.Username,
+			Status:     string(job.Status),
+			Error:      utils.OptionalText(job.Error),
+			CreatedAt:  job.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:  job.UpdatedAt.Time.Format(time.RFC3339),
+			FinishedAt: utils.FormatOptionalTime(job.FinishedAt),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dataList, "count": dataCount})
+}
+
+func (t *OptimizationRoute) getOptimization(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	jobId, err := utils.ParseUuidBase64(c.Param("jobId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job ID"})
+		return
+	}
+
+	job, err := t.DB.Queries.GetOptimizationJob(c, db_sqlc_gen.GetOptimizationJobParams{
+		ID:        jobId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("optimization job not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages_optimization.OptimizationJob{
+		ID:         job.ID,
+		ModelId:    job.ModelID,
+		UserId:     job.UserID,
+		Username:   job.Username,
+		Status:     string(job.Status),
+		Request:    job.Request,
+		Result:     job.Result,
+		Error:      utils.OptionalText(job.Error),
+		CreatedAt:  job.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt:  job.UpdatedAt.Time.Format(time.RFC3339),
+		FinishedAt: utils.FormatOptionalTime(job.FinishedAt),
+	}})
+}
+
+func (t *OptimizationRoute) postCancelOptimization(c *gin.Context) {
+	projectId, modelId, ok := t.authorize(c)
+	if !ok {
+		return
+	}
+
+	jobId, err := utils.ParseUuidBase64(c.Param("jobId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job ID"})
+		return
+	}
+
+	userId, err := utils.GetUuidFromCtx(c, "userId")
+	if err != nil {
+		t.Logger.Error("error while getting userId", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	job, err := t.DB.Queries.GetOptimizationJob(c, db_sqlc_gen.GetOptimizationJobParams{
+		ID:        jobId,
+		ModelID:   modelId,
+		ProjectID: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("optimization job not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	if job.UserID != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the requester can cancel the optimization"})
+		return
+	}
+
+	err = optimizer.CancelJob(c, t.DB, t.JobQueue, jobId)
+	if errors.Is(err, optimizer.ErrJobFinished) {
+		c.JSON(http.StatusConflict, gin.H{"error": "optimization already finished"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while cancelling optimization job", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (t *OptimizationRoute) InitRoute(router gin.IRouter) gin.IRouter {
+	router.GET("/projects/:projectId/models/:modelId/optimizations", t.getOptimizations)
+	router.GET("/projects/:projectId/models/:modelId/optimizations/:jobId", t.getOptimization)
+	router.POST("/projects/:projectId/models/:modelId/optimizations/:jobId/cancel", t.postCancelOptimization)
+	router.GET("/projects/:projectId/models/:modelId/candidates", t.getCandidates)
+	router.GET("/projects/:projectId/models/:modelId/candidates/:candidateId", t.getCandidate)
+	router.PATCH("/projects/:projectId/models/:modelId/candidates/:candidateId", t.patchCandidate)
+	router.DELETE("/projects/:projectId/models/:modelId/candidates/:candidateId", t.deleteCandidate)
+	router.GET("/projects/:projectId/models/:modelId/candidates/:candidateId/diff", t.getCandidateDiff)
+	router.POST("/projects/:projectId/models/:modelId/candidates/:candidateId/apply", t.postApplyCandidate)
+	return router
+}
@@ -0,0 +1,317 @@
+package controller_workspaces
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_workspace "omnicam.com/backend/pkg/messages/workspace"
+)
+
+// Name of the workspace used by the /workspaces/me endpoints and by the autosave without a branch
+const DefaultBranch = "default"
+
+// Close reason of the connections to a deleted workspace
+const deletedReason = "workspace deleted"
+
+type CreateBranchRequest struct {
+	Name string `json:"name" binding:"required,max=255,ne=default"`
+	// Branch to copy, main when empty
+	FromBranchId *uuid.UUID `json:"fromBranchId"`
+}
+
+type RenameBranchRequest struct {
+	Name string `json:"name" binding:"required,max=255,ne=default"`
+}
+
+func isUniqueViolation(err error) bool {
+	var e *pgconn.PgError
+	return errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation
+}
+
+// Model and member of the project from the path, writes the error response when not ok
+func (t *WorkspaceRoute) branchOwner(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	username := c.GetString("username")
+	userInfo, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return modelId, userInfo.ID, true
+}
+
+func (t *WorkspaceRoute) parseBranchId(c *gin.Context) (uuid.UUID, bool) {
+	strBranchId := c.Param("branchId")
+	branchId, err := utils.ParseUuidBase64(strBranchId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		return uuid.Nil, false
+	}
+	return branchId, true
+}
+
+// Lists the workspaces of the user on the model, oldest first
+func (t *WorkspaceRoute) getBranches(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+
+	branches, err := t.DB.Queries.GetWorkspaces(c, db_sqlc_gen.GetWorkspacesParams{
+		UserID:  userId,
+		ModelID: modelId,
+	})
+	if err != nil {
+		t.Logger.Error("error while getting branches", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataList := make([]messages_workspace.Branch, 0, len(branches))
+	for _, branch := range branches {
+		dataList = append(dataList, messages_workspace.Branch{
+			Id:          branch.ID,
+			Name:        branch.Name,
+			Version:     branch.Version,
+			BaseVersion: branch.BaseVersion,
+			CreatedAt:   branch.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:   branch.UpdatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dataList, "count": len(dataList)})
+}
+
+// Creates a branch from main, or from another branch of the user with the same base version
+func (t *WorkspaceRoute) postBranch(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+
+	var req CreateBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branch messages_workspace.Branch
+	var err error
+	if req.FromBranchId == nil {
+		var row db_sqlc_gen.CreateWorkspaceRow
+		row, err = t.DB.Queries.CreateWorkspace(c, db_sqlc_gen.CreateWorkspaceParams{
+			UserID:  userId,
+			ModelID: modelId,
+			Name:    req.Name,
+		})
+		branch = messages_workspace.Branch{
+			Id:          row.ID,
+			Name:        row.Name,
+			Version:     row.Version,
+			BaseVersion: row.BaseVersion,
+			CreatedAt:   row.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:   row.UpdatedAt.Time.Format(time.RFC3339),
+		}
+	} else {
+		var row db_sqlc_gen.CreateWorkspaceFromRow
+		row, err = t.DB.Queries.CreateWorkspaceFrom(c, db_sqlc_gen.CreateWorkspaceFromParams{
+			Name:     req.Name,
+			SourceID: *req.FromBranchId,
+			UserID:   userId,
+			ModelID:  modelId,
+		})
+		branch = messages_workspace.Branch{
+			Id:          row.ID,
+			Name:        row.Name,
+			Version:     row.Version,
+			BaseVersion: row.BaseVersion,
+			CreatedAt:   row.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:   row.UpdatedAt.Time.Format(time.RFC3339),
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model or source branch not found"})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "branch already exists"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while creating branch", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": branch})
+}
+
+func (t *WorkspaceRoute) patchBranch(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+	branchId, ok := t.parseBranchId(c)
+	if !ok {
+		return
+	}
+
+	var req RenameBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		UserID:      userId,
+		ModelID:     modelId,
+		WorkspaceID: pgtype.UUID{Bytes: branchId, Valid: true},
+	})
+	if err != nil {
+		t.Logger.Error("branch not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	// The /workspaces/me endpoints rely on the default branch keeping its name
+	if workspace.Name == DefaultBranch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the default branch can't be renamed"})
+		return
+	}
+
+	row, err := t.DB.Queries.RenameWorkspace(c, db_sqlc_gen.RenameWorkspaceParams{
+		Name:        req.Name,
+		WorkspaceID: branchId,
+		UserID:      userId,
+		ModelID:     modelId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "branch already exists"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while renaming branch", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages_workspace.Branch{
+		Id:          row.ID,
+		Name:        row.Name,
+		Version:     row.Version,
+		BaseVersion: row.BaseVersion,
+		CreatedAt:   row.CreatedAt.Time.Format(time.RFC3339),
+		UpdatedAt:   row.UpdatedAt.Time.Format(time.RFC3339),
+	}})
+}
+
+func (t *WorkspaceRoute) deleteBranch(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+	branchId, ok := t.parseBranchId(c)
+	if !ok {
+		return
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		UserID:      userId,
+		ModelID:     modelId,
+		WorkspaceID: pgtype.UUID{Bytes: branchId, Valid: true},
+	})
+	if err != nil {
+		t.Logger.Error("branch not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	// Deleted through the /workspaces/me endpoint instead
+	if workspace.Name == DefaultBranch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the default branch can't be deleted"})
+		return
+	}
+
+	if err := t.DB.Queries.DeleteWorkspace(c, workspace.ID); err != nil {
+		t.Logger.Error("error while deleting branch", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	t.Presence.CloseWorkspace(c, modelId, workspace.ID, deletedReason)
+	c.Status(http.StatusNoContent)
+}
+
+func (t *WorkspaceRoute) postMergeBranch(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+	branchId, ok := t.parseBranchId(c)
+	if !ok {
+		return
+	}
+
+	t.mergeWorkspace(c, workspaceMerge{
+		modelId:  modelId,
+		ownerId:  userId,
+		userId:   userId,
+		branchId: pgtype.UUID{Bytes: branchId, Valid: true},
+	})
+}
+
+func (t *WorkspaceRoute) postResolveBranch(c *gin.Context) {
+	modelId, userId, ok := t.branchOwner(c)
+	if !ok {
+		return
+	}
+	branchId, ok := t.parseBranchId(c)
+	if !ok {
+		return
+	}
+
+	t.resolveWorkspace(c, workspaceMerge{
+		modelId:  modelId,
+		ownerId:  userId,
+		userId:   userId,
+		branchId: pgtype.UUID{Bytes: branchId, Valid: true},
+	})
+}
@@ -0,0 +1,154 @@
+package controller_workspaces
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_workspace "omnicam.com/backend/pkg/messages/workspace"
+)
+
+// Lists the undo history of the workspace, newest step first
+func (t *WorkspaceRoute) getWorkspaceMeHistory(c *gin.Context) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	username := c.GetString("username")
+	userInfo, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	branchId, err := utils.ParseNullUuidBase64(c.Query("branchId"))
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		return
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		UserID:      userInfo.ID,
+		ModelID:     modelId,
+		WorkspaceID: branchId,
+	})
+	if err != nil {
+		t.Logger.Error("workspace not found", zap.String("modelId", strModelId), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	steps, err := t.DB.Queries.GetWorkspaceSteps(c, db_sqlc_gen.GetWorkspaceStepsParams{
+		WorkspaceID: workspace.ID,
+		PageSize:    int32(pageSize),
+		PageOffset:  int32(offset),
+	})
+	if err != nil {
+		t.Logger.Error("error while getting workspace history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataCount, err := t.DB.Queries.CountWorkspaceSteps(c, workspace.ID)
+	if err != nil {
+		t.Logger.Error("error while counting workspace history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	stacks, err := history.LatestStacks(c, t.DB.Queries, workspace.ID)
+	if err != nil {
+		t.Logger.Error("error while getting workspace history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	versions := make([]int32, 0, len(steps))
+	for _, step := range steps {
+		versions = append(versions, step.Version)
+	}
+	events, err := t.DB.Queries.GetWorkspaceEventsByVersions(c, db_sqlc_gen.GetWorkspaceEventsByVersionsParams{
+		WorkspaceID: workspace.ID,
+		Versions:    versions,
+	})
+	if err != nil {
+		t.Logger.Error("error while getting workspace history events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	eventsByVersion := make(map[int32][]messages_workspace.HistoryEvent, len(steps))
+	for _, event := range events {
+		eventsByVersion[event.Version] = append(eventsByVersion[event.Version], messages_workspace.HistoryEvent{
+			Kind:     event.Kind,
+			TargetId: event.TargetID,
+			Before:   event.BeforeValue,
+			After:    event.AfterValue,
+		})
+	}
+
+	dataList := make([]messages_workspace.HistoryStep, 0, len(steps))
+	for _, step := range steps {
+		var targetVersion *int32
+		if step.TargetVersion.Valid {
+			targetVersion = &step.TargetVersion.Int32
+		}
+		stepEvents := eventsByVersion[step.Version]
+		if stepEvents == nil {
+			stepEvents = []messages_workspace.HistoryEvent{}
+		}
+		dataList = append(dataList, messages_workspace.HistoryStep{
+			Version:       step.Version,
+			Action:        step.Action,
+			TargetVersion: targetVersion,
+			Events:        stepEvents,
+			CreatedAt:     step.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    dataList,
+		"count":   dataCount,
+		"canUndo": stacks.CanUndo(),
+		"canRedo": stacks.CanRedo(),
+	})
+}
@@ -0,0 +1,39 @@
+package controller_workspaces
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/revision"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Tells the users editing the model that main moved, so that they can merge before their
+// workspace drifts further. The merged workspace already has the change.
+func (t *WorkspaceRoute) announceMainUpdate(c *gin.Context, modelId uuid.UUID, workspaceId uuid.UUID, previousVersion int32, version int32, previous []byte, current []byte) {
+	previousCameras, err := messages_cameras.UnmarshalCameras(previous)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling previous model cams", zap.Error(err))
+		return
+	}
+
+	currentCameras, err := messages_cameras.UnmarshalCameras(current)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling model cams", zap.Error(err))
+		return
+	}
+
+	change, err := revision.CamerasChange(previousCameras, currentCameras)
+	if err != nil {
+		t.Logger.Error("error while diffing model cams", zap.Error(err))
+		return
+	}
+
+	t.Presence.AnnounceMainUpdate(c, modelId, workspaceId, &protobufs.MainUpdatedResponse{
+		MainVersion:     version,
+		PreviousVersion: previousVersion,
+		Cameras:         change,
+		UpdatedBy:       c.GetString("username"),
+	})
+}
@@ -0,0 +1,689 @@
+package controller_workspaces
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/revision"
+	"omnicam.com/backend/internal/timeline"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	messages_workspace "omnicam.com/backend/pkg/messages/workspace"
+)
+
+const protectedMessage = "main is protected, open a merge request"
+
+type CreateMergeRequestRequest struct {
+	// Workspace to merge, the default one when empty
+	BranchId    *uuid.UUID `json:"branchId"`
+	Description string     `json:"description"`
+}
+
+type UpdateMergeRequestRequest struct {
+	Description *string `json:"description"`
+}
+
+type CreateMergeRequestCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// Parses the ids of the path and checks that the user is a member of the project of the model.
+// Answers the request and returns false otherwise.
+func (t *WorkspaceRoute) authorizeMergeRequests(c *gin.Context) (uuid.UUID, db_sqlc_gen.GetUserOfProjectRow, bool) {
+	strModelId := c.Param("modelId")
+	modelId, err := utils.ParseUuidBase64(strModelId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model ID"})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	strProjectId := c.Param("projectId")
+	projectId, err := utils.ParseUuidBase64(strProjectId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	username := c.GetString("username")
+	userInfo, err := t.DB.Queries.GetUserOfProject(c, db_sqlc_gen.GetUserOfProjectParams{
+		Username: pgtype.Text{
+			String: username,
+			Valid:  true,
+		},
+		Projectid: projectId,
+	})
+	if err != nil {
+		t.Logger.Error("user of project not found", zap.String("projectId", strProjectId), zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+
+	model, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
+		Fields: []string{},
+		ID:     modelId,
+	})
+	if err != nil || model.ProjectID != projectId {
+		t.Logger.Error("model not found", zap.String("modelId", strModelId), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{})
+		return uuid.UUID{}, db_sqlc_gen.GetUserOfProjectRow{}, false
+	}
+	return modelId, userInfo, true
+}
+
+// Reads the merge request of the path, answers the request and returns false when it isn't found
+func (t *WorkspaceRoute) mergeRequestOf(c *gin.Context, modelId uuid.UUID) (db_sqlc_gen.GetMergeRequestRow, bool) {
+	strMergeRequestId := c.Param("mergeRequestId")
+	mergeRequestId, err := utils.ParseUuidBase64(strMergeRequestId)
+	if err != nil {
+		t.Logger.Error("error while converting str id to uuid", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merge request ID"})
+		return db_sqlc_gen.GetMergeRequestRow{}, false
+	}
+
+	mergeRequest, err := t.DB.Queries.GetMergeRequest(c, db_sqlc_gen.GetMergeRequestParams{
+		ID:      mergeRequestId,
+		ModelID: modelId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return db_sqlc_gen.GetMergeRequestRow{}, false
+	}
+	if err != nil {
+		t.Logger.Error("error while getting merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return db_sqlc_gen.GetMergeRequestRow{}, false
+	}
+	return mergeRequest, true
+}
+
+// Changes of the workspace against its base, the calibration against main like a merge compares it
+func (t *WorkspaceRoute) workspaceDiff(c *gin.Context, modelId uuid.UUID, workspace db_sqlc_gen.GetWorkspaceByIDRow) (messages_workspace.MergeRequestDiff, error) {
+	model, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
+		Fields: []string{},
+		ID:     modelId,
+	})
+	if err != nil {
+		return messages_workspace.MergeRequestDiff{}, err
+	}
+
+	from, err := timeline.StateOf(workspace.BaseCameras, workspace.BaseTargetAreaTrapezoids, model.ScaleFactor, model.ModelHeight)
+	if err != nil {
+		return messages_workspace.MergeRequestDiff{}, err
+	}
+	to, err := timeline.StateOf(workspace.Cameras, workspace.TargetAreaTrapezoids, workspace.ScaleFactor, workspace.ModelHeight)
+	if err != nil {
+		return messages_workspace.MergeRequestDiff{}, err
+	}
+	diff, err := revision.Diff(from, to)
+	if err != nil {
+		return messages_workspace.MergeRequestDiff{}, err
+	}
+
+	return messages_workspace.MergeRequestDiff{
+		BaseVersion: workspace.BaseVersion,
+		Version:     workspace.Version,
+		Cameras:     diff.Cameras,
+		Faces:       diff.Faces,
+		Calibration: diff.Calibration,
+	}, nil
+}
+
+func (t *WorkspaceRoute) getMergeRequests(c *gin.Context) {
+	modelId, _, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+
+	var status db_sqlc_gen.NullMergeRequestStatus
+	if strStatus := c.Query("status"); strStatus != "" {
+		switch db_sqlc_gen.MergeRequestStatus(strStatus) {
+		case db_sqlc_gen.MergeRequestStatusOpen, db_sqlc_gen.MergeRequestStatusMerged, db_sqlc_gen.MergeRequestStatusClosed:
+			status = db_sqlc_gen.NullMergeRequestStatus{MergeRequestStatus: db_sqlc_gen.MergeRequestStatus(strStatus), Valid: true}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+			return
+		}
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	mergeRequests, err := t.DB.Queries.GetMergeRequestsByModel(c, db_sqlc_gen.GetMergeRequestsByModelParams{
+		ModelID:    modelId,
+		Status:     status,
+		PageSize:   int32(pageSize),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		t.Logger.Error("error while getting merge requests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataCount, err := t.DB.Queries.CountMergeRequestsByModel(c, db_sqlc_gen.CountMergeRequestsByModelParams{
+		ModelID: modelId,
+		Status:  status,
+	})
+	if err != nil {
+		t.Logger.Error("error while counting merge requests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	dataList := make([]messages_workspace.MergeRequest, 0, len(mergeRequests))
+	for _, mr := range mergeRequests {
+		var revisionNumber *int32
+		if mr.Revision.Valid {
+			revisionNumber = &mr.Revision.Int32
+		}
+		dataList = append(dataList, messages_workspace.MergeRequest{
+			Id:               mr.ID,
+			BranchId:         utils.OptionalUuid(mr.WorkspaceID),
+			UserId:           mr.UserID,
+			Username:         mr.Username,
+			Description:      mr.Description,
+			WorkspaceVersion: mr.WorkspaceVersion,
+			Status:           string(mr.Status),
+			MergedBy:         utils.OptionalUuid(mr.MergedBy),
+			MergedByUsername: utils.OptionalText(mr.MergedByUsername),
+			Revision:         revisionNumber,
+			ApprovalCount:    mr.ApprovalCount,
+			CreatedAt:        mr.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:        mr.UpdatedAt.Time.Format(time.RFC3339),
+			ClosedAt:         utils.FormatOptionalTime(mr.ClosedAt),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dataList, "count": dataCount})
+}
+
+func (t *WorkspaceRoute) getMergeRequest(c *gin.Context) {
+	modelId, _, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+
+	var diff messages_workspace.MergeRequestDiff
+	if err := json.Unmarshal(mr.Diff, &diff); err != nil {
+		t.Logger.Error("merge request diff jsonb is invalid", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	comments, err := t.DB.Queries.GetMergeRequestComments(c, mr.ID)
+	if err != nil {
+		t.Logger.Error("error while getting merge request comments", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	commentList := make([]messages_workspace.MergeRequestComment, 0, len(comments))
+	for _, comment := range comments {
+		commentList = append(commentList, messages_workspace.MergeRequestComment{
+			Id:        comment.ID,
+			UserId:    comment.UserID,
+			Username:  comment.Username,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	approvals, err := t.DB.Queries.GetMergeRequestApprovals(c, mr.ID)
+	if err != nil {
+		t.Logger.Error("error while getting merge request approvals", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	approvalList := make([]messages_workspace.MergeRequestApproval, 0, len(approvals))
+	for _, approval := range approvals {
+		approvalList = append(approvalList, messages_workspace.MergeRequestApproval{
+			UserId:    approval.UserID,
+			Username:  approval.Username,
+			CreatedAt: approval.CreatedAt.Time.Format(time.RFC3339),
+		})
+	}
+
+	var revisionNumber *int32
+	if mr.Revision.Valid {
+		revisionNumber = &mr.Revision.Int32
+	}
+	c.JSON(http.StatusOK, gin.H{"data": messages_workspace.MergeRequestDetail{
+		MergeRequest: messages_workspace.MergeRequest{
+			Id:               mr.ID,
+			BranchId:         utils.OptionalUuid(mr.WorkspaceID),
+			UserId:           mr.UserID,
+			Username:         mr.Username,
+			Description:      mr.Description,
+			WorkspaceVersion: mr.WorkspaceVersion,
+			Status:           string(mr.Status),
+			MergedBy:         utils.OptionalUuid(mr.MergedBy),
+			MergedByUsername: utils.OptionalText(mr.MergedByUsername),
+			Revision:         revisionNumber,
+			ApprovalCount:    int64(len(approvalList)),
+			CreatedAt:        mr.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:        mr.UpdatedAt.Time.Format(time.RFC3339),
+			ClosedAt:         utils.FormatOptionalTime(mr.ClosedAt),
+		},
+		Diff:      diff,
+		Comments:  commentList,
+		Approvals: approvalList,
+	}})
+}
+
+// Opens a merge request from a workspace of the user with the diff of its current version
+func (t *WorkspaceRoute) postMergeRequest(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+
+	var req CreateMergeRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var branchId pgtype.UUID
+	if req.BranchId != nil {
+		branchId = pgtype.UUID{Bytes: *req.BranchId, Valid: true}
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		Fields:      []string{"cameras", "base_cameras", "target_area_trapezoids", "base_target_area_trapezoids"},
+		UserID:      userInfo.ID,
+		ModelID:     modelId,
+		WorkspaceID: branchId,
+	})
+	if err != nil {
+		t.Logger.Error("workspace not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return
+	}
+
+	diff, err := t.workspaceDiff(c, modelId, workspace)
+	if err != nil {
+		t.Logger.Error("error while diffing workspace", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if len(diff.Cameras) == 0 && len(diff.Faces) == 0 && len(diff.Calibration) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workspace has no changes"})
+		return
+	}
+	encodedDiff, err := json.Marshal(diff)
+	if err != nil {
+		t.Logger.Error("error while marshalling workspace diff", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	created, err := t.DB.Queries.CreateMergeRequest(c, db_sqlc_gen.CreateMergeRequestParams{
+		ModelID:          modelId,
+		WorkspaceID:      workspace.ID,
+		UserID:           userInfo.ID,
+		Description:      req.Description,
+		Diff:             encodedDiff,
+		WorkspaceVersion: workspace.Version,
+	})
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace already has an open merge request"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while creating merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	branch := workspace.ID
+	c.JSON(http.StatusCreated, gin.H{"data": messages_workspace.MergeRequestDetail{
+		MergeRequest: messages_workspace.MergeRequest{
+			Id:               created.ID,
+			BranchId:         &branch,
+			UserId:           userInfo.ID,
+			Username:         c.GetString("username"),
+			Description:      req.Description,
+			WorkspaceVersion: workspace.Version,
+			Status:           string(created.Status),
+			CreatedAt:        created.CreatedAt.Time.Format(time.RFC3339),
+			UpdatedAt:        created.UpdatedAt.Time.Format(time.RFC3339),
+		},
+		Diff:      diff,
+		Comments:  []messages_workspace.MergeRequestComment{},
+		Approvals: []messages_workspace.MergeRequestApproval{},
+	}})
+}
+
+// Recomputes the diff with the current version of the workspace, the approvals of an older
+// version are dropped
+func (t *WorkspaceRoute) patchMergeRequest(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+	if mr.UserID != userInfo.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the author can update the merge request"})
+		return
+	}
+	if mr.Status != db_sqlc_gen.MergeRequestStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't open"})
+		return
+	}
+	if !mr.WorkspaceID.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace of the merge request was deleted"})
+		return
+	}
+
+	var req UpdateMergeRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var description pgtype.Text
+	if req.Description != nil {
+		description = pgtype.Text{String: *req.Description, Valid: true}
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
+		Fields:      []string{"cameras", "base_cameras", "target_area_trapezoids", "base_target_area_trapezoids"},
+		UserID:      mr.UserID,
+		ModelID:     modelId,
+		WorkspaceID: mr.WorkspaceID,
+	})
+	if err != nil {
+		t.Logger.Error("workspace not found", zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return
+	}
+
+	diff, err := t.workspaceDiff(c, modelId, workspace)
+	if err != nil {
+		t.Logger.Error("error while diffing workspace", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	encodedDiff, err := json.Marshal(diff)
+	if err != nil {
+		t.Logger.Error("error while marshalling workspace diff", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	tx, err := t.DB.Pool.Begin(c)
+	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
+	_, err = queries.RefreshMergeRequest(c, db_sqlc_gen.RefreshMergeRequestParams{
+		Description:      description,
+		Diff:             encodedDiff,
+		WorkspaceVersion: workspace.Version,
+		ID:               mr.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't open"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while refreshing merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if workspace.Version != mr.WorkspaceVersion {
+		if err := queries.DeleteMergeRequestApprovals(c, mr.ID); err != nil {
+			t.Logger.Error("error while dropping merge request approvals", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+	}
+	if err := tx.Commit(c); err != nil {
+		t.Logger.Error("error while committing merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	t.getMergeRequest(c)
+}
+
+func (t *WorkspaceRoute) postMergeRequestComment(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+
+	var req CreateMergeRequestCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := t.DB.Queries.CreateMergeRequestComment(c, db_sqlc_gen.CreateMergeRequestCommentParams{
+		MergeRequestID: mr.ID,
+		UserID:         userInfo.ID,
+		Body:           req.Body,
+	})
+	if err != nil {
+		t.Logger.Error("error while creating merge request comment", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": messages_workspace.MergeRequestComment{
+		Id:        comment.ID,
+		UserId:    userInfo.ID,
+		Username:  c.GetString("username"),
+		Body:      req.Body,
+		CreatedAt: comment.CreatedAt.Time.Format(time.RFC3339),
+	}})
+}
+
+// Approves the diff of the merge request, which must still be the one of the workspace
+func (t *WorkspaceRoute) postMergeRequestApproval(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+	if mr.UserID == userInfo.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "authors can't approve their own merge request"})
+		return
+	}
+	if mr.Status != db_sqlc_gen.MergeRequestStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't open"})
+		return
+	}
+	if !mr.WorkspaceID.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace of the merge request was deleted"})
+		return
+	}
+
+	workspace, err := t.DB.Queries.GetWorkspaceLayout(c, mr.WorkspaceID.Bytes)
+	if err != nil {
+		t.Logger.Error("error while reading workspace version", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if workspace.Version != mr.WorkspaceVersion {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace changed since the merge request was updated"})
+		return
+	}
+
+	err = t.DB.Queries.ApproveMergeRequest(c, db_sqlc_gen.ApproveMergeRequestParams{
+		MergeRequestID: mr.ID,
+		UserID:         userInfo.ID,
+	})
+	if err != nil {
+		t.Logger.Error("error while approving merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (t *WorkspaceRoute) deleteMergeRequestApproval(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+
+	err := t.DB.Queries.DeleteMergeRequestApproval(c, db_sqlc_gen.DeleteMergeRequestApprovalParams{
+		MergeRequestID: mr.ID,
+		UserID:         userInfo.ID,
+	})
+	if err != nil {
+		t.Logger.Error("error while withdrawing merge request approval", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Closes the merge request without merging it, by its author or by a role that merges
+func (t *WorkspaceRoute) postCloseMergeRequest(c *gin.Context) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return
+	}
+	if mr.UserID != userInfo.ID && !utils.CanMerge(userInfo.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role can't close the merge request"})
+		return
+	}
+
+	_, err := t.DB.Queries.FinishMergeRequest(c, db_sqlc_gen.FinishMergeRequestParams{
+		Status: db_sqlc_gen.MergeRequestStatusClosed,
+		ID:     mr.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't open"})
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while closing merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Checks that the merge request can be applied by the user and returns the merge of its workspace.
+// Answers the request and returns false otherwise.
+func (t *WorkspaceRoute) approvedMerge(c *gin.Context) (workspaceMerge, bool) {
+	modelId, userInfo, ok := t.authorizeMergeRequests(c)
+	if !ok {
+		return workspaceMerge{}, false
+	}
+	if !utils.CanMerge(userInfo.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role can't apply merge requests"})
+		return workspaceMerge{}, false
+	}
+	mr, ok := t.mergeRequestOf(c, modelId)
+	if !ok {
+		return workspaceMerge{}, false
+	}
+	if mr.Status != db_sqlc_gen.MergeRequestStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't open"})
+		return workspaceMerge{}, false
+	}
+	if !mr.WorkspaceID.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace of the merge request was deleted"})
+		return workspaceMerge{}, false
+	}
+
+	approvals, err := t.DB.Queries.GetMergeRequestApprovals(c, mr.ID)
+	if err != nil {
+		t.Logger.Error("error while getting merge request approvals", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return workspaceMerge{}, false
+	}
+	if len(approvals) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "merge request isn't approved"})
+		return workspaceMerge{}, false
+	}
+
+	return workspaceMerge{
+		modelId:      modelId,
+		ownerId:      mr.UserID,
+		userId:       userInfo.ID,
+		branchId:     mr.WorkspaceID,
+		mergeRequest: true,
+		version:      pgtype.Int4{Int32: mr.WorkspaceVersion, Valid: true},
+		onMerged: func(queries *db_sqlc_gen.Queries, saved revision.Saved) error {
+			_, err := queries.FinishMergeRequest(c, db_sqlc_gen.FinishMergeRequestParams{
+				Status:   db_sqlc_gen.MergeRequestStatusMerged,
+				MergedBy: pgtype.UUID{Bytes: userInfo.ID, Valid: true},
+				Revision: pgtype.Int4{Int32: saved.Revision, Valid: true},
+				ID:       mr.ID,
+			})
+			return err
+		},
+	}, true
+}
+
+// Applies an approved merge request, answers with the conflicts like a merge of the workspace
+func (t *WorkspaceRoute) postMergeMergeRequest(c *gin.Context) {
+	m, ok := t.approvedMerge(c)
+	if !ok {
+		return
+	}
+	t.mergeWorkspace(c, m)
+}
+
+// Applies an approved merge request with the resolutions of its conflicts
+func (t *WorkspaceRoute) postResolveMergeRequest(c *gin.Context) {
+	m, ok := t.approvedMerge(c)
+	if !ok {
+		return
+	}
+	t.resolveWorkspace(c, m)
+}
@@ -4,21 +4,23 @@ import (
 	"cmp"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
-	"reflect"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/r3labs/diff/v3"
 	"go.uber.org/zap"
 	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/merge"
+	"omnicam.com/backend/internal/presence"
+	"omnicam.com/backend/internal/revision"
 	"omnicam.com/backend/internal/utils"
 	db_client "omnicam.com/backend/pkg/db"
 	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
@@ -29,218 +31,97 @@ import (
 )
 
 type WorkspaceRoute struct {
-	Logger *zap.Logger
-	Env    *config_env.AppEnv
-	DB     *db_client.DB
+	Logger   *zap.Logger
+	Env      *config_env.AppEnv
+	DB       *db_client.DB
+	Presence *presence.Hub
 }
 
-type FieldConflict struct {
-	Base      any `json:"base"`
-	Main      any `json:"main"`
-	Workspace any `json:"workspace"`
+// Choice for the conflict at Path in a camera or a face, Value is the custom value
+type LeafResolution struct {
+	Document string     `json:"document" binding:"required,oneof=camera face"`
+	Id       string     `json:"id" binding:"required"`
+	Path     []string   `json:"path" binding:"required,min=1"`
+	Take     merge.Take `json:"take" binding:"required,oneof=main workspace custom"`
+	Value    any        `json:"value"`
 }
 
-type CamProperty string
-
-const MaxMergeDepth = 10
-
-func applyChangeReflect(cam *messages_cameras.CameraStruct, c *diff.Change) error {
-	if len(c.Path) == 0 {
-		return nil
-	}
-	field := c.Path[0]
-
-	v := reflect.ValueOf(cam).Elem()
-	f := v.FieldByNameFunc(func(name string) bool {
-		// match against json or diff tag
-		t, _ := v.Type().FieldByName(name)
-		tag := t.Tag.Get("diff")
-		return tag == field || t.Tag.Get("json") == field
-	})
-
-	if !f.IsValid() {
-		return fmt.Errorf("unknown field: %s", field)
-	}
-	if !f.CanSet() {
-		return fmt.Errorf("cannot set field: %s", field)
-	}
-
-	if c.To == nil {
-		f.Set(reflect.Zero(f.Type())) // Set to default (0, "", etc) instead of panicking
-		return nil
-	}
-
-	val := reflect.ValueOf(c.To)
-	if val.Type().ConvertibleTo(f.Type()) {
-		f.Set(val.Convert(f.Type()))
-	} else {
-		return fmt.Errorf("type mismatch for field %s", field)
-	}
-	return nil
+type ResolveRequest struct {
+	// Values picked for the conflicting fields, nested as the conflicts, taken as custom values
+	Merged      map[messages_cameras.CamId]map[string]any         `json:"merged"`
+	MergedFaces map[messages_trapezoid.TrapezoidId]map[string]any `json:"mergedFaces"`
+	Resolutions []LeafResolution                                  `json:"resolutions" binding:"dive"`
+	// Message of the revision, defaults to revision.MessageResolve
+	Message string `json:"message"`
 }
 
-type ConflictMap map[CamProperty]any
-
-func threeWayMerge(base, main, workspace messages_cameras.CameraStruct) (messages_cameras.CameraStruct, ConflictMap) {
-	merged := base
-	conflicts := make(ConflictMap)
-
-	changesMain, _ := diff.Diff(base, main)
-	changesWork, _ := diff.Diff(base, workspace)
-
-	type pair struct{ main, work *diff.Change }
-	changeMap := make(map[string]pair)
-
-	for _, c := range changesMain {
-		p := strings.Join(c.Path, "\x1f") // Use Unit Separator to avoid dot collisions
-		changeMap[p] = pair{main: &c}
-	}
-	for _, c := range changesWork {
-		p := strings.Join(c.Path, "\x1f")
-		entry := changeMap[p]
-		entry.work = &c
-		changeMap[p] = entry
-	}
-
-	for _, p := range changeMap {
-		m, w := p.main, p.work
-
-		// 1. No conflict: Only Main changed
-		if w == nil {
-			applyChangeReflect(&merged, m)
-			continue
-		}
-		// 2. No conflict: Only Workspace changed
-		if m == nil {
-			applyChangeReflect(&merged, w)
-			continue
-		}
-		// 3. Potential conflict: Both changed
-		if reflect.DeepEqual(m.To, w.To) {
-			applyChangeReflect(&merged, m) // Both made the same change
-		} else {
-			// Actual conflict: Populate the nested map
-			buildConflictTree(conflicts, m.Path, m.From, m.To, w.To)
-		}
-	}
+// Optional body of a merge
+type MergeRequest struct {
+	// Message of the revision, defaults to revision.MessageMerge
+	Message string `json:"message"`
+}
 
-	return merged, conflicts
+// Merge of a workspace into main
+type workspaceMerge struct {
+	modelId uuid.UUID
+	// Owner of the workspace
+	ownerId uuid.UUID
+	// User changing main, credited with the revision
+	userId uuid.UUID
+	// NULL is the default workspace of the owner
+	branchId pgtype.UUID
+	// Merges of a merge request bypass the protection of main
+	mergeRequest bool
+	// Version of the workspace that was reviewed, the merge is refused once the workspace moved
+	version pgtype.Int4
+	// Runs in the transaction once main holds the changes of the workspace
+	onMerged func(queries *db_sqlc_gen.Queries, saved revision.Saved) error
 }
 
-func buildConflictTree(tree ConflictMap, path []string, b, m, w any) {
-	curr := tree
-	for i, step := range path {
-		key := CamProperty(step)
-		if i == len(path)-1 {
-			curr[key] = FieldConflict{Base: b, Main: m, Workspace: w}
-			return
-		}
-		if _, ok := curr[key]; !ok {
-			curr[key] = make(ConflictMap)
-		}
-		curr = curr[key].(ConflictMap)
+func (m workspaceMerge) merged(queries *db_sqlc_gen.Queries, saved revision.Saved) error {
+	if m.onMerged == nil {
+		return nil
 	}
+	return m.onMerged(queries, saved)
 }
 
-func mergeAllCameras(base, main, workspace messages_cameras.Cameras) (messages_cameras.Cameras, map[messages_cameras.CamId]map[CamProperty]interface{}) {
-	result := make(messages_cameras.Cameras)
-	conflicts := make(map[messages_cameras.CamId]map[CamProperty]interface{})
-
-	keys := map[messages_cameras.CamId]struct{}{}
-	for k := range base {
-		keys[k] = struct{}{}
-	}
-	for k := range main {
-		keys[k] = struct{}{}
+// Resolutions of the cameras and of the faces, the nested values first
+func (r ResolveRequest) byDocument(conflicts map[messages_cameras.CamId]merge.ConflictMap, faceConflicts map[messages_trapezoid.TrapezoidId]merge.ConflictMap) (map[messages_cameras.CamId][]merge.Resolution, map[messages_trapezoid.TrapezoidId][]merge.Resolution) {
+	cameras := make(map[messages_cameras.CamId][]merge.Resolution)
+	for id, values := range r.Merged {
+		cameras[id] = merge.Picked(conflicts[id], values)
 	}
-	for k := range workspace {
-		keys[k] = struct{}{}
+	faces := make(map[messages_trapezoid.TrapezoidId][]merge.Resolution)
+	for id, values := range r.MergedFaces {
+		faces[id] = merge.Picked(faceConflicts[id], values)
 	}
 
-	for id := range keys {
-		b, bok := base[id]
-		m, mok := main[id]
-		w, wok := workspace[id]
-
-		// new camera in workspace
-		if !bok && !mok && wok {
-			result[id] = w
+	for _, leaf := range r.Resolutions {
+		resolution := merge.Resolution{Path: leaf.Path, Take: leaf.Take, Value: leaf.Value}
+		if leaf.Document == history.KindFace {
+			id := messages_trapezoid.TrapezoidId(leaf.Id)
+			faces[id] = append(faces[id], resolution)
 			continue
 		}
-		// new camera in main
-		if !bok && mok && !wok {
-			result[id] = m
-			continue
-		}
-		// deleted in both
-		if !mok && !wok {
-			continue
-		}
-
-		// zero values if missing
-		if !bok {
-			b = messages_cameras.CameraStruct{}
-		}
-		if !mok {
-			m = messages_cameras.CameraStruct{}
-		}
-		if !wok {
-			w = messages_cameras.CameraStruct{}
-		}
-
-		merged, conflictOfId := threeWayMerge(b, m, w)
-		if len(conflictOfId) != 0 {
-			conflicts[messages_cameras.CamId(id)] = conflictOfId
-		}
-		result[id] = merged
-	}
-
-	return result, conflicts
-}
-
-type ResolveRequest struct {
-	Merged map[messages_cameras.CamId]map[CamProperty]any `json:"merged"`
-}
-
-func validateCamera(actualConflicts, merged map[CamProperty]any, depth uint8) error {
-	if depth >= MaxMergeDepth {
-		return fmt.Errorf("Invalid depth")
-	}
-	for key, conflict := range actualConflicts {
-		requestConflict, ok := merged[key]
-		if !ok {
-			return fmt.Errorf("Missing field %s", key)
-		}
-		castedRequest, ok := requestConflict.(map[CamProperty]any)
-		// If is leaf
-		if !ok {
-			if _, ok := conflict.(FieldConflict); !ok {
-				return fmt.Errorf("Not expecting leaf on %s", key)
-			}
-		} else {
-			castedActual, ok := conflict.(map[CamProperty]any)
-			if !ok {
-				return fmt.Errorf("Expecting leaf on %s", key)
-			}
-			if err := validateCamera(castedActual, castedRequest, depth+1); err != nil {
-				return err
-			}
-		}
+		id := messages_cameras.CamId(leaf.Id)
+		cameras[id] = append(cameras[id], resolution)
 	}
-	return nil
+	return cameras, faces
 }
 
-func validateResolve(actualConflicts, merged map[messages_cameras.CamId]map[CamProperty]any) error {
-	for key, actualConflict := range actualConflicts {
-		requestConflict, ok := merged[key]
-		if !ok {
-			return fmt.Errorf("Missing field %s", key)
+// Faces of the base of the workspace, of main and of the workspace, empty documents have none
+func unmarshalFaces(base, main, workspace []byte) (messages_trapezoid.Trapezoids, messages_trapezoid.Trapezoids, messages_trapezoid.Trapezoids, error) {
+	faces := make([]messages_trapezoid.Trapezoids, 3)
+	for i, doc := range [][]byte{base, main, workspace} {
+		faces[i] = make(messages_trapezoid.Trapezoids)
+		if len(doc) == 0 {
+			continue
 		}
-		if err := validateCamera(actualConflict, requestConflict, 0); err != nil {
-			return err
+		if err := json.Unmarshal(doc, &faces[i]); err != nil {
+			return nil, nil, nil, err
 		}
 	}
-	return nil
+	return faces[0], faces[1], faces[2], nil
 }
 
 func (t *WorkspaceRoute) postResolveWorkspaceMe(c *gin.Context) {
@@ -259,17 +140,29 @@ func (t *WorkspaceRoute) postResolveWorkspaceMe(c *gin.Context) {
 		return
 	}
 
+	t.resolveWorkspace(c, workspaceMerge{modelId: modelId, ownerId: userId, userId: userId})
+}
+
+// Saves the resolved merge of the workspace into main and deletes the workspace
+func (t *WorkspaceRoute) resolveWorkspace(c *gin.Context, m workspaceMerge) {
+	modelId, userId := m.modelId, m.userId
+
 	// Get workspace cams
 	workspaceData, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
-		Fields:  []string{"cameras"},
-		UserID:  userId,
-		ModelID: modelId,
+		Fields:      []string{"cameras", "base_cameras", "target_area_trapezoids", "base_target_area_trapezoids"},
+		UserID:      m.ownerId,
+		ModelID:     modelId,
+		WorkspaceID: m.branchId,
 	})
 	if err != nil {
-		t.Logger.Error("model not found", zap.Error(err))
+		t.Logger.Error("workspace not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{})
 		return
 	}
+	if m.version.Valid && workspaceData.Version != m.version.Int32 {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace changed since the review"})
+		return
+	}
 
 	if workspaceData.Version == workspaceData.BaseVersion {
 		c.Status(http.StatusOK)
@@ -295,84 +188,124 @@ func (t *WorkspaceRoute) postResolveWorkspaceMe(c *gin.Context) {
 		}
 	}
 
-	// Get  model cams
-	modelData, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
-		Fields: []string{"cameras"},
-		ID:     modelId,
-	})
+	// Validate request
+	var resolveRequest ResolveRequest
+	if err := c.ShouldBindJSON(&resolveRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := t.DB.Pool.Begin(c)
 	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
+	// Locked until the resolved merge is saved, the conflicts are those of the main it is saved to
+	modelData, err := queries.GetModelStateForUpdate(c, modelId)
+	if errors.Is(err, pgx.ErrNoRows) {
 		t.Logger.Error("model not found", zap.Error(err), zap.String("modelId", modelId.String()))
 		c.Status(http.StatusNotFound)
 		return
 	}
-
-	modelCameras, err := messages_cameras.UnmarshalCameras(modelData.Cameras)
 	if err != nil {
-		t.Logger.Error("error while unmarshalling workspace base cams", zap.Error(err))
+		t.Logger.Error("error while getting model", zap.Error(err), zap.String("modelId", modelId.String()))
 		c.Status(http.StatusInternalServerError)
 		return
 	}
+	if modelData.Protected && !m.mergeRequest {
+		c.JSON(http.StatusForbidden, gin.H{"error": protectedMessage})
+		return
+	}
 
-	// Validate request
-	var resolveRequest ResolveRequest
-	if err := c.ShouldBindJSON(&resolveRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	baseFaces, mainFaces, workspaceFaces, err := unmarshalFaces(workspaceData.BaseTargetAreaTrapezoids, modelData.TargetAreaTrapezoids, workspaceData.TargetAreaTrapezoids)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling faces", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	merged, conflicts := mergeAllCameras(baseCameras, modelCameras, workspaceCameras)
+	modelCameras, err := messages_cameras.UnmarshalCameras(modelData.Cameras)
+	if err != nil {
+		t.Logger.Error("error while unmarshalling model cams", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
 
-	if err := validateResolve(conflicts, resolveRequest.Merged); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	merged, conflicts, err := merge.Documents(baseCameras, modelCameras, workspaceCameras)
+	if err != nil {
+		t.Logger.Error("error while merging cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	mergedFaces, faceConflicts, err := merge.Documents(baseFaces, mainFaces, workspaceFaces)
+	if err != nil {
+		t.Logger.Error("error while merging faces", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
 	// Apply merge
-	for camId, mergedCam := range resolveRequest.Merged {
-		cam := merged[camId]
-
-		for key, value := range mergedCam {
-			// Use reflection here to set the field INSIDE the *Camera struct
-			val := reflect.ValueOf(value)
-			if val.Kind() == reflect.Map || val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
-				// skip nested types for now
-				continue
-			}
-
-			// Also apply to workspace cam
-			if !utils.SetFieldByJSONTag(&cam, string(key), val) {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "Invalid field",
-				})
-				return
-			}
-		}
-		merged[camId] = cam
+	cameraResolutions, faceResolutions := resolveRequest.byDocument(conflicts, faceConflicts)
+	if err := merge.ResolveDocuments(merged, conflicts, cameraResolutions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	tx, err := t.DB.Pool.Begin(c)
+	if err := merge.ResolveDocuments(mergedFaces, faceConflicts, faceResolutions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mergedEncoded, err := json.Marshal(merged)
 	if err != nil {
-		t.Logger.Error("error while creating transaction", zap.Error(err))
+		t.Logger.Error("error while marshalling merged cameras", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	mergedFacesEncoded, err := json.Marshal(mergedFaces)
+	if err != nil {
+		t.Logger.Error("error while marshalling merged faces", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
 
-	queries := t.DB.Queries.WithTx(tx)
-	queries.UpdateModelCams(c, db_sqlc_gen.UpdateModelCamsParams{
-		Value:   workspaceData.Cameras,
-		ModelID: modelId,
-	})
-	queries.UpdateModelCalibration(c, db_sqlc_gen.UpdateModelCalibrationParams{
-		ModelID:     modelId,
-		ScaleFactor: workspaceData.ScaleFactor,
-		ModelHeight: workspaceData.ModelHeight,
-	})
-	queries.DeleteWorkspace(c, db_sqlc_gen.DeleteWorkspaceParams{
-		UserID:  userId,
-		ModelID: modelId,
+	message := resolveRequest.Message
+	if message == "" {
+		message = revision.MessageResolve
+	}
+	saved, err := revision.Save(c, queries, modelId, userId, revision.Change{
+		Cameras: mergedEncoded,
+		Faces:   mergedFacesEncoded,
+		Calibration: &history.Calibration{
+			ScaleFactor: workspaceData.ScaleFactor,
+			ModelHeight: workspaceData.ModelHeight,
+		},
+		Message: message,
 	})
-	tx.Commit(c)
+	if err != nil {
+		t.Logger.Error("error while saving cameras to model", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if err := m.merged(queries, saved); err != nil {
+		t.Logger.Error("error while closing merged merge request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if err := queries.DeleteWorkspace(c, workspaceData.ID); err != nil {
+		t.Logger.Error("error while deleting resolved workspace", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		t.Logger.Error("error while committing resolved workspace", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
 
+	t.announceMainUpdate(c, modelId, workspaceData.ID, modelData.Version, saved.Version, modelData.Cameras, mergedEncoded)
 	c.Status(http.StatusOK)
 }
 
@@ -392,26 +325,66 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 		return
 	}
 
+	t.mergeWorkspace(c, workspaceMerge{modelId: modelId, ownerId: userId, userId: userId})
+}
+
+// Merges the workspace into main, answers with the conflicts when main moved since its base
+func (t *WorkspaceRoute) mergeWorkspace(c *gin.Context, m workspaceMerge) {
+	modelId, userId := m.modelId, m.userId
+
+	// The body is optional, an empty one merges with the default message
+	var mergeRequest MergeRequest
+	if err := c.ShouldBindJSON(&mergeRequest); err != nil && !errors.Is(err, io.EOF) {
+		t.Logger.Debug("error while validating body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merge request"})
+		return
+	}
+	message := mergeRequest.Message
+	if message == "" {
+		message = revision.MessageMerge
+	}
+
 	workspaceData, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
-		Fields:  []string{"cameras", "base_cameras"},
-		UserID:  userId,
-		ModelID: modelId,
+		Fields:      []string{"cameras", "base_cameras", "target_area_trapezoids", "base_target_area_trapezoids"},
+		UserID:      m.ownerId,
+		ModelID:     modelId,
+		WorkspaceID: m.branchId,
 	})
 	if err != nil {
 		t.Logger.Error("model not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{})
 		return
 	}
+	if m.version.Valid && workspaceData.Version != m.version.Int32 {
+		c.JSON(http.StatusConflict, gin.H{"error": "workspace changed since the review"})
+		return
+	}
 
-	modelData, err := t.DB.Queries.GetModelByID(c, db_sqlc_gen.GetModelByIDParams{
-		Fields: []string{"cameras"},
-		ID:     modelId,
-	})
+	tx, err := t.DB.Pool.Begin(c)
 	if err != nil {
+		t.Logger.Error("error while creating transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	defer tx.Rollback(c)
+	queries := t.DB.Queries.WithTx(tx)
+
+	// Locked until the merge is saved, a concurrent merge waits and merges against this one
+	modelData, err := queries.GetModelStateForUpdate(c, modelId)
+	if errors.Is(err, pgx.ErrNoRows) {
 		t.Logger.Error("model not found", zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{})
 		return
 	}
+	if err != nil {
+		t.Logger.Error("error while getting model", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	if modelData.Protected && !m.mergeRequest {
+		c.JSON(http.StatusForbidden, gin.H{"error": protectedMessage})
+		return
+	}
 
 	calibrationChanged := workspaceData.ScaleFactor != modelData.ScaleFactor ||
 		workspaceData.ModelHeight != modelData.ModelHeight
@@ -423,25 +396,32 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 		return
 	}
 
-	saveCalibrationToModel := func() error {
-		if !calibrationChanged {
-			return nil // skip if nothing to save
-		}
-		_, err := t.DB.Queries.UpdateModelCalibration(c, db_sqlc_gen.UpdateModelCalibrationParams{
-			ModelID:     modelId,
+	var calibration *history.Calibration
+	if calibrationChanged {
+		calibration = &history.Calibration{
 			ScaleFactor: workspaceData.ScaleFactor,
 			ModelHeight: workspaceData.ModelHeight,
-		})
-		return err
+		}
 	}
 
 	// Only calibration changed, no camera changes
 	if !camerasChanged && calibrationChanged {
-		if err := saveCalibrationToModel(); err != nil {
+		saved, err := revision.Save(c, queries, modelId, userId, revision.Change{Calibration: calibration, Message: message})
+		if err != nil {
 			t.Logger.Error("error saving calibration to model", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
+		if err := m.merged(queries, saved); err != nil {
+			t.Logger.Error("error while closing merged merge request", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		if err := tx.Commit(c); err != nil {
+			t.Logger.Error("error while committing merged workspace", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"noChanges":          false,
 			"calibrationChanged": true,
@@ -452,32 +432,44 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 
 	switch cmp.Compare(modelData.Version, workspaceData.BaseVersion) {
 	case 0:
-		newVersion, err := t.DB.Queries.UpdateModelCams(c, db_sqlc_gen.UpdateModelCamsParams{
-			Value:   workspaceData.Cameras,
-			ModelID: modelId,
+		saved, err := revision.Save(c, queries, modelId, userId, revision.Change{
+			Cameras:     workspaceData.Cameras,
+			Faces:       workspaceData.TargetAreaTrapezoids,
+			Calibration: calibration,
+			Message:     message,
 		})
 		if err != nil {
 			t.Logger.Error("error while saving cameras to model", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
-		if err := saveCalibrationToModel(); err != nil {
-			t.Logger.Error("error saving calibration to model", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{})
-			return
-		}
-		err = t.DB.Queries.UpdateSetWorkspaceCams(c, db_sqlc_gen.UpdateSetWorkspaceCamsParams{
-			Cameras:     workspaceData.Cameras,
-			BaseCameras: workspaceData.Cameras,
-			BaseVersion: newVersion,
-			UserID:      userId,
-			ModelID:     modelId,
+		err = queries.UpdateSetWorkspaceLayout(c, db_sqlc_gen.UpdateSetWorkspaceLayoutParams{
+			Cameras:              workspaceData.Cameras,
+			TargetAreaTrapezoids: workspaceData.TargetAreaTrapezoids,
+			BaseVersion:          saved.Version,
+			WorkspaceID:          workspaceData.ID,
 		})
 		if err != nil {
 			t.Logger.Error("error while updating workspace base version", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
+		if err := history.Clear(c, queries, workspaceData.ID); err != nil {
+			t.Logger.Error("error while clearing workspace history", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		if err := m.merged(queries, saved); err != nil {
+			t.Logger.Error("error while closing merged merge request", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		if err := tx.Commit(c); err != nil {
+			t.Logger.Error("error while committing merged workspace", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		t.announceMainUpdate(c, modelId, workspaceData.ID, modelData.Version, saved.Version, modelData.Cameras, workspaceData.Cameras)
 		c.JSON(http.StatusOK, gin.H{
 			"noChanges":          false,
 			"calibrationChanged": calibrationChanged,
@@ -508,7 +500,19 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 			return
 		}
 
-		merged, conflicts := mergeAllCameras(baseCameras, mainCameras, workspaceCameras)
+		baseFaces, mainFaces, workspaceFaces, err := unmarshalFaces(workspaceData.BaseTargetAreaTrapezoids, modelData.TargetAreaTrapezoids, workspaceData.TargetAreaTrapezoids)
+		if err != nil {
+			t.Logger.Error("error while unmarshalling faces", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+
+		merged, conflicts, err := merge.Documents(baseCameras, mainCameras, workspaceCameras)
+		if err != nil {
+			t.Logger.Error("error while merging cameras", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
 		mergedEncoded, err := json.Marshal(merged)
 		if err != nil {
 			t.Logger.Error("error while marshalling merged cameras", zap.Error(err))
@@ -516,33 +520,58 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 			return
 		}
 
-		if len(conflicts) == 0 {
-			base_version, err := t.DB.Queries.UpdateModelCams(c, db_sqlc_gen.UpdateModelCamsParams{
-				Value:   mergedEncoded,
-				ModelID: modelId,
+		mergedFaces, faceConflicts, err := merge.Documents(baseFaces, mainFaces, workspaceFaces)
+		if err != nil {
+			t.Logger.Error("error while merging faces", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		mergedFacesEncoded, err := json.Marshal(mergedFaces)
+		if err != nil {
+			t.Logger.Error("error while marshalling merged faces", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+
+		if len(conflicts) == 0 && len(faceConflicts) == 0 {
+			saved, err := revision.Save(c, queries, modelId, userId, revision.Change{
+				Cameras:     mergedEncoded,
+				Faces:       mergedFacesEncoded,
+				Calibration: calibration,
+				Message:     message,
 			})
 			if err != nil {
 				t.Logger.Error("error while saving merged workspace into model", zap.Error(err))
 				c.JSON(http.StatusInternalServerError, gin.H{})
 				return
 			}
-			if err := saveCalibrationToModel(); err != nil {
-				t.Logger.Error("error saving calibration to model", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{})
-				return
-			}
-			err = t.DB.Queries.UpdateSetWorkspaceCams(c, db_sqlc_gen.UpdateSetWorkspaceCamsParams{
-				Cameras:     mergedEncoded,
-				BaseCameras: mergedEncoded,
-				BaseVersion: base_version,
-				UserID:      userId,
-				ModelID:     modelId,
+			err = queries.UpdateSetWorkspaceLayout(c, db_sqlc_gen.UpdateSetWorkspaceLayoutParams{
+				Cameras:              mergedEncoded,
+				TargetAreaTrapezoids: mergedFacesEncoded,
+				BaseVersion:          saved.Version,
+				WorkspaceID:          workspaceData.ID,
 			})
 			if err != nil {
 				t.Logger.Error("error while saving merged workspace into model", zap.Error(err))
 				c.JSON(http.StatusInternalServerError, gin.H{})
 				return
 			}
+			if err := history.Clear(c, queries, workspaceData.ID); err != nil {
+				t.Logger.Error("error while clearing workspace history", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{})
+				return
+			}
+			if err := m.merged(queries, saved); err != nil {
+				t.Logger.Error("error while closing merged merge request", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{})
+				return
+			}
+			if err := tx.Commit(c); err != nil {
+				t.Logger.Error("error while committing merged workspace", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{})
+				return
+			}
+			t.announceMainUpdate(c, modelId, workspaceData.ID, modelData.Version, saved.Version, modelData.Cameras, mergedEncoded)
 			c.JSON(http.StatusOK, gin.H{
 				"noChanges":          false,
 				"calibrationChanged": calibrationChanged,
@@ -551,15 +580,13 @@ func (t *WorkspaceRoute) postMergeWorkspace(c *gin.Context) {
 			return
 		}
 
-		// Has camera conflicts — save calibration immediately (last-write-wins)
-		if err := saveCalibrationToModel(); err != nil {
-			t.Logger.Error("error saving calibration to model", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{})
-			return
-		}
+		// Has camera or face conflicts, nothing is saved: the calibration is saved with the
+		// resolved merge
 		c.JSON(http.StatusOK, gin.H{
 			"merged":             merged,
 			"conflicts":          conflicts,
+			"mergedFaces":        mergedFaces,
+			"faceConflicts":      faceConflicts,
 			"calibrationChanged": calibrationChanged,
 			"camerasChanged":     true,
 		})
@@ -589,10 +616,21 @@ func (t *WorkspaceRoute) deleteWorkspaceMe(c *gin.Context) {
 		return
 	}
 
-	err = t.DB.Queries.DeleteWorkspace(c, db_sqlc_gen.DeleteWorkspaceParams{
+	workspace, err := t.DB.Queries.GetWorkspaceByID(c, db_sqlc_gen.GetWorkspaceByIDParams{
 		UserID:  userId,
 		ModelID: modelId,
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		t.Logger.Error("error while getting workspace", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+
+	err = t.DB.Queries.DeleteWorkspace(c, workspace.ID)
 
 	if err != nil {
 		t.Logger.Error("error while deleting workspace", zap.Error(err))
@@ -600,6 +638,7 @@ func (t *WorkspaceRoute) deleteWorkspaceMe(c *gin.Context) {
 		return
 	}
 
+	t.Presence.CloseWorkspace(c, modelId, workspace.ID, deletedReason)
 	c.Status(http.StatusNoContent)
 }
 
@@ -622,6 +661,7 @@ func (t *WorkspaceRoute) postWorkspaceMe(c *gin.Context) {
 	workspace, err := t.DB.Queries.CreateWorkspace(c, db_sqlc_gen.CreateWorkspaceParams{
 		UserID:  userId,
 		ModelID: modelId,
+		Name:    DefaultBranch,
 	})
 
 	if err != nil {
@@ -746,8 +786,27 @@ func (t *WorkspaceRoute) InitRoute(router gin.IRouter) gin.IRouter {
 	router.GET("/projects/:projectId/models/:modelId/workspaces/me", t.getWorkspaceMe)
 	router.POST("/projects/:projectId/models/:modelId/workspaces/me", t.postWorkspaceMe)
 	router.DELETE("/projects/:projectId/models/:modelId/workspaces/me", t.deleteWorkspaceMe)
+	router.GET("/projects/:projectId/models/:modelId/workspaces/me/history", t.getWorkspaceMeHistory)
 
 	router.POST("/projects/:projectId/models/:modelId/workspaces/me/resolve", t.postResolveWorkspaceMe)
 	router.POST("/projects/:projectId/models/:modelId/workspaces/me/merge", t.postMergeWorkspace)
+
+	router.GET("/projects/:projectId/models/:modelId/branches", t.getBranches)
+	router.POST("/projects/:projectId/models/:modelId/branches", t.postBranch)
+	router.PATCH("/projects/:projectId/models/:modelId/branches/:branchId", t.patchBranch)
+	router.DELETE("/projects/:projectId/models/:modelId/branches/:branchId", t.deleteBranch)
+	router.POST("/projects/:projectId/models/:modelId/branches/:branchId/resolve", t.postResolveBranch)
+	router.POST("/projects/:projectId/models/:modelId/branches/:branchId/merge", t.postMergeBranch)
+
+	router.GET("/projects/:projectId/models/:modelId/merge-requests", t.getMergeRequests)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests", t.postMergeRequest)
+	router.GET("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId", t.getMergeRequest)
+	router.PATCH("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId", t.patchMergeRequest)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/comments", t.postMergeRequestComment)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/approval", t.postMergeRequestApproval)
+	router.DELETE("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/approval", t.deleteMergeRequestApproval)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/close", t.postCloseMergeRequest)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/merge", t.postMergeMergeRequest)
+	router.POST("/projects/:projectId/models/:modelId/merge-requests/:mergeRequestId/resolve", t.postResolveMergeRequest)
 	return router
 }
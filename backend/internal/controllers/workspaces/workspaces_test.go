@@ -19,13 +19,16 @@ import (
 	"go.uber.org/zap"
 	config_env "omnicam.com/backend/config"
 	controller_workspaces "omnicam.com/backend/internal/controllers/workspaces"
+	"omnicam.com/backend/internal/history"
 	"omnicam.com/backend/internal/middleware"
+	"omnicam.com/backend/internal/presence"
 	"omnicam.com/backend/internal/testutils"
 	"omnicam.com/backend/internal/utils"
 
 	db_client "omnicam.com/backend/pkg/db"
 	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
 	"omnicam.com/backend/pkg/logger"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
 )
 
 var testLogger = logger.InitLogger(true)
@@ -113,9 +116,10 @@ func setupTest(t *testing.T, source string) *testContext {
 	protected.Use(authMiddleware.CreateHandler())
 
 	route := controller_workspaces.WorkspaceRoute{
-		Logger: testcaseLogger,
-		Env:    env,
-		DB:     db,
+		Logger:   testcaseLogger,
+		Env:      env,
+		DB:       db,
+		Presence: presence.NewHub(testcaseLogger, nil),
 	}
 	route.InitRoute(protected)
 
@@ -270,6 +274,30 @@ func TestWorkspacesMe(t *testing.T) {
 	}
 }
 
+// Saves a camera the way the autosave of the camera websocket does, returns the new version
+func autosaveCamera(t *testing.T, tc *testContext, workspaceId uuid.UUID, version int32, cameraId string, value string) int32 {
+	t.Helper()
+	tx, err := tc.DB.Pool.Begin(tc.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(tc.Ctx)
+	queries := tc.DB.Queries.WithTx(tx)
+
+	newVersion, err := queries.BumpWorkspaceVersion(tc.Ctx, db_sqlc_gen.BumpWorkspaceVersionParams{
+		WorkspaceID: workspaceId,
+		Version:     version,
+	})
+	require.NoError(t, err)
+	log, err := history.Open(tc.Ctx, queries, workspaceId)
+	require.NoError(t, err)
+	applied, err := log.Apply(tc.Ctx, history.Event{Kind: history.KindCamera, TargetId: cameraId, After: []byte(value)})
+	require.NoError(t, err)
+	_, err = log.RecordEdit(tc.Ctx, newVersion, []history.Event{applied})
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Commit(tc.Ctx))
+	return newVersion
+}
+
 func TestPostMergeWorkspace(t *testing.T) {
 	tests := []struct {
 		name string
@@ -326,6 +354,7 @@ func TestPostMergeWorkspace(t *testing.T) {
 				_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
 				})
 
 				req, _ := http.NewRequest("POST",
@@ -352,17 +381,17 @@ func TestPostMergeWorkspace(t *testing.T) {
 
 				// Create model and workspace with equal baseVersion
 
-				_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"123"},
-					Value:   []byte("{}"),
-					UserID:  tc.User.ID,
-					ModelID: tc.Model1,
+					Key:         []string{"123"},
+					Value:       []byte("{}"),
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
@@ -395,25 +424,78 @@ func TestPostMergeWorkspace(t *testing.T) {
 				require.NoError(t, err)
 
 				// Create model and workspace with equal baseVersion
-				_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"123"},
-					Value:   []byte(`{"posX":10}`),
-					UserID:  tc.User.ID,
-					ModelID: tc.Model1,
+					Key:         []string{"123"},
+					Value:       []byte(`{"posX":10}`),
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"456"},
-					Value:   []byte(`{"posX":10}`),
+					Key:         []string{"456"},
+					Value:       []byte(`{"posX":10}`),
+					WorkspaceID: workspace.ID,
+				})
+				require.NoError(t, err)
+
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					Value:   []byte(`{"123":{"posX":12}}`),
+					ModelID: tc.Model1,
+				})
+				require.NoError(t, err)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/merge", projectIdBase64, modelIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Contains(t, w.Body.String(), `"conflicts":{"123":{"posX":{"base":1,"main":12,"workspace":10}}}`)
+			},
+		},
+		{
+			name: "Merge with conflicts saves nothing, the calibration included",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+
+				_, err := tc.DB.Queries.AddUserToProject(tc.Ctx, db_sqlc_gen.AddUserToProjectParams{
+					UserID: tc.User.ID, ProjectID: tc.Project1, Role: db_sqlc_gen.RoleCollaborator,
+				})
+				require.NoError(t, err)
+
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					Value:   []byte(`{"123":{"posX":1}}`),
+					ModelID: tc.Model1,
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
+				})
+				require.NoError(t, err)
+
+				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
+					Key:         []string{"123"},
+					Value:       []byte(`{"posX":10}`),
+					WorkspaceID: workspace.ID,
+				})
+				require.NoError(t, err)
+				_, err = tc.DB.Queries.UpdateWorkspaceCalibration(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCalibrationParams{
+					ScaleFactor: 2,
+					ModelHeight: 3,
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
@@ -423,6 +505,11 @@ func TestPostMergeWorkspace(t *testing.T) {
 				})
 				require.NoError(t, err)
 
+				before, err := tc.DB.Queries.GetModelByID(tc.Ctx, db_sqlc_gen.GetModelByIDParams{ID: tc.Model1})
+				require.NoError(t, err)
+				revisions, err := tc.DB.Queries.CountModelRevisions(tc.Ctx, tc.Model1)
+				require.NoError(t, err)
+
 				req, _ := http.NewRequest("POST",
 					fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/merge", projectIdBase64, modelIdBase64),
 					nil)
@@ -431,7 +518,74 @@ func TestPostMergeWorkspace(t *testing.T) {
 				tc.Router.ServeHTTP(w, req)
 
 				require.Equal(t, http.StatusOK, w.Code)
-				require.Contains(t, w.Body.String(), `"conflicts":{"123":{"posX":{"base":1,"main":12,"workspace":10}}}`)
+				require.Contains(t, w.Body.String(), `"conflicts":{"123":`)
+
+				after, err := tc.DB.Queries.GetModelByID(tc.Ctx, db_sqlc_gen.GetModelByIDParams{ID: tc.Model1})
+				require.NoError(t, err)
+				require.Equal(t, before.ScaleFactor, after.ScaleFactor)
+				require.Equal(t, before.ModelHeight, after.ModelHeight)
+				count, err := tc.DB.Queries.CountModelRevisions(tc.Ctx, tc.Model1)
+				require.NoError(t, err)
+				require.Equal(t, revisions, count)
+			},
+		},
+		{
+			name: "Autosave and undo after a merge only see the edits since the merge",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+
+				_, err := tc.DB.Queries.AddUserToProject(tc.Ctx, db_sqlc_gen.AddUserToProjectParams{
+					UserID: tc.User.ID, ProjectID: tc.Project1, Role: db_sqlc_gen.RoleCollaborator,
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
+					ModelID: tc.Model1,
+					Name:    "default",
+				})
+				require.NoError(t, err)
+
+				// Two edits move the workspace two versions ahead of the version main gets
+				version := autosaveCamera(t, tc, workspace.ID, workspace.Version, "123", `{"posX":1}`)
+				version = autosaveCamera(t, tc, workspace.ID, version, "123", `{"posX":2}`)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/merge", projectIdBase64, modelIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Contains(t, w.Body.String(), `"noChanges":false`)
+
+				stacks, err := history.LatestStacks(tc.Ctx, tc.DB.Queries, workspace.ID)
+				require.NoError(t, err)
+				require.False(t, stacks.CanUndo())
+
+				merged, err := tc.DB.Queries.GetWorkspaceLayout(tc.Ctx, workspace.ID)
+				require.NoError(t, err)
+				version = autosaveCamera(t, tc, workspace.ID, merged.Version, "123", `{"posX":3}`)
+
+				tx, err := tc.DB.Pool.Begin(tc.Ctx)
+				require.NoError(t, err)
+				defer tx.Rollback(tc.Ctx)
+				queries := tc.DB.Queries.WithTx(tx)
+				undoVersion, err := queries.BumpWorkspaceVersion(tc.Ctx, db_sqlc_gen.BumpWorkspaceVersionParams{
+					WorkspaceID: workspace.ID,
+					Version:     version,
+				})
+				require.NoError(t, err)
+				log, err := history.Open(tc.Ctx, queries, workspace.ID)
+				require.NoError(t, err)
+				events, stacks, err := log.Undo(tc.Ctx, undoVersion)
+				require.NoError(t, err)
+				require.NoError(t, tx.Commit(tc.Ctx))
+
+				require.Len(t, events, 1)
+				require.JSONEq(t, `{"posX":2}`, string(events[0].After))
+				require.False(t, stacks.CanUndo())
 			},
 		},
 		// {
@@ -448,6 +602,7 @@ func TestPostMergeWorkspace(t *testing.T) {
 		// 		_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 		// 			UserID:  tc.User.ID,
 		// 			ModelID: tc.Model1,
+		// 			Name:    "default",
 		// 		})
 		// 		require.NoError(t, err)
 
@@ -525,17 +680,17 @@ func TestPostResolveWorkspaceMe(t *testing.T) {
 				// userIdBase64, _ := utils.UuidToBase64(tc.User.ID)
 
 				// Create workspace and model
-				_, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"123"},
-					Value:   []byte("{}"),
-					UserID:  tc.User.ID,
-					ModelID: tc.Model1,
+					Key:         []string{"123"},
+					Value:       []byte("{}"),
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
@@ -573,17 +728,17 @@ func TestPostResolveWorkspaceMe(t *testing.T) {
 				})
 				require.NoError(t, err)
 
-				_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
 					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
+					Name:    "default",
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"123"},
-					Value:   workspaceCams,
-					UserID:  tc.User.ID,
-					ModelID: tc.Model1,
+					Key:         []string{"123"},
+					Value:       workspaceCams,
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
@@ -601,6 +756,126 @@ func TestPostResolveWorkspaceMe(t *testing.T) {
 				require.Equal(t, http.StatusOK, w.Code)
 			},
 		},
+		{
+			name: "Nested conflicts resolved by path returns 200",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, err := utils.UuidToBase64(tc.Project1)
+				require.Nil(t, err)
+				modelIdBase64, err := utils.UuidToBase64(tc.Model1)
+				require.Nil(t, err)
+
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					ModelID: tc.Model1,
+					Value:   []byte(`{"123":{"frustumColor":{"r":1,"g":1}}}`),
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
+					ModelID: tc.Model1,
+					Name:    "default",
+				})
+				require.NoError(t, err)
+
+				// Main and the workspace both change the same leaf after the workspace was created
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					ModelID: tc.Model1,
+					Value:   []byte(`{"123":{"frustumColor":{"r":0.75,"g":1}}}`),
+				})
+				require.NoError(t, err)
+				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
+					Key:         []string{"123"},
+					Value:       []byte(`{"frustumColor":{"r":0.5,"g":1}}`),
+					WorkspaceID: workspace.ID,
+				})
+				require.NoError(t, err)
+
+				resolve := func(body string) *httptest.ResponseRecorder {
+					req, _ := http.NewRequest("POST",
+						fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/resolve",
+							projectIdBase64, modelIdBase64),
+						strings.NewReader(body))
+					req.Header.Set("Content-Type", "application/json")
+					req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+
+					w := httptest.NewRecorder()
+					tc.Router.ServeHTTP(w, req)
+					return w
+				}
+
+				// The conflict is real, it can't be resolved without picking a side
+				w := resolve(`{"resolutions":[]}`)
+				require.Equal(t, http.StatusBadRequest, w.Code)
+
+				w = resolve(`{"resolutions":[{"document":"camera","id":"123","path":["frustumColor","r"],"take":"workspace"}]}`)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				model, err := tc.DB.Queries.GetModelByID(tc.Ctx, db_sqlc_gen.GetModelByIDParams{
+					Fields: []string{"cameras"},
+					ID:     tc.Model1,
+				})
+				require.NoError(t, err)
+				require.Contains(t, string(model.Cameras), `"r": 0.5`)
+			},
+		},
+		{
+			name: "Camera deleted in the workspace stays deleted returns 200",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, err := utils.UuidToBase64(tc.Project1)
+				require.Nil(t, err)
+				modelIdBase64, err := utils.UuidToBase64(tc.Model1)
+				require.Nil(t, err)
+
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					ModelID: tc.Model1,
+					Value:   []byte(`{"kept":{"angleX":1},"deleted":{"angleX":1}}`),
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
+					ModelID: tc.Model1,
+					Name:    "default",
+				})
+				require.NoError(t, err)
+
+				// Main moves another camera, the workspace deletes one it didn't touch
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+					ModelID: tc.Model1,
+					Value:   []byte(`{"kept":{"angleX":2},"deleted":{"angleX":1}}`),
+				})
+				require.NoError(t, err)
+				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
+					Key:         []string{"deleted"},
+					Value:       nil,
+					WorkspaceID: workspace.ID,
+				})
+				require.NoError(t, err)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/resolve",
+						projectIdBase64, modelIdBase64),
+					strings.NewReader(`{"resolutions":[]}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusOK, w.Code)
+
+				model, err := tc.DB.Queries.GetModelByID(tc.Ctx, db_sqlc_gen.GetModelByIDParams{
+					Fields: []string{"cameras"},
+					ID:     tc.Model1,
+				})
+				require.NoError(t, err)
+				cameras, err := messages_cameras.UnmarshalCameras(model.Cameras)
+				require.NoError(t, err)
+				require.Contains(t, cameras, messages_cameras.CamId("kept"))
+				require.Equal(t, 2.0, cameras["kept"].AngleX)
+				require.NotContains(t, cameras, messages_cameras.CamId("deleted"))
+			},
+		},
 		{
 			name: "Unresolved conflicts returns 400",
 			body: `{"merged":{"CameraA":{"Field1":"differentValue"}}}`,
@@ -612,23 +887,24 @@ func TestPostResolveWorkspaceMe(t *testing.T) {
 				modelCams := []byte(`{"CameraA":{"angleX":1}}`)
 				workspaceCams := []byte(`{"angleX":2}`)
 
-				_, err := tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
 					ModelID: tc.Model1,
-					Value:   modelCams,
+					Name:    "default",
 				})
 				require.NoError(t, err)
 
-				_, err = tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
-					UserID:  tc.User.ID,
+				// Main and the workspace both add the camera after the workspace was created
+				_, err = tc.DB.Queries.UpdateModelCams(tc.Ctx, db_sqlc_gen.UpdateModelCamsParams{
 					ModelID: tc.Model1,
+					Value:   modelCams,
 				})
 				require.NoError(t, err)
 
 				_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
-					Key:     []string{"CameraA"},
-					Value:   workspaceCams,
-					UserID:  tc.User.ID,
-					ModelID: tc.Model1,
+					Key:         []string{"CameraA"},
+					Value:       workspaceCams,
+					WorkspaceID: workspace.ID,
 				})
 				require.NoError(t, err)
 
@@ -655,3 +931,215 @@ func TestPostResolveWorkspaceMe(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteBranch(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, tc *testContext)
+	}{
+		{
+			name: "Default branch can't be deleted",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+
+				_, err := tc.DB.Queries.AddUserToProject(tc.Ctx, db_sqlc_gen.AddUserToProjectParams{
+					UserID: tc.User.ID, ProjectID: tc.Project1, Role: db_sqlc_gen.RoleCollaborator,
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
+					ModelID: tc.Model1,
+					Name:    "default",
+				})
+				require.NoError(t, err)
+				branchIdBase64, _ := utils.UuidToBase64(workspace.ID)
+
+				req, _ := http.NewRequest("DELETE",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/branches/%s", projectIdBase64, modelIdBase64, branchIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusBadRequest, w.Code)
+				require.Contains(t, w.Body.String(), "the default branch can't be deleted")
+			},
+		},
+		{
+			name: "Other branch is deleted",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+
+				_, err := tc.DB.Queries.AddUserToProject(tc.Ctx, db_sqlc_gen.AddUserToProjectParams{
+					UserID: tc.User.ID, ProjectID: tc.Project1, Role: db_sqlc_gen.RoleCollaborator,
+				})
+				require.NoError(t, err)
+
+				workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+					UserID:  tc.User.ID,
+					ModelID: tc.Model1,
+					Name:    "lobby",
+				})
+				require.NoError(t, err)
+				branchIdBase64, _ := utils.UuidToBase64(workspace.ID)
+
+				req, _ := http.NewRequest("DELETE",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/branches/%s", projectIdBase64, modelIdBase64, branchIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusNoContent, w.Code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tc := setupTest(t, tt.name)
+			tt.run(t, tc)
+		})
+	}
+}
+
+// Opens a merge request from a changed default workspace, returns its base64 id
+func openMergeRequest(t *testing.T, tc *testContext, role db_sqlc_gen.Role) string {
+	t.Helper()
+	projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+	modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+
+	_, err := tc.DB.Queries.AddUserToProject(tc.Ctx, db_sqlc_gen.AddUserToProjectParams{
+		UserID: tc.User.ID, ProjectID: tc.Project1, Role: role,
+	})
+	require.NoError(t, err)
+
+	workspace, err := tc.DB.Queries.CreateWorkspace(tc.Ctx, db_sqlc_gen.CreateWorkspaceParams{
+		UserID:  tc.User.ID,
+		ModelID: tc.Model1,
+		Name:    "default",
+	})
+	require.NoError(t, err)
+
+	_, err = tc.DB.Queries.UpdateWorkspaceCams(tc.Ctx, db_sqlc_gen.UpdateWorkspaceCamsParams{
+		Key:         []string{"123"},
+		Value:       []byte(`{"posX":10}`),
+		WorkspaceID: workspace.ID,
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST",
+		fmt.Sprintf("/api/v1/projects/%s/models/%s/merge-requests", projectIdBase64, modelIdBase64),
+		strings.NewReader(`{"description":"add camera 123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+	w := httptest.NewRecorder()
+	tc.Router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var body struct {
+		Data struct {
+			Id uuid.UUID `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	mergeRequestIdBase64, err := utils.UuidToBase64(body.Data.Id)
+	require.NoError(t, err)
+	return mergeRequestIdBase64
+}
+
+func TestMergeRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, tc *testContext)
+	}{
+		{
+			name: "Protected main refuses direct merges",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+				openMergeRequest(t, tc, db_sqlc_gen.RoleCollaborator)
+
+				_, err := tc.DB.Queries.SetModelProtection(tc.Ctx, db_sqlc_gen.SetModelProtectionParams{
+					Protected: true,
+					ModelID:   tc.Model1,
+					ProjectID: tc.Project1,
+				})
+				require.NoError(t, err)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/workspaces/me/merge", projectIdBase64, modelIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusForbidden, w.Code)
+			},
+		},
+		{
+			name: "Collaborator can't apply a merge request",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+				mergeRequestIdBase64 := openMergeRequest(t, tc, db_sqlc_gen.RoleCollaborator)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/merge-requests/%s/merge", projectIdBase64, modelIdBase64, mergeRequestIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusForbidden, w.Code)
+			},
+		},
+		{
+			name: "Author can't approve their own merge request",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+				mergeRequestIdBase64 := openMergeRequest(t, tc, db_sqlc_gen.RoleOwner)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/merge-requests/%s/approval", projectIdBase64, modelIdBase64, mergeRequestIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusForbidden, w.Code)
+			},
+		},
+		{
+			name: "Unapproved merge request isn't applied",
+			run: func(t *testing.T, tc *testContext) {
+				projectIdBase64, _ := utils.UuidToBase64(tc.Project1)
+				modelIdBase64, _ := utils.UuidToBase64(tc.Model1)
+				mergeRequestIdBase64 := openMergeRequest(t, tc, db_sqlc_gen.RoleOwner)
+
+				req, _ := http.NewRequest("POST",
+					fmt.Sprintf("/api/v1/projects/%s/models/%s/merge-requests/%s/merge", projectIdBase64, modelIdBase64, mergeRequestIdBase64),
+					nil)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.Token})
+				w := httptest.NewRecorder()
+				tc.Router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusConflict, w.Code)
+				require.Contains(t, w.Body.String(), "isn't approved")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tc := setupTest(t, tt.name)
+			tt.run(t, tc)
+		})
+	}
+}
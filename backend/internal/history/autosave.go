@@ -0,0 +1,56 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+	messsages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
+)
+
+// Converts a change of the history back to the event the client applies
+func AutosaveEvent(e Event) (*protobufs.AutosaveEvent, error) {
+	switch e.Kind {
+	case KindCamera:
+		if e.After == nil {
+			return &protobufs.AutosaveEvent{Event: &protobufs.AutosaveEvent_Delete{
+				Delete: &protobufs.CameraDeleteEvent{Id: e.TargetId},
+			}}, nil
+		}
+		cam, err := messages_cameras.UnmarshalCamera(e.After)
+		if err != nil {
+			return nil, err
+		}
+		return &protobufs.AutosaveEvent{Event: &protobufs.AutosaveEvent_Upsert{
+			Upsert: &protobufs.CameraUpsertEvent{Camera: messages_cameras.CamToProtoCam(e.TargetId, cam)},
+		}}, nil
+	case KindFace:
+		if e.After == nil {
+			return &protobufs.AutosaveEvent{Event: &protobufs.AutosaveEvent_FaceDelete{
+				FaceDelete: &protobufs.FacesDeleteEvent{Id: e.TargetId},
+			}}, nil
+		}
+		var face messsages_trapezoids.TrapezoidStruct
+		if err := json.Unmarshal(e.After, &face); err != nil {
+			return nil, err
+		}
+		face.ID = e.TargetId
+		return &protobufs.AutosaveEvent{Event: &protobufs.AutosaveEvent_FaceUpsert{
+			FaceUpsert: &protobufs.FacesUpsertEvent{CoverageFace: messsages_trapezoids.TrapezoidToProtoTrapezoid(face)},
+		}}, nil
+	case KindCalibration:
+		var calibration Calibration
+		if err := json.Unmarshal(e.After, &calibration); err != nil {
+			return nil, err
+		}
+		return &protobufs.AutosaveEvent{Event: &protobufs.AutosaveEvent_Calibrate{
+			Calibrate: &protobufs.CalibrationUpdateEvent{
+				ScaleFactor: calibration.ScaleFactor,
+				ModelHeight: calibration.ModelHeight,
+			},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidEvent, e.Kind)
+	}
+}
@@ -0,0 +1,336 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+// Targets of the events
+const (
+	KindCamera      = "camera"
+	KindFace        = "face"
+	KindCalibration = "calibration"
+)
+
+// Actions of the steps
+const (
+	ActionEdit = "edit"
+	ActionUndo = "undo"
+	ActionRedo = "redo"
+)
+
+var (
+	ErrInvalidEvent = errors.New("invalid history event")
+	// A target of the step changed outside of the history since, e.g. through a merge
+	ErrConflict = errors.New("workspace changed since the step")
+)
+
+// Value of the calibration events
+type Calibration struct {
+	ScaleFactor float64 `json:"scaleFactor"`
+	ModelHeight float64 `json:"modelHeight"`
+}
+
+// Change of a camera, a face or the calibration. Before is nil when the target was created and
+// After when it was deleted.
+type Event struct {
+	Kind     string
+	TargetId string
+	Before   json.RawMessage
+	After    json.RawMessage
+}
+
+func (e Event) Inverse() Event {
+	return Event{
+		Kind:     e.Kind,
+		TargetId: e.TargetId,
+		Before:   e.After,
+		After:    e.Before,
+	}
+}
+
+// History of a workspace, bound to the transaction that changes it. The workspace version must
+// be bumped in the transaction before Open, so that the row stays locked until the commit.
+type Log struct {
+	queries     *db_sqlc_gen.Queries
+	workspaceId uuid.UUID
+
+	// Current values of the targets, updated as events are applied
+	cameras     map[string]json.RawMessage
+	faces       map[string]json.RawMessage
+	calibration json.RawMessage
+}
+
+func Open(ctx context.Context, queries *db_sqlc_gen.Queries, workspaceId uuid.UUID) (*Log, error) {
+	workspace, err := queries.GetWorkspaceLayout(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		queries:     queries,
+		workspaceId: workspaceId,
+		cameras:     make(map[string]json.RawMessage),
+		faces:       make(map[string]json.RawMessage),
+	}
+	if len(workspace.Cameras) > 0 {
+		if err := json.Unmarshal(workspace.Cameras, &l.cameras); err != nil {
+			return nil, fmt.Errorf("unmarshalling workspace cameras: %w", err)
+		}
+	}
+	if len(workspace.TargetAreaTrapezoids) > 0 {
+		if err := json.Unmarshal(workspace.TargetAreaTrapezoids, &l.faces); err != nil {
+			return nil, fmt.Errorf("unmarshalling workspace faces: %w", err)
+		}
+	}
+	l.calibration, err = json.Marshal(Calibration{
+		ScaleFactor: workspace.ScaleFactor,
+		ModelHeight: workspace.ModelHeight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Current value of a target, nil when it doesn't exist
+func (l *Log) current(kind string, targetId string) json.RawMessage {
+	switch kind {
+	case KindCamera:
+		return l.cameras[targetId]
+	case KindFace:
+		return l.faces[targetId]
+	default:
+		return l.calibration
+	}
+}
+
+// Writes e.After to the workspace. Returns the event with Before set to the value it replaced,
+// which is what the history records.
+func (l *Log) Apply(ctx context.Context, e Event) (Event, error) {
+	e.Before = l.current(e.Kind, e.TargetId)
+
+	switch e.Kind {
+	case KindCamera:
+		if err := l.queries.PatchWorkspaceCams(ctx, db_sqlc_gen.PatchWorkspaceCamsParams{
+			Key:         []string{e.TargetId},
+			Value:       e.After,
+			WorkspaceID: l.workspaceId,
+		}); err != nil {
+			return Event{}, err
+		}
+		setOrDelete(l.cameras, e.TargetId, e.After)
+	case KindFace:
+		if err := l.queries.PatchWorkspaceTargetTrapezoids(ctx, db_sqlc_gen.PatchWorkspaceTargetTrapezoidsParams{
+			Key:         []string{e.TargetId},
+			Value:       e.After,
+			WorkspaceID: l.workspaceId,
+		}); err != nil {
+			return Event{}, err
+		}
+		setOrDelete(l.faces, e.TargetId, e.After)
+	case KindCalibration:
+		var calibration Calibration
+		if e.After == nil {
+			return Event{}, fmt.Errorf("%w: the calibration can't be deleted", ErrInvalidEvent)
+		}
+		if err := json.Unmarshal(e.After, &calibration); err != nil {
+			return Event{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+		if err := l.queries.PatchWorkspaceCalibration(ctx, db_sqlc_gen.PatchWorkspaceCalibrationParams{
+			WorkspaceID: l.workspaceId,
+			ScaleFactor: calibration.ScaleFactor,
+			ModelHeight: calibration.ModelHeight,
+		}); err != nil {
+			return Event{}, err
+		}
+		l.calibration = e.After
+	default:
+		return Event{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidEvent, e.Kind)
+	}
+	return e, nil
+}
+
+func setOrDelete(values map[string]json.RawMessage, key string, value json.RawMessage) {
+	if value == nil {
+		delete(values, key)
+		return
+	}
+	values[key] = value
+}
+
+// Stacks after the latest step, empty for a workspace without history
+func (l *Log) Stacks(ctx context.Context) (Stacks, error) {
+	return LatestStacks(ctx, l.queries, l.workspaceId)
+}
+
+func LatestStacks(ctx context.Context, queries *db_sqlc_gen.Queries, workspaceId uuid.UUID) (Stacks, error) {
+	latest, err := queries.GetLatestWorkspaceStep(ctx, workspaceId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Stacks{}, nil
+	}
+	if err != nil {
+		return Stacks{}, err
+	}
+	return Stacks{Undo: latest.UndoStack, Redo: latest.RedoStack}, nil
+}
+
+// Drops the history of a workspace whose version goes back to the base version of main, e.g. after
+// a merge. The steps would collide with the next versions and undo edits that are now in main.
+func Clear(ctx context.Context, queries *db_sqlc_gen.Queries, workspaceId uuid.UUID) error {
+	return queries.DeleteWorkspaceSteps(ctx, workspaceId)
+}
+
+func (l *Log) record(ctx context.Context, version int32, action string, targetVersion pgtype.Int4, stacks Stacks, events []Event) error {
+	undo, redo := stacks.Undo, stacks.Redo
+	if undo == nil {
+		undo = []int32{}
+	}
+	if redo == nil {
+		redo = []int32{}
+	}
+	if err := l.queries.CreateWorkspaceStep(ctx, db_sqlc_gen.CreateWorkspaceStepParams{
+		WorkspaceID:   l.workspaceId,
+		Version:       version,
+		Action:        action,
+		TargetVersion: targetVersion,
+		UndoStack:     undo,
+		RedoStack:     redo,
+	}); err != nil {
+		return err
+	}
+
+	for i, e := range events {
+		if err := l.queries.CreateWorkspaceEvent(ctx, db_sqlc_gen.CreateWorkspaceEventParams{
+			WorkspaceID: l.workspaceId,
+			Version:     version,
+			Seq:         int32(i),
+			Kind:        e.Kind,
+			TargetID:    e.TargetId,
+			BeforeValue: e.Before,
+			AfterValue:  e.After,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records the events returned by Apply as an edit, which becomes the next one to undo
+func (l *Log) RecordEdit(ctx context.Context, version int32, events []Event) (Stacks, error) {
+	stacks, err := l.Stacks(ctx)
+	if err != nil {
+		return Stacks{}, err
+	}
+
+	stacks = stacks.Edit(version)
+	if err := l.record(ctx, version, ActionEdit, pgtype.Int4{}, stacks, events); err != nil {
+		return Stacks{}, err
+	}
+	return stacks, nil
+}
+
+// Events of an edit step, in the order they were applied
+func (l *Log) events(ctx context.Context, version int32) ([]Event, error) {
+	rows, err := l.queries.GetWorkspaceEventsByVersions(ctx, db_sqlc_gen.GetWorkspaceEventsByVersionsParams{
+		WorkspaceID: l.workspaceId,
+		Versions:    []int32{version},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, len(rows))
+	for i, row := range rows {
+		events[i] = Event{
+			Kind:     row.Kind,
+			TargetId: row.TargetID,
+			Before:   row.BeforeValue,
+			After:    row.AfterValue,
+		}
+	}
+	return events, nil
+}
+
+// Applies the events after checking that the targets still have the values they had when the
+// step was recorded
+func (l *Log) replay(ctx context.Context, events []Event) ([]Event, error) {
+	applied := make([]Event, 0, len(events))
+	for _, e := range events {
+		if !utils.SameJSON(l.current(e.Kind, e.TargetId), e.Before) {
+			return nil, fmt.Errorf("%w: %s %q", ErrConflict, e.Kind, e.TargetId)
+		}
+		recorded, err := l.Apply(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		applied = append(applied, recorded)
+	}
+	return applied, nil
+}
+
+// Reverts the last edit on the undo stack, the workspace version must already be bumped to
+// version. Returns the events applied to the workspace.
+func (l *Log) Undo(ctx context.Context, version int32) ([]Event, Stacks, error) {
+	stacks, err := l.Stacks(ctx)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+	target, stacks, err := stacks.PopUndo()
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+
+	events, err := l.events(ctx, target)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+	inverse := make([]Event, len(events))
+	for i, e := range events {
+		inverse[len(events)-1-i] = e.Inverse()
+	}
+
+	applied, err := l.replay(ctx, inverse)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+	if err := l.record(ctx, version, ActionUndo, pgtype.Int4{Int32: target, Valid: true}, stacks, applied); err != nil {
+		return nil, Stacks{}, err
+	}
+	return applied, stacks, nil
+}
+
+// Applies again the last undone edit, the workspace version must already be bumped to version.
+// Returns the events applied to the workspace.
+func (l *Log) Redo(ctx context.Context, version int32) ([]Event, Stacks, error) {
+	stacks, err := l.Stacks(ctx)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+	target, stacks, err := stacks.PopRedo()
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+
+	events, err := l.events(ctx, target)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+
+	applied, err := l.replay(ctx, events)
+	if err != nil {
+		return nil, Stacks{}, err
+	}
+	if err := l.record(ctx, version, ActionRedo, pgtype.Int4{Int32: target, Valid: true}, stacks, applied); err != nil {
+		return nil, Stacks{}, err
+	}
+	return applied, stacks, nil
+}
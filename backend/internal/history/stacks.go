@@ -0,0 +1,61 @@
+package history
+
+import (
+	"errors"
+	"slices"
+)
+
+// Edits kept on the undo stack, older ones can't be undone anymore
+const MaxUndoDepth = 100
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Versions of the edit steps that undo and redo apply next, the last element first
+type Stacks struct {
+	Undo []int32
+	Redo []int32
+}
+
+func (s Stacks) CanUndo() bool {
+	return len(s.Undo) > 0
+}
+
+func (s Stacks) CanRedo() bool {
+	return len(s.Redo) > 0
+}
+
+// Stacks after a new edit, which can't be followed by a redo of the edits undone before it
+func (s Stacks) Edit(version int32) Stacks {
+	undo := append(slices.Clone(s.Undo), version)
+	if len(undo) > MaxUndoDepth {
+		undo = undo[len(undo)-MaxUndoDepth:]
+	}
+	return Stacks{Undo: undo}
+}
+
+// Edit to undo, and the stacks once it is undone
+func (s Stacks) PopUndo() (int32, Stacks, error) {
+	if !s.CanUndo() {
+		return 0, s, ErrNothingToUndo
+	}
+	target := s.Undo[len(s.Undo)-1]
+	return target, Stacks{
+		Undo: slices.Clone(s.Undo[:len(s.Undo)-1]),
+		Redo: append(slices.Clone(s.Redo), target),
+	}, nil
+}
+
+// Edit to redo, and the stacks once it is redone
+func (s Stacks) PopRedo() (int32, Stacks, error) {
+	if !s.CanRedo() {
+		return 0, s, ErrNothingToRedo
+	}
+	target := s.Redo[len(s.Redo)-1]
+	return target, Stacks{
+		Undo: append(slices.Clone(s.Undo), target),
+		Redo: slices.Clone(s.Redo[:len(s.Redo)-1]),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package history_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/history"
+)
+
+func TestStacks(t *testing.T) {
+	t.Parallel()
+
+	stacks := history.Stacks{}.Edit(1).Edit(2)
+	require.Equal(t, []int32{1, 2}, stacks.Undo)
+	require.False(t, stacks.CanRedo())
+
+	target, stacks, err := stacks.PopUndo()
+	require.NoError(t, err)
+	require.Equal(t, int32(2), target)
+	require.Equal(t, []int32{1}, stacks.Undo)
+	require.Equal(t, []int32{2}, stacks.Redo)
+
+	target, stacks, err = stacks.PopRedo()
+	require.NoError(t, err)
+	require.Equal(t, int32(2), target)
+	require.Equal(t, []int32{1, 2}, stacks.Undo)
+	require.Empty(t, stacks.Redo)
+
+	_, stacks, err = stacks.PopUndo()
+	require.NoError(t, err)
+	// A new edit drops the undone ones
+	stacks = stacks.Edit(5)
+	require.Equal(t, []int32{1, 5}, stacks.Undo)
+	require.False(t, stacks.CanRedo())
+}
+
+func TestStacksEmpty(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := history.Stacks{}.PopUndo()
+	require.ErrorIs(t, err, history.ErrNothingToUndo)
+	_, _, err = history.Stacks{}.PopRedo()
+	require.ErrorIs(t, err, history.ErrNothingToRedo)
+}
+
+func TestStacksDepth(t *testing.T) {
+	t.Parallel()
+
+	stacks := history.Stacks{}
+	for version := int32(1); version <= history.MaxUndoDepth+5; version++ {
+		stacks = stacks.Edit(version)
+	}
+	require.Len(t, stacks.Undo, history.MaxUndoDepth)
+	require.Equal(t, int32(6), stacks.Undo[0])
+}
+
+func TestStacksDontShareArrays(t *testing.T) {
+	t.Parallel()
+
+	base := history.Stacks{}.Edit(1).Edit(2)
+	_, undone, err := base.PopUndo()
+	require.NoError(t, err)
+	undone.Edit(3)
+	require.Equal(t, []int32{1, 2}, base.Undo)
+}
@@ -0,0 +1,94 @@
+package merge
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+)
+
+// Changes of a document between two versions
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Appends the leaves that differ between two decoded json values. Objects are walked key by key,
+// a missing side is walked as an empty object so that every field of an added or removed
+// document is listed. Any other value, arrays included, is compared as a whole.
+func diffValues(fields []messages_model_workspace.FieldChange, path []string, from, to any) []messages_model_workspace.FieldChange {
+	fromObject, fromOk := from.(map[string]any)
+	toObject, toOk := to.(map[string]any)
+	if (fromOk || from == nil) && (toOk || to == nil) && (fromOk || toOk) {
+		union := make(map[string]any, len(fromObject)+len(toObject))
+		maps.Copy(union, fromObject)
+		maps.Copy(union, toObject)
+		for _, key := range slices.Sorted(maps.Keys(union)) {
+			fields = diffValues(fields, append(path[:len(path):len(path)], key), fromObject[key], toObject[key])
+		}
+		return fields
+	}
+
+	if reflect.DeepEqual(from, to) {
+		return fields
+	}
+	return append(fields, messages_model_workspace.FieldChange{Path: path, From: from, To: to})
+}
+
+// Fields that differ between two versions of a document, named by their json path
+func Diff[V any](from, to V) ([]messages_model_workspace.FieldChange, error) {
+	fromDecoded, err := decode(from)
+	if err != nil {
+		return nil, fmt.Errorf("decoding document: %w", err)
+	}
+	toDecoded, err := decode(to)
+	if err != nil {
+		return nil, fmt.Errorf("decoding document: %w", err)
+	}
+	return diffValues([]messages_model_workspace.FieldChange{}, nil, fromDecoded, toDecoded), nil
+}
+
+// Documents keyed by id added, removed or changed from from to to, sorted by id. Every field of
+// an added or removed document is listed.
+func DiffDocuments[K ~string, V any](from, to map[K]V) ([]messages_model_workspace.DocumentChange, error) {
+	union := make(map[K]struct{}, len(from)+len(to))
+	for id := range from {
+		union[id] = struct{}{}
+	}
+	for id := range to {
+		union[id] = struct{}{}
+	}
+
+	changes := []messages_model_workspace.DocumentChange{}
+	for _, id := range slices.Sorted(maps.Keys(union)) {
+		fromValue, fromOk := from[id]
+		toValue, toOk := to[id]
+
+		var fromDoc, toDoc any
+		if fromOk {
+			fromDoc = fromValue
+		}
+		if toOk {
+			toDoc = toValue
+		}
+		fields, err := Diff(fromDoc, toDoc)
+		if err != nil {
+			return nil, fmt.Errorf("diffing %s: %w", string(id), err)
+		}
+
+		change := ChangeChanged
+		switch {
+		case !fromOk:
+			change = ChangeAdded
+		case !toOk:
+			change = ChangeRemoved
+		case len(fields) == 0:
+			continue
+		}
+		changes = append(changes, messages_model_workspace.DocumentChange{Id: string(id), Change: change, Fields: fields})
+	}
+	return changes, nil
+}
@@ -0,0 +1,36 @@
+package merge_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/merge"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	t.Parallel()
+
+	moved := messages_cameras.CameraStruct{Name: "moved", PosX: 1}
+	movedAfter := moved
+	movedAfter.PosX = 2
+	movedAfter.FrustumColor.R = 0.5
+	kept := messages_cameras.CameraStruct{Name: "kept"}
+
+	changes, err := merge.DiffDocuments(
+		messages_cameras.Cameras{"moved": moved, "kept": kept, "removed": kept},
+		messages_cameras.Cameras{"moved": movedAfter, "kept": kept, "added": kept},
+	)
+	require.NoError(t, err)
+
+	require.Len(t, changes, 3)
+	require.Equal(t, "added", changes[0].Id)
+	require.Equal(t, merge.ChangeAdded, changes[0].Change)
+	require.Equal(t, messages_model_workspace.DocumentChange{Id: "moved", Change: merge.ChangeChanged, Fields: []messages_model_workspace.FieldChange{
+		{Path: []string{"frustumColor", "r"}, From: 0.0, To: 0.5},
+		{Path: []string{"posX"}, From: 1.0, To: 2.0},
+	}}, changes[1])
+	require.Equal(t, "removed", changes[2].Id)
+	require.Equal(t, merge.ChangeRemoved, changes[2].Change)
+}
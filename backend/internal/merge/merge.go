@@ -0,0 +1,160 @@
+package merge
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Deepest path of a conflict, deeper documents are rejected
+const MaxDepth = 10
+
+// Key of a level in the path to a field, the json name of the field
+type Property string
+
+// Conflicting fields of a document, nested as the document. The leaves are FieldConflict.
+type ConflictMap map[Property]any
+
+type FieldConflict struct {
+	Base      any `json:"base"`
+	Main      any `json:"main"`
+	Workspace any `json:"workspace"`
+}
+
+func buildConflictTree(tree ConflictMap, path []string, b, m, w any) {
+	curr := tree
+	for i, step := range path {
+		key := Property(step)
+		if i == len(path)-1 {
+			curr[key] = FieldConflict{Base: b, Main: m, Workspace: w}
+			return
+		}
+		if _, ok := curr[key]; !ok {
+			curr[key] = make(ConflictMap)
+		}
+		curr = curr[key].(ConflictMap)
+	}
+}
+
+// Merges decoded json values. Objects are merged key by key, any other value is replaced as a
+// whole and conflicts when both sides changed it differently, in which case base is kept.
+func mergeValues(tree ConflictMap, path []string, base, main, workspace any) any {
+	if len(path) < MaxDepth {
+		mainObject, mainOk := main.(map[string]any)
+		workspaceObject, workspaceOk := workspace.(map[string]any)
+		baseObject, baseOk := base.(map[string]any)
+		if mainOk && workspaceOk && (baseOk || base == nil) {
+			merged := make(map[string]any, len(workspaceObject))
+			for _, object := range []map[string]any{baseObject, mainObject, workspaceObject} {
+				for key := range object {
+					if _, done := merged[key]; done {
+						continue
+					}
+					merged[key] = mergeValues(tree, append(path[:len(path):len(path)], key),
+						baseObject[key], mainObject[key], workspaceObject[key])
+				}
+			}
+			return merged
+		}
+	}
+
+	switch {
+	case reflect.DeepEqual(base, main):
+		return workspace
+	case reflect.DeepEqual(base, workspace), reflect.DeepEqual(main, workspace):
+		return main
+	default:
+		buildConflictTree(tree, path, base, main, workspace)
+		return base
+	}
+}
+
+func decode(doc any) (any, error) {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// Three-way merge of a document edited in main and in a workspace since base
+func Merge[V any](base, main, workspace V) (V, ConflictMap, error) {
+	var merged V
+	decoded := make([]any, 3)
+	for i, doc := range []V{base, main, workspace} {
+		var err error
+		if decoded[i], err = decode(doc); err != nil {
+			return merged, nil, fmt.Errorf("decoding document: %w", err)
+		}
+	}
+
+	conflicts := make(ConflictMap)
+	encoded, err := json.Marshal(mergeValues(conflicts, nil, decoded[0], decoded[1], decoded[2]))
+	if err != nil {
+		return merged, nil, err
+	}
+	if err := json.Unmarshal(encoded, &merged); err != nil {
+		return merged, nil, fmt.Errorf("decoding merged document: %w", err)
+	}
+	return merged, conflicts, nil
+}
+
+// Three-way merge of documents keyed by id, like the cameras or the faces of a model. A document
+// deleted on one side and left as is on the other is deleted, one deleted and edited is merged
+// against a zero document so that the edited fields conflict.
+func Documents[K comparable, V any](base, main, workspace map[K]V) (map[K]V, map[K]ConflictMap, error) {
+	result := make(map[K]V)
+	conflicts := make(map[K]ConflictMap)
+
+	keys := map[K]struct{}{}
+	for k := range base {
+		keys[k] = struct{}{}
+	}
+	for k := range main {
+		keys[k] = struct{}{}
+	}
+	for k := range workspace {
+		keys[k] = struct{}{}
+	}
+
+	for id := range keys {
+		b, bok := base[id]
+		m, mok := main[id]
+		w, wok := workspace[id]
+
+		switch {
+		// new document in workspace
+		case !bok && !mok && wok:
+			result[id] = w
+			continue
+		// new document in main
+		case !bok && mok && !wok:
+			result[id] = m
+			continue
+		// deleted in both
+		case !mok && !wok:
+			continue
+		// deleted in main, untouched in workspace
+		case bok && !mok && reflect.DeepEqual(b, w):
+			continue
+		// deleted in workspace, untouched in main
+		case bok && !wok && reflect.DeepEqual(b, m):
+			continue
+		}
+
+		merged, conflictOfId, err := Merge(b, m, w)
+		if err != nil {
+			return nil, nil, fmt.Errorf("merging %v: %w", id, err)
+		}
+		if len(conflictOfId) != 0 {
+			conflicts[id] = conflictOfId
+		}
+		result[id] = merged
+	}
+
+	return result, conflicts, nil
+}
@@ -0,0 +1,197 @@
+package merge_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/merge"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
+)
+
+func TestMergeNestedFields(t *testing.T) {
+	t.Parallel()
+
+	base := messages_cameras.CameraStruct{Name: "cam", PosX: 1}
+	main := base
+	main.FrustumColor.R = 0.5
+	workspace := base
+	workspace.PosX = 2
+	workspace.FrustumColor.G = 0.25
+
+	merged, conflicts, err := merge.Merge(base, main, workspace)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+	require.Equal(t, 2.0, merged.PosX)
+	require.Equal(t, messages_cameras.ColorRGBA{R: 0.5, G: 0.25}, merged.FrustumColor)
+}
+
+func TestMergeConflict(t *testing.T) {
+	t.Parallel()
+
+	base := messages_cameras.CameraStruct{Name: "cam", PosX: 1}
+	main := base
+	main.PosX = 12
+	workspace := base
+	workspace.PosX = 10
+
+	merged, conflicts, err := merge.Merge(base, main, workspace)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, merged.PosX)
+	require.Equal(t, merge.ConflictMap{
+		"posX": merge.FieldConflict{Base: 1.0, Main: 12.0, Workspace: 10.0},
+	}, conflicts)
+}
+
+func TestDocumentsFaces(t *testing.T) {
+	t.Parallel()
+
+	face := messages_trapezoids.TrapezoidStruct{ID: "a", Name: "entrance"}
+	moved := face
+	moved.Points[1].Y = 3
+	renamed := face
+	renamed.Name = "exit"
+
+	base := messages_trapezoids.Trapezoids{"a": face, "b": face, "c": face}
+	main := messages_trapezoids.Trapezoids{"a": moved, "c": face, "d": face}
+	workspace := messages_trapezoids.Trapezoids{"a": renamed, "b": face, "e": face}
+
+	merged, conflicts, err := merge.Documents(base, main, workspace)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+
+	expected := moved
+	expected.Name = "exit"
+	// b deleted in main and c in the workspace, d and e added
+	require.Equal(t, messages_trapezoids.Trapezoids{"a": expected, "d": face, "e": face}, merged)
+}
+
+func TestDocumentsConflictingPoints(t *testing.T) {
+	t.Parallel()
+
+	face := messages_trapezoids.TrapezoidStruct{ID: "a"}
+	main := face
+	main.Points[0].X = 1
+	workspace := face
+	workspace.Points[2].Z = 1
+
+	merged, conflicts, err := merge.Documents(
+		messages_trapezoids.Trapezoids{"a": face},
+		messages_trapezoids.Trapezoids{"a": main},
+		messages_trapezoids.Trapezoids{"a": workspace},
+	)
+	require.NoError(t, err)
+	// The corners are replaced as a whole
+	require.Contains(t, conflicts["a"], merge.Property("points"))
+	require.Equal(t, face, merged["a"])
+}
+
+func TestDocumentsDeletedAndEdited(t *testing.T) {
+	t.Parallel()
+
+	base := messages_cameras.Cameras{"a": {Name: "cam"}}
+	workspace := messages_cameras.Cameras{"a": {Name: "renamed"}}
+
+	_, conflicts, err := merge.Documents(base, messages_cameras.Cameras{}, workspace)
+	require.NoError(t, err)
+	require.Contains(t, conflicts, messages_cameras.CamId("a"))
+}
+
+func nestedConflict(t *testing.T) (messages_cameras.CameraStruct, merge.ConflictMap) {
+	t.Helper()
+
+	base := messages_cameras.CameraStruct{Name: "cam"}
+	main := base
+	main.FrustumColor.R = 0.5
+	main.PosX = 1
+	workspace := base
+	workspace.FrustumColor.R = 0.25
+	workspace.PosX = 2
+
+	merged, conflicts, err := merge.Merge(base, main, workspace)
+	require.NoError(t, err)
+	return merged, conflicts
+}
+
+func TestResolveNested(t *testing.T) {
+	t.Parallel()
+
+	merged, conflicts := nestedConflict(t)
+	resolved, err := merge.Resolve(merged, conflicts, []merge.Resolution{
+		{Path: []string{"frustumColor", "r"}, Take: merge.TakeWorkspace},
+		{Path: []string{"posX"}, Take: merge.TakeCustom, Value: 3.0},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0.25, resolved.FrustumColor.R)
+	require.Equal(t, 3.0, resolved.PosX)
+}
+
+func TestResolveErrors(t *testing.T) {
+	t.Parallel()
+
+	merged, conflicts := nestedConflict(t)
+	main := merge.Resolution{Path: []string{"posX"}, Take: merge.TakeMain}
+	tests := []struct {
+		name        string
+		resolutions []merge.Resolution
+		err         error
+	}{
+		{"missing leaf", []merge.Resolution{main}, merge.ErrUnresolved},
+		{"not a conflict", []merge.Resolution{main, {Path: []string{"fov"}, Take: merge.TakeMain}}, merge.ErrInvalidResolution},
+		{"branch instead of leaf", []merge.Resolution{main, {Path: []string{"frustumColor"}, Take: merge.TakeMain}}, merge.ErrInvalidResolution},
+		{"resolved twice", []merge.Resolution{main, main}, merge.ErrInvalidResolution},
+		{"wrong type", []merge.Resolution{main, {Path: []string{"frustumColor", "r"}, Take: merge.TakeCustom, Value: "red"}}, merge.ErrInvalidResolution},
+		{"unknown choice", []merge.Resolution{main, {Path: []string{"frustumColor", "r"}, Take: "theirs"}}, merge.ErrInvalidResolution},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := merge.Resolve(merged, conflicts, tt.resolutions)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestResolvePoints(t *testing.T) {
+	t.Parallel()
+
+	face := messages_trapezoids.TrapezoidStruct{ID: "a"}
+	main := face
+	main.Points[0].X = 1
+	workspace := face
+	workspace.Points[2].Z = 1
+
+	merged, conflicts, err := merge.Merge(face, main, workspace)
+	require.NoError(t, err)
+
+	points := []any{}
+	for i := range 4 {
+		points = append(points, map[string]any{"x": float64(i), "y": 0.0, "z": 0.0})
+	}
+	resolved, err := merge.Resolve(merged, conflicts, []merge.Resolution{
+		{Path: []string{"points"}, Take: merge.TakeCustom, Value: points},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3.0, resolved.Points[3].X)
+
+	// Three corners aren't a trapezoid
+	_, err = merge.Resolve(merged, conflicts, []merge.Resolution{
+		{Path: []string{"points"}, Take: merge.TakeCustom, Value: points[:3]},
+	})
+	require.ErrorIs(t, err, merge.ErrInvalidResolution)
+}
+
+func TestPicked(t *testing.T) {
+	t.Parallel()
+
+	merged, conflicts := nestedConflict(t)
+	resolutions := merge.Picked(conflicts, map[string]any{
+		"frustumColor": map[string]any{"r": 0.75},
+		"posX":         2.0,
+	})
+	resolved, err := merge.Resolve(merged, conflicts, resolutions)
+	require.NoError(t, err)
+	require.Equal(t, 0.75, resolved.FrustumColor.R)
+	require.Equal(t, 2.0, resolved.PosX)
+}
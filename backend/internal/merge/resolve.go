@@ -0,0 +1,219 @@
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	ErrInvalidResolution = errors.New("invalid resolution")
+	ErrUnresolved        = errors.New("unresolved conflict")
+)
+
+// Side a conflicting field is resolved with
+type Take string
+
+const (
+	TakeMain      Take = "main"
+	TakeWorkspace Take = "workspace"
+	TakeCustom    Take = "custom"
+)
+
+// Resolution of the conflict at Path, a leaf of the ConflictMap. Value is only read for
+// TakeCustom and must have the type of the field.
+type Resolution struct {
+	Path  []string
+	Take  Take
+	Value any
+}
+
+func formatPath(path []string) string {
+	return strings.Join(path, ".")
+}
+
+// Custom resolutions from values nested as the conflicts, objects are walked down as long as the
+// conflicts are nested
+func Picked(conflicts ConflictMap, values map[string]any) []Resolution {
+	var resolutions []Resolution
+	var walk func(conflicts ConflictMap, values map[string]any, path []string)
+	walk = func(conflicts ConflictMap, values map[string]any, path []string) {
+		for key, value := range values {
+			keyPath := append(path[:len(path):len(path)], key)
+			nestedConflicts, conflictsOk := conflicts[Property(key)].(ConflictMap)
+			nestedValues, valuesOk := value.(map[string]any)
+			if conflictsOk && valuesOk {
+				walk(nestedConflicts, nestedValues, keyPath)
+				continue
+			}
+			resolutions = append(resolutions, Resolution{Path: keyPath, Take: TakeCustom, Value: value})
+		}
+	}
+	walk(conflicts, values, nil)
+	return resolutions
+}
+
+// Whether value has the json type of sample, arrays must have the same length and objects the
+// same keys
+func sameShape(sample any, value any) bool {
+	switch sample := sample.(type) {
+	case []any:
+		values, ok := value.([]any)
+		if !ok || len(values) != len(sample) {
+			return false
+		}
+		for i := range sample {
+			if !sameShape(sample[i], values[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		values, ok := value.(map[string]any)
+		if !ok || len(values) != len(sample) {
+			return false
+		}
+		for key, nested := range sample {
+			if v, ok := values[key]; !ok || !sameShape(nested, v) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.TypeOf(sample) == reflect.TypeOf(value)
+	}
+}
+
+// Checks a custom value against the values the field has on each side
+func checkType(conflict FieldConflict, value any) error {
+	for _, side := range []any{conflict.Main, conflict.Workspace, conflict.Base} {
+		if side == nil && value == nil {
+			return nil
+		}
+		if side != nil && sameShape(side, value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("value doesn't have the type of the field")
+}
+
+// Leaf of the conflicts at path
+func leaf(conflicts ConflictMap, path []string) (FieldConflict, bool) {
+	var curr any = conflicts
+	for _, step := range path {
+		tree, ok := curr.(ConflictMap)
+		if !ok {
+			return FieldConflict{}, false
+		}
+		if curr, ok = tree[Property(step)]; !ok {
+			return FieldConflict{}, false
+		}
+	}
+	conflict, ok := curr.(FieldConflict)
+	return conflict, ok
+}
+
+func leafPaths(conflicts ConflictMap, path []string, paths *[]string) {
+	for key, conflict := range conflicts {
+		keyPath := append(path[:len(path):len(path)], string(key))
+		if nested, ok := conflict.(ConflictMap); ok {
+			leafPaths(nested, keyPath, paths)
+			continue
+		}
+		*paths = append(*paths, formatPath(keyPath))
+	}
+}
+
+// Sets the value at path in a decoded json document, the objects on the way exist as the
+// conflicts were found by walking them
+func setPath(doc any, path []string, value any) error {
+	object, ok := doc.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: %s isn't in an object", ErrInvalidResolution, formatPath(path))
+	}
+	if len(path) == 1 {
+		object[path[0]] = value
+		return nil
+	}
+	return setPath(object[path[0]], path[1:], value)
+}
+
+// Applies the resolutions to a document merged by Merge. Every conflict must be resolved exactly
+// once and nothing else.
+func Resolve[V any](merged V, conflicts ConflictMap, resolutions []Resolution) (V, error) {
+	var resolved V
+	doc, err := decode(merged)
+	if err != nil {
+		return resolved, fmt.Errorf("decoding document: %w", err)
+	}
+
+	done := make(map[string]struct{}, len(resolutions))
+	for _, r := range resolutions {
+		path := formatPath(r.Path)
+		if len(r.Path) == 0 || len(r.Path) > MaxDepth {
+			return resolved, fmt.Errorf("%w: invalid path %q", ErrInvalidResolution, path)
+		}
+		conflict, ok := leaf(conflicts, r.Path)
+		if !ok {
+			return resolved, fmt.Errorf("%w: no conflict at %s", ErrInvalidResolution, path)
+		}
+		if _, ok := done[path]; ok {
+			return resolved, fmt.Errorf("%w: %s is resolved twice", ErrInvalidResolution, path)
+		}
+		done[path] = struct{}{}
+
+		var value any
+		switch r.Take {
+		case TakeMain:
+			value = conflict.Main
+		case TakeWorkspace:
+			value = conflict.Workspace
+		case TakeCustom:
+			if err := checkType(conflict, r.Value); err != nil {
+				return resolved, fmt.Errorf("%w: %s: %v", ErrInvalidResolution, path, err)
+			}
+			value = r.Value
+		default:
+			return resolved, fmt.Errorf("%w: unknown choice %q for %s", ErrInvalidResolution, r.Take, path)
+		}
+		if err := setPath(doc, r.Path, value); err != nil {
+			return resolved, err
+		}
+	}
+
+	var paths []string
+	leafPaths(conflicts, nil, &paths)
+	for _, path := range paths {
+		if _, ok := done[path]; !ok {
+			return resolved, fmt.Errorf("%w at %s", ErrUnresolved, path)
+		}
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return resolved, err
+	}
+	if err := json.Unmarshal(encoded, &resolved); err != nil {
+		return resolved, fmt.Errorf("%w: %v", ErrInvalidResolution, err)
+	}
+	return resolved, nil
+}
+
+// Resolves the conflicts of documents merged by Documents, in place
+func ResolveDocuments[K comparable, V any](merged map[K]V, conflicts map[K]ConflictMap, resolutions map[K][]Resolution) error {
+	for id := range resolutions {
+		if _, ok := conflicts[id]; !ok {
+			return fmt.Errorf("%w: no conflict in %v", ErrInvalidResolution, id)
+		}
+	}
+	for id, conflictOfId := range conflicts {
+		resolved, err := Resolve(merged[id], conflictOfId, resolutions[id])
+		if err != nil {
+			return fmt.Errorf("%v: %w", id, err)
+		}
+		merged[id] = resolved
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package optimizer
+
+import (
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Cameras of a single config, more than that is never a reasonable layout
+const MaxCameraAmount = 100
+
+func CameraAmount(config *protobufs.CameraConfig) uint32 {
+	if config.GetAmount() == 0 {
+		return 1
+	}
+	return config.GetAmount()
+}
+
+// Checks the amount of cameras to place, the optimizer needs at least one face per camera
+func ValidateCameraConfigs(configs []*protobufs.CameraConfig, faceCount int) error {
+	if len(configs) == 0 {
+		return invalidParams("no camera config provided")
+	}
+
+	total := 0
+	for _, config := range configs {
+		amount := CameraAmount(config)
+		if amount > MaxCameraAmount {
+			return invalidParams("camera config %q: amount must be at most %d", config.GetName(), MaxCameraAmount)
+		}
+		total += int(amount)
+	}
+
+	if total > faceCount {
+		return invalidParams("%d cameras requested for %d coverage faces", total, faceCount)
+	}
+
+	return nil
+}
+
+// Resolves the fixed cameras from the workspace cameras, the optimizer accounts for them without moving them
+func FixedCamerasFromWorkspace(cameras messages_cameras.Cameras, ids []string) ([]*protobufs.Camera, error) {
+	fixed := make([]*protobufs.Camera, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, invalidParams("camera %q is fixed twice", id)
+		}
+		seen[id] = true
+
+		cam, ok := cameras[messages_cameras.CamId(id)]
+		if !ok {
+			return nil, invalidParams("camera %q not found in workspace", id)
+		}
+
+		fixed = append(fixed, messages_cameras.CamToProtoCam(id, cam))
+	}
+
+	return fixed, nil
+}
@@ -0,0 +1,82 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/optimizer"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+func TestValidateCameraConfigs(t *testing.T) {
+	tests := []struct {
+		name      string
+		configs   []*protobufs.CameraConfig
+		faceCount int
+		wantErr   bool
+	}{
+		{
+			name:      "zero amount counts as one",
+			configs:   []*protobufs.CameraConfig{{Name: "a"}, {Name: "b"}},
+			faceCount: 2,
+		},
+		{
+			name:      "amounts fit the faces",
+			configs:   []*protobufs.CameraConfig{{Name: "a", Amount: 6}},
+			faceCount: 6,
+		},
+		{
+			name:      "more cameras than faces",
+			configs:   []*protobufs.CameraConfig{{Name: "a", Amount: 4}, {Name: "b"}},
+			faceCount: 4,
+			wantErr:   true,
+		},
+		{
+			name:      "amount too large",
+			configs:   []*protobufs.CameraConfig{{Name: "a", Amount: optimizer.MaxCameraAmount + 1}},
+			faceCount: 1000,
+			wantErr:   true,
+		},
+		{
+			name:      "no config",
+			faceCount: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := optimizer.ValidateCameraConfigs(tc.configs, tc.faceCount)
+			if tc.wantErr {
+				require.ErrorIs(t, err, optimizer.ErrInvalidParams)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFixedCamerasFromWorkspace(t *testing.T) {
+	cam := messages_cameras.DefaultCam()
+	cam.Name = "entrance"
+	cam.PosX, cam.PosY, cam.PosZ = 1, 2, 3
+	cam.AngleW, cam.AngleX, cam.AngleY, cam.AngleZ = 1, 0, 0, 0
+	cameras := messages_cameras.Cameras{"cam-1": cam}
+
+	fixed, err := optimizer.FixedCamerasFromWorkspace(cameras, []string{"cam-1"})
+	require.NoError(t, err)
+	require.Len(t, fixed, 1)
+	require.Equal(t, "cam-1", fixed[0].Id)
+	require.Equal(t, "entrance", fixed[0].Name)
+	require.Equal(t, []float64{1, 2, 3}, []float64{fixed[0].PosX, fixed[0].PosY, fixed[0].PosZ})
+	require.Equal(t, []float64{1, 0, 0, 0}, []float64{fixed[0].AngleW, fixed[0].AngleX, fixed[0].AngleY, fixed[0].AngleZ})
+
+	_, err = optimizer.FixedCamerasFromWorkspace(cameras, []string{"missing"})
+	require.ErrorIs(t, err, optimizer.ErrInvalidParams)
+
+	_, err = optimizer.FixedCamerasFromWorkspace(cameras, []string{"cam-1", "cam-1"})
+	require.ErrorIs(t, err, optimizer.ErrInvalidParams)
+}
@@ -0,0 +1,43 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	db_client "omnicam.com/backend/pkg/db"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Longer than any optimization, so the optimizer sees the flag even if the job is still queued
+const cancelFlagTTL = time.Hour
+
+var ErrJobFinished = errors.New("optimization job already finished")
+
+func CancelledResponse(jobId string) *protobufs.OptimizationEventResp {
+	return &protobufs.OptimizationEventResp{
+		JobId: jobId,
+		Payload: &protobufs.OptimizationEventResp_CancelledResp{
+			CancelledResp: &protobufs.CancelledOptimizationEventResp{},
+		},
+	}
+}
+
+// Marks the job as cancelled, then asks the queue to stop it and notify its waiter
+func CancelJob(ctx context.Context, db *db_client.DB, queue JobQueue, jobId uuid.UUID) error {
+	_, err := db.Queries.FinishOptimizationJob(ctx, db_sqlc_gen.FinishOptimizationJobParams{
+		ID:     jobId,
+		Status: db_sqlc_gen.OptimizationJobStatusCancelled,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrJobFinished
+	}
+	if err != nil {
+		return err
+	}
+
+	return queue.Cancel(ctx, jobId.String())
+}
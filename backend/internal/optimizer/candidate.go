@@ -0,0 +1,84 @@
+package optimizer
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+	"omnicam.com/backend/internal/merge"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_optimization "omnicam.com/backend/pkg/messages/optimization"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Cameras of a successful result in the workspace format, cameras without an id get a new one
+func ResultCameras(resp *protobufs.SuccessOptimizationEventResp) messages_cameras.Cameras {
+	cameras := make(messages_cameras.Cameras, len(resp.GetCameras()))
+	for _, cam := range resp.GetCameras() {
+		id := cam.GetId()
+		if id == "" {
+			id = uuid.NewString()
+		}
+		cameras[messages_cameras.CamId(id)] = messages_cameras.ProtoCamToCam(cam)
+	}
+	return cameras
+}
+
+// Workspace cameras once a candidate is applied. The candidate cameras are added, replacing the
+// workspace cameras with the same id, and the other workspace cameras are kept: the optimizer
+// didn't know about the cameras that weren't fixed, but they may have been placed since.
+func CandidateLayout(workspace messages_cameras.Cameras, candidate messages_cameras.Cameras) messages_cameras.Cameras {
+	layout := maps.Clone(workspace)
+	if layout == nil {
+		layout = make(messages_cameras.Cameras, len(candidate))
+	}
+	maps.Copy(layout, candidate)
+	return layout
+}
+
+// Compares cameras by id, changed fields are named by their json name
+func DiffCameras(current messages_cameras.Cameras, next messages_cameras.Cameras) (messages_optimization.CamerasDiff, error) {
+	result := messages_optimization.CamerasDiff{
+		Added:     []messages_optimization.CameraEntry{},
+		Removed:   []messages_optimization.CameraEntry{},
+		Changed:   []messages_optimization.CameraChange{},
+		Unchanged: []messages_cameras.CamId{},
+	}
+
+	changes, err := merge.DiffDocuments(current, next)
+	if err != nil {
+		return messages_optimization.CamerasDiff{}, err
+	}
+	for _, change := range changes {
+		id := messages_cameras.CamId(change.Id)
+		switch change.Change {
+		case merge.ChangeAdded:
+			result.Added = append(result.Added, messages_optimization.CameraEntry{ID: id, Camera: next[id]})
+		case merge.ChangeRemoved:
+			result.Removed = append(result.Removed, messages_optimization.CameraEntry{ID: id, Camera: current[id]})
+		default:
+			fields := []string{}
+			for _, field := range change.Fields {
+				if len(field.Path) > 0 && !slices.Contains(fields, field.Path[0]) {
+					fields = append(fields, field.Path[0])
+				}
+			}
+			result.Changed = append(result.Changed, messages_optimization.CameraChange{
+				ID:     id,
+				Fields: fields,
+				Before: current[id],
+				After:  next[id],
+			})
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := next[id]; ok && !slices.ContainsFunc(result.Changed, func(change messages_optimization.CameraChange) bool {
+			return change.ID == id
+		}) {
+			result.Unchanged = append(result.Unchanged, id)
+		}
+	}
+
+	return result, nil
+}
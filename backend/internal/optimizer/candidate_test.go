@@ -0,0 +1,116 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/optimizer"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_optimization "omnicam.com/backend/pkg/messages/optimization"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+func camAt(name string, x float64) messages_cameras.CameraStruct {
+	cam := messages_cameras.DefaultCam()
+	cam.Name = name
+	cam.PosX = x
+	return cam
+}
+
+func TestResultCameras(t *testing.T) {
+	cameras := optimizer.ResultCameras(&protobufs.SuccessOptimizationEventResp{
+		Cameras: []*protobufs.Camera{
+			{Id: "a", Name: "A", PosX: 1},
+			{Name: "B", PosX: 2},
+		},
+	})
+
+	require.Len(t, cameras, 2)
+	require.Equal(t, "A", cameras["a"].Name)
+	require.EqualValues(t, 1, cameras["a"].PosX)
+	for id, cam := range cameras {
+		require.NotEmpty(t, id)
+		if id != "a" {
+			require.Equal(t, "B", cam.Name)
+		}
+	}
+}
+
+func TestCandidateLayout(t *testing.T) {
+	workspace := messages_cameras.Cameras{
+		"fixed":   camAt("fixed", 1),
+		"other":   camAt("other", 2),
+		"replace": camAt("replace", 3),
+	}
+	candidate := messages_cameras.Cameras{
+		"new":     camAt("new", 4),
+		"replace": camAt("replace", 5),
+	}
+
+	// The cameras the optimizer didn't know about are kept
+	layout := optimizer.CandidateLayout(workspace, candidate)
+	require.Equal(t, messages_cameras.Cameras{
+		"fixed":   camAt("fixed", 1),
+		"other":   camAt("other", 2),
+		"new":     camAt("new", 4),
+		"replace": camAt("replace", 5),
+	}, layout)
+	require.Len(t, workspace, 3)
+}
+
+func TestCandidateLayoutEmptyWorkspace(t *testing.T) {
+	layout := optimizer.CandidateLayout(nil, messages_cameras.Cameras{"new": camAt("new", 1)})
+	require.Equal(t, messages_cameras.Cameras{"new": camAt("new", 1)}, layout)
+}
+
+func TestDiffCameras(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		current messages_cameras.Cameras
+		next    messages_cameras.Cameras
+		check   func(t *testing.T, d messages_optimization.CamerasDiff)
+	}{
+		{
+			name:    "empty",
+			current: messages_cameras.Cameras{},
+			next:    messages_cameras.Cameras{},
+			check: func(t *testing.T, d messages_optimization.CamerasDiff) {
+				require.Empty(t, d.Added)
+				require.Empty(t, d.Removed)
+				require.Empty(t, d.Changed)
+				require.Empty(t, d.Unchanged)
+			},
+		},
+		{
+			name:    "added and removed",
+			current: messages_cameras.Cameras{"old": camAt("old", 1)},
+			next:    messages_cameras.Cameras{"new": camAt("new", 1)},
+			check: func(t *testing.T, d messages_optimization.CamerasDiff) {
+				require.Equal(t, []messages_optimization.CameraEntry{{ID: "new", Camera: camAt("new", 1)}}, d.Added)
+				require.Equal(t, []messages_optimization.CameraEntry{{ID: "old", Camera: camAt("old", 1)}}, d.Removed)
+			},
+		},
+		{
+			name:    "changed and unchanged",
+			current: messages_cameras.Cameras{"a": camAt("a", 1), "b": camAt("b", 1)},
+			next:    messages_cameras.Cameras{"a": camAt("a", 2), "b": camAt("b", 1)},
+			check: func(t *testing.T, d messages_optimization.CamerasDiff) {
+				require.Len(t, d.Changed, 1)
+				require.Equal(t, messages_cameras.CamId("a"), d.Changed[0].ID)
+				require.Equal(t, []string{"posX"}, d.Changed[0].Fields)
+				require.Equal(t, []messages_cameras.CamId{"b"}, d.Unchanged)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d, err := optimizer.DiffCameras(tt.current, tt.next)
+			require.NoError(t, err)
+			tt.check(t, d)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Runs quick layout jobs on Local, and the other jobs on Primary. Jobs that Primary can't
+// take, e.g. when no optimizer is running, are run on Local instead of timing out.
+type FallbackJobQueue struct {
+	Logger  *zap.Logger
+	Primary JobQueue
+	Local   JobQueue
+}
+
+func (q *FallbackJobQueue) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*JobWaiter, error) {
+	if job.GetParams().GetAlgorithm() == protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_QUICK_LAYOUT {
+		return q.Local.Enqueue(ctx, job)
+	}
+
+	waiter, err := q.Primary.Enqueue(ctx, job)
+	if err == nil {
+		return waiter, nil
+	}
+
+	q.Logger.Warn("falling back to the local optimizer", zap.String("job_id", job.GetJobId()), zap.Error(err))
+	return q.Local.Enqueue(ctx, job)
+}
+
+func (q *FallbackJobQueue) Release(jobId string) {
+	q.Primary.Release(jobId)
+	q.Local.Release(jobId)
+}
+
+// The queue running the job isn't tracked, so both are asked to cancel it
+func (q *FallbackJobQueue) Cancel(ctx context.Context, jobId string) error {
+	return errors.Join(q.Primary.Cancel(ctx, jobId), q.Local.Cancel(ctx, jobId))
+}
@@ -0,0 +1,75 @@
+package optimizer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+type failingQueue struct {
+	enqueued int
+}
+
+func (q *failingQueue) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*optimizer.JobWaiter, error) {
+	q.enqueued++
+	return nil, optimizer.ErrNoOptimizer
+}
+
+func (q *failingQueue) Release(jobId string) {}
+
+func (q *failingQueue) Cancel(ctx context.Context, jobId string) error {
+	return nil
+}
+
+func TestFallbackJobQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := func(ctx context.Context, job *protobufs.OptimizeJob, progress func(*protobufs.OptimizationEventResp)) *protobufs.OptimizationEventResp {
+		return &protobufs.OptimizationEventResp{
+			Payload: &protobufs.OptimizationEventResp_SuccessResp{
+				SuccessResp: &protobufs.SuccessOptimizationEventResp{},
+			},
+		}
+	}
+	local := optimizer.NewMemoryJobQueue(zap.NewNop(), &fakeStore{}, handler, 1)
+	require.NoError(t, local.Start(ctx))
+
+	primary := &failingQueue{}
+	queue := &optimizer.FallbackJobQueue{Logger: zap.NewNop(), Primary: primary, Local: local}
+
+	tests := []struct {
+		name      string
+		algorithm protobufs.OptimizationAlgorithm
+		enqueued  int
+	}{
+		{
+			name:      "quick layout runs locally",
+			algorithm: protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_QUICK_LAYOUT,
+			enqueued:  0,
+		},
+		{
+			name:      "falls back when the primary queue fails",
+			algorithm: protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_DIFFERENTIAL_EVOLUTION,
+			enqueued:  1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			primary.enqueued = 0
+			waiter, err := queue.Enqueue(ctx, &protobufs.OptimizeJob{
+				JobId:  uuid.NewString(),
+				Params: &protobufs.OptimizeJobParams{Algorithm: tc.algorithm},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, receive(t, waiter.Result).GetSuccessResp())
+			require.Equal(t, tc.enqueued, primary.enqueued)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Greedy camera placement, a port of assign_faces and look_at_quaternion of the Python optimizer.
+// Faces are clustered around one seed face per camera, then every camera is put in front of its
+// faces, far enough to see all of them, and looks at their center. It takes milliseconds, but
+// ignores occlusion, apart from keeping cameras above the floor, and doesn't optimize anything.
+package optimizer_greedy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+
+	"github.com/google/uuid"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+const (
+	// Extra distance so that faces are not on the border of the frame
+	frameMargin = 1.2
+	// Faces seen by less pixels than this are penalized, like in assign_faces
+	minPixels = 50
+)
+
+var ErrNoFaces = errors.New("no coverage faces")
+
+type face struct {
+	points []vec3
+	center vec3
+	normal vec3
+}
+
+type camera struct {
+	config *protobufs.CameraConfig
+	fixed  bool
+	pos    vec3
+	angle  quaternion
+	faces  []int
+}
+
+func toFaces(coverageFaces []*protobufs.CoverageFace) []face {
+	faces := make([]face, 0, len(coverageFaces))
+	for _, coverageFace := range coverageFaces {
+		f := face{}
+		for _, p := range coverageFace.GetPoints() {
+			f.points = append(f.points, vec3{p.GetX(), p.GetY(), p.GetZ()})
+		}
+		if len(f.points) == 0 {
+			continue
+		}
+
+		for _, p := range f.points {
+			f.center = f.center.add(p)
+		}
+		f.center = f.center.scale(1 / float64(len(f.points)))
+
+		if len(f.points) >= 3 {
+			f.normal = f.points[1].sub(f.points[0]).cross(f.points[2].sub(f.points[0])).normalize()
+		}
+		faces = append(faces, f)
+	}
+	return faces
+}
+
+func toCameras(job *protobufs.OptimizeJob) []*camera {
+	cameras := []*camera{}
+	for _, fixed := range job.GetFixedCameras() {
+		cameras = append(cameras, &camera{
+			config: &protobufs.CameraConfig{
+				Name:      fixed.GetName(),
+				Fov:       fixed.GetFov(),
+				WidthRes:  fixed.GetWidthRes(),
+				HeightRes: fixed.GetHeightRes(),
+			},
+			fixed: true,
+			pos:   vec3{fixed.GetPosX(), fixed.GetPosY(), fixed.GetPosZ()},
+			angle: quaternion{W: fixed.GetAngleW(), X: fixed.GetAngleX(), Y: fixed.GetAngleY(), Z: fixed.GetAngleZ()},
+		})
+	}
+	for _, config := range job.GetCameraConfigs() {
+		for range optimizer.CameraAmount(config) {
+			cameras = append(cameras, &camera{config: config})
+		}
+	}
+	return cameras
+}
+
+func nearestFace(faces []face, pos vec3) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, f := range faces {
+		if d := f.center.sub(pos).norm(); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// K-means++ seeding, fixed cameras are seeded at the face closest to them
+func seedFaces(faces []face, cameras []*camera, rng *rand.Rand) []int {
+	seeds := make([]int, len(cameras))
+	chosen := []int{}
+	for i, cam := range cameras {
+		if cam.fixed {
+			seeds[i] = nearestFace(faces, cam.pos)
+			chosen = append(chosen, seeds[i])
+		}
+	}
+
+	for i, cam := range cameras {
+		if cam.fixed {
+			continue
+		}
+
+		weights := make([]float64, len(faces))
+		sum := 0.0
+		if len(chosen) > 0 {
+			for f := range faces {
+				minDist := math.Inf(1)
+				for _, c := range chosen {
+					minDist = min(minDist, faces[f].center.sub(faces[c].center).norm())
+				}
+				weights[f] = minDist * minDist
+				sum += weights[f]
+			}
+		}
+
+		idx := rng.IntN(len(faces))
+		if sum > 0 {
+			target := rng.Float64() * sum
+			for f, w := range weights {
+				target -= w
+				if target < 0 {
+					idx = f
+					break
+				}
+			}
+		}
+
+		seeds[i] = idx
+		chosen = append(chosen, idx)
+	}
+	return seeds
+}
+
+// Distance from the seed, penalized when the face looks away from it or doesn't fit the camera
+func assignmentCost(f face, seed face, cam *camera, scale float64) float64 {
+	vfov := cam.config.GetFov() * math.Pi / 180
+	distance := f.center.sub(seed.center).norm() + 1e-6
+
+	cosSim := f.normal.dot(seed.normal)
+	normPenalty := 1 - cosSim
+	if cosSim < 0 {
+		normPenalty = 100
+	}
+
+	angularSize := 2 * math.Atan(scale/(2*distance))
+	fovPenalty := 1.0
+	if angularSize > vfov {
+		fovPenalty = 10
+	}
+
+	resPenalty := 1.0
+	if vfov > 0 && angularSize/vfov*cam.config.GetHeightRes() < minPixels {
+		resPenalty = 5
+	}
+
+	return distance * (1 + normPenalty*5) * fovPenalty * resPenalty
+}
+
+func assignFaces(faces []face, cameras []*camera, seeds []int, scale float64) {
+	costs := make([][]float64, len(faces))
+	for f := range faces {
+		costs[f] = make([]float64, len(cameras))
+		best := 0
+		for c, cam := range cameras {
+			costs[f][c] = assignmentCost(faces[f], faces[seeds[c]], cam, scale)
+			if costs[f][c] < costs[f][best] {
+				best = c
+			}
+		}
+		cameras[best].faces = append(cameras[best].faces, f)
+	}
+
+	// Cameras without faces take their cheapest face
+	for c, cam := range cameras {
+		if len(cam.faces) > 0 {
+			continue
+		}
+		best := 0
+		for f := range faces {
+			if costs[f][c] < costs[best][c] {
+				best = f
+			}
+		}
+		cam.faces = append(cam.faces, best)
+	}
+}
+
+// Puts the camera in front of its faces, at the distance where they all fit in the frame
+func place(cam *camera, faces []face, scale float64, mesh *protobufs.ModelMesh) {
+	center, normal := vec3{}, vec3{}
+	for _, f := range cam.faces {
+		center = center.add(faces[f].center)
+		normal = normal.add(faces[f].normal)
+	}
+	center = center.scale(1 / float64(len(cam.faces)))
+	normal = normal.normalize()
+	if normal == (vec3{}) {
+		normal = vec3{0, 1, 0}
+	}
+
+	radius := 0.0
+	for _, f := range cam.faces {
+		for _, p := range faces[f].points {
+			radius = max(radius, p.sub(center).norm())
+		}
+	}
+	radius = max(radius, scale/2)
+
+	vfov := cam.config.GetFov() * math.Pi / 180
+	if vfov <= 0 || vfov >= math.Pi {
+		vfov = math.Pi / 2
+	}
+	fov := vfov
+	if w, h := cam.config.GetWidthRes(), cam.config.GetHeightRes(); w > 0 && h > 0 && w < h {
+		// Portrait frames are narrower horizontally
+		fov = 2 * math.Atan(math.Tan(vfov/2)*w/h)
+	}
+
+	distance := radius / math.Tan(fov/2) * frameMargin
+	cam.pos = center.add(normal.scale(distance))
+	if floor := -mesh.GetModelHeight(); mesh != nil && cam.pos[1] < floor {
+		// Faces looking down would put the camera under the floor
+		cam.pos[1] = floor
+	}
+	cam.angle = lookAt(center.sub(cam.pos))
+}
+
+func toProtoCamera(cam *camera) *protobufs.Camera {
+	return &protobufs.Camera{
+		Id:              uuid.NewString(),
+		Name:            cam.config.GetName(),
+		AngleW:          cam.angle.W,
+		AngleX:          cam.angle.X,
+		AngleY:          cam.angle.Y,
+		AngleZ:          cam.angle.Z,
+		PosX:            cam.pos[0],
+		PosY:            cam.pos[1],
+		PosZ:            cam.pos[2],
+		Fov:             cam.config.GetFov(),
+		WidthRes:        cam.config.GetWidthRes(),
+		HeightRes:       cam.config.GetHeightRes(),
+		FrustumLength:   10,
+		IsHidingFrustum: true,
+		FrustumColor: &protobufs.ColorRGBA{
+			R: 0.5,
+			G: 0.5,
+			B: 0.5,
+			A: 0.5,
+		},
+		Distortion: &protobufs.Distortion{
+			Enabled: true,
+		},
+	}
+}
+
+// Places the cameras of the job, fixed cameras are accounted for but not returned
+func Layout(job *protobufs.OptimizeJob) ([]*protobufs.Camera, error) {
+	faces := toFaces(job.GetCoverageFaces())
+	if len(faces) == 0 {
+		return nil, ErrNoFaces
+	}
+
+	cameras := toCameras(job)
+	seed := uint64(job.GetParams().GetSeed())
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	seeds := seedFaces(faces, cameras, rng)
+	assignFaces(faces, cameras, seeds, job.GetScale())
+
+	placed := []*protobufs.Camera{}
+	for _, cam := range cameras {
+		if cam.fixed {
+			continue
+		}
+		place(cam, faces, job.GetScale(), job.GetMesh())
+		placed = append(placed, toProtoCamera(cam))
+	}
+	return placed, nil
+}
+
+// optimizer.JobHandler running Layout, it is fast enough to not report progress
+func Handle(ctx context.Context, job *protobufs.OptimizeJob, progress func(*protobufs.OptimizationEventResp)) *protobufs.OptimizationEventResp {
+	cameras, err := Layout(job)
+	if err != nil {
+		return &protobufs.OptimizationEventResp{
+			JobId: job.GetJobId(),
+			Payload: &protobufs.OptimizationEventResp_ErrorResp{
+				ErrorResp: &protobufs.ErrorOptimizationEventResp{
+					Error: err.Error(),
+				},
+			},
+		}
+	}
+
+	return &protobufs.OptimizationEventResp{
+		JobId: job.GetJobId(),
+		Payload: &protobufs.OptimizationEventResp_SuccessResp{
+			SuccessResp: &protobufs.SuccessOptimizationEventResp{
+				Cameras: cameras,
+			},
+		},
+	}
+}
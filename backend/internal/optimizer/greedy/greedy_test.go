@@ -0,0 +1,133 @@
+package optimizer_greedy_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	optimizer_greedy "omnicam.com/backend/internal/optimizer/greedy"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Square of side 2 centered on center, its normal is +X or -X depending on facing
+func square(center [3]float64, facing float64) *protobufs.CoverageFace {
+	points := [][3]float64{{0, -1, -1}, {0, 1, -1}, {0, 1, 1}, {0, -1, 1}}
+	face := &protobufs.CoverageFace{}
+	for _, p := range points {
+		face.Points = append(face.Points, &protobufs.ProtoVector3{
+			X: center[0],
+			Y: center[1] + p[1],
+			Z: center[2] + p[2]*facing,
+		})
+	}
+	return face
+}
+
+// Direction of the local -Z axis of the camera
+func forward(cam *protobufs.Camera) [3]float64 {
+	w, x, y, z := cam.AngleW, cam.AngleX, cam.AngleY, cam.AngleZ
+	return [3]float64{
+		-2 * (x*z + w*y),
+		-2 * (y*z - w*x),
+		-(1 - 2*(x*x+y*y)),
+	}
+}
+
+func requireLooksAt(t *testing.T, cam *protobufs.Camera, target [3]float64) {
+	t.Helper()
+	dir := [3]float64{target[0] - cam.PosX, target[1] - cam.PosY, target[2] - cam.PosZ}
+	norm := math.Sqrt(dir[0]*dir[0] + dir[1]*dir[1] + dir[2]*dir[2])
+	f := forward(cam)
+	for i := range dir {
+		require.InDelta(t, dir[i]/norm, f[i], 1e-6)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	job := &protobufs.OptimizeJob{
+		CoverageFaces: []*protobufs.CoverageFace{
+			square([3]float64{10, 0, 0}, 1),
+			square([3]float64{-10, 0, 0}, -1),
+		},
+		CameraConfigs: []*protobufs.CameraConfig{
+			{Name: "cam", Fov: 60, WidthRes: 1920, HeightRes: 1080, Amount: 2},
+		},
+		Scale:  1,
+		Params: &protobufs.OptimizeJobParams{Seed: 2000},
+	}
+
+	cameras, err := optimizer_greedy.Layout(job)
+	require.NoError(t, err)
+	require.Len(t, cameras, 2)
+
+	seen := map[float64]bool{}
+	for _, cam := range cameras {
+		require.Equal(t, "cam", cam.Name)
+		// Each camera is in front of one face, outside of the faces
+		require.Greater(t, math.Abs(cam.PosX), 10.0)
+		require.InDelta(t, 0, cam.PosY, 1e-9)
+		require.InDelta(t, 0, cam.PosZ, 1e-9)
+		requireLooksAt(t, cam, [3]float64{math.Copysign(10, cam.PosX), 0, 0})
+		seen[math.Copysign(1, cam.PosX)] = true
+	}
+	require.Len(t, seen, 2)
+
+	again, err := optimizer_greedy.Layout(job)
+	require.NoError(t, err)
+	for i := range cameras {
+		require.Equal(t, cameras[i].PosX, again[i].PosX)
+	}
+}
+
+func TestLayoutKeepsFixedCameras(t *testing.T) {
+	job := &protobufs.OptimizeJob{
+		CoverageFaces: []*protobufs.CoverageFace{
+			square([3]float64{10, 0, 0}, 1),
+			square([3]float64{-10, 0, 0}, -1),
+		},
+		CameraConfigs: []*protobufs.CameraConfig{
+			{Name: "new", Fov: 60, WidthRes: 1920, HeightRes: 1080},
+		},
+		FixedCameras: []*protobufs.Camera{
+			{Id: "fixed", Name: "fixed", PosX: 15, Fov: 60, WidthRes: 1920, HeightRes: 1080, AngleW: 1},
+		},
+		Scale: 1,
+	}
+
+	cameras, err := optimizer_greedy.Layout(job)
+	require.NoError(t, err)
+	require.Len(t, cameras, 1)
+	require.Equal(t, "new", cameras[0].Name)
+	// The face next to the fixed camera is left to it
+	require.Less(t, cameras[0].PosX, -10.0)
+}
+
+func TestHandle(t *testing.T) {
+	resp := optimizer_greedy.Handle(t.Context(), &protobufs.OptimizeJob{JobId: "job"}, nil)
+	require.Equal(t, "job", resp.GetJobId())
+	require.NotEmpty(t, resp.GetErrorResp().GetError())
+
+	resp = optimizer_greedy.Handle(t.Context(), &protobufs.OptimizeJob{
+		JobId:         "job",
+		CoverageFaces: []*protobufs.CoverageFace{square([3]float64{0, 0, 0}, 1)},
+		CameraConfigs: []*protobufs.CameraConfig{{Name: "cam", Fov: 60}},
+	}, nil)
+	require.Len(t, resp.GetSuccessResp().GetCameras(), 1)
+}
+
+func TestLayoutStaysAboveFloor(t *testing.T) {
+	// Faces the floor, the camera would be put under it
+	floorFace := &protobufs.CoverageFace{Points: []*protobufs.ProtoVector3{
+		{X: -1, Y: 0, Z: -1}, {X: 1, Y: 0, Z: -1}, {X: 1, Y: 0, Z: 1}, {X: -1, Y: 0, Z: 1},
+	}}
+	job := &protobufs.OptimizeJob{
+		CoverageFaces: []*protobufs.CoverageFace{floorFace},
+		CameraConfigs: []*protobufs.CameraConfig{{Name: "cam", Fov: 60}},
+		Mesh:          &protobufs.ModelMesh{ModelHeight: 0.5},
+	}
+
+	cameras, err := optimizer_greedy.Layout(job)
+	require.NoError(t, err)
+	require.Len(t, cameras, 1)
+	require.GreaterOrEqual(t, cameras[0].PosY, -0.5)
+}
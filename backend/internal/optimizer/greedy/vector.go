@@ -0,0 +1,80 @@
+package optimizer_greedy
+
+import "math"
+
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3 {
+	return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a vec3) scale(s float64) vec3 {
+	return vec3{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func (a vec3) norm() float64 {
+	return math.Sqrt(a.dot(a))
+}
+
+// Zero vector for degenerate input, like normal_vec_of_face
+func (a vec3) normalize() vec3 {
+	n := a.norm()
+	if n < 1e-12 {
+		return vec3{}
+	}
+	return a.scale(1 / n)
+}
+
+type quaternion struct {
+	W, X, Y, Z float64
+}
+
+// Rotation whose local -Z points along forward, with +Y as up (same as look_at_quaternion)
+func lookAt(forward vec3) quaternion {
+	f := forward.normalize()
+	r := f.cross(vec3{0, 1, 0})
+	if r.norm() < 1e-6 {
+		// Looking straight up or down
+		r = vec3{1, 0, 0}
+	}
+	r = r.normalize()
+	u := r.cross(f)
+	b := f.scale(-1)
+
+	// Columns of the rotation matrix are right, up and backward
+	m00, m01, m02 := r[0], u[0], b[0]
+	m10, m11, m12 := r[1], u[1], b[1]
+	m20, m21, m22 := r[2], u[2], b[2]
+
+	trace := m00 + m11 + m22
+	switch {
+	case trace > 0:
+		s := 0.5 / math.Sqrt(trace+1)
+		return quaternion{W: 0.25 / s, X: (m21 - m12) * s, Y: (m02 - m20) * s, Z: (m10 - m01) * s}
+	case m00 > m11 && m00 > m22:
+		s := 2 * math.Sqrt(1+m00-m11-m22)
+		return quaternion{W: (m21 - m12) / s, X: 0.25 * s, Y: (m01 + m10) / s, Z: (m02 + m20) / s}
+	case m11 > m22:
+		s := 2 * math.Sqrt(1+m11-m00-m22)
+		return quaternion{W: (m02 - m20) / s, X: (m01 + m10) / s, Y: 0.25 * s, Z: (m12 + m21) / s}
+	default:
+		s := 2 * math.Sqrt(1+m22-m00-m11)
+		return quaternion{W: (m10 - m01) / s, X: (m02 + m20) / s, Y: (m12 + m21) / s, Z: 0.25 * s}
+	}
+}
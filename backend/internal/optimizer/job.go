@@ -0,0 +1,31 @@
+package optimizer
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Version of OptimizeJob and OptimizeJobResult, must match the optimizer
+const SchemaVersion = 1
+
+func EncodeJob(job *protobufs.OptimizeJob) ([]byte, error) {
+	job.SchemaVersion = SchemaVersion
+	return proto.Marshal(job)
+}
+
+// Decodes a result published by the optimizer, results of another schema version are rejected
+func DecodeResult(data []byte) (*protobufs.OptimizationEventResp, error) {
+	result := &protobufs.OptimizeJobResult{}
+	if err := proto.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	if result.GetSchemaVersion() != SchemaVersion {
+		return nil, fmt.Errorf("%w: schema version %d, expected %d", errMalformed, result.GetSchemaVersion(), SchemaVersion)
+	}
+	if result.GetResp() == nil {
+		return nil, fmt.Errorf("%w: missing response", errMalformed)
+	}
+	return result.GetResp(), nil
+}
@@ -0,0 +1,60 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+func TestDecodeResult(t *testing.T) {
+	resp := optimizer.CancelledResponse("job")
+
+	marshal := func(result *protobufs.OptimizeJobResult) []byte {
+		bytes, err := proto.Marshal(result)
+		require.NoError(t, err)
+		return bytes
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "current version",
+			data: marshal(&protobufs.OptimizeJobResult{SchemaVersion: optimizer.SchemaVersion, Resp: resp}),
+		},
+		{
+			name:    "other version",
+			data:    marshal(&protobufs.OptimizeJobResult{SchemaVersion: optimizer.SchemaVersion + 1, Resp: resp}),
+			wantErr: true,
+		},
+		{
+			name:    "missing response",
+			data:    marshal(&protobufs.OptimizeJobResult{SchemaVersion: optimizer.SchemaVersion}),
+			wantErr: true,
+		},
+		{
+			name:    "not a protobuf",
+			data:    []byte("{\"job_id\": \"job\"}"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := optimizer.DecodeResult(tc.data)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, proto.Equal(resp, got), "got %v", got)
+		})
+	}
+}
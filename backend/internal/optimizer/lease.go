@@ -0,0 +1,98 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	config_env "omnicam.com/backend/config"
+)
+
+var ErrSchedulerRunning = errors.New("the optimization scheduler runs in another backend instance")
+
+// Lifetime of the lease, it outlives a stopped instance by at most this long
+const schedulerLeaseTTL = 30 * time.Second
+
+// Extends the lease when it is still held by this instance
+var renewLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Keeps a single Scheduler across the backend instances sharing Redis, the instance holding the
+// lease is the only one allowed to run optimizations
+type SchedulerLease struct {
+	Logger      *zap.Logger
+	Env         *config_env.AppEnv
+	RedisClient *redis.Client
+	owner       string
+}
+
+// Takes the lease and keeps it until ctx is done. The lease of a stopped instance is waited for
+// until it expires, ErrSchedulerRunning is returned when another instance still holds it.
+func (l *SchedulerLease) Acquire(ctx context.Context) error {
+	l.owner = uuid.NewString()
+
+	deadline := time.Now().Add(schedulerLeaseTTL + time.Second)
+	for {
+		taken, err := l.RedisClient.SetNX(ctx, l.Env.OptiSchedulerKey, l.owner, schedulerLeaseTTL).Result()
+		if err != nil {
+			return err
+		}
+		if taken {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrSchedulerRunning
+		}
+		l.Logger.Info("waiting for the optimization scheduler lease")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	go l.keep(ctx)
+	return nil
+}
+
+// Renews the lease well before it expires. Losing it to another instance would run two
+// schedulers, so the process stops instead.
+func (l *SchedulerLease) keep(ctx context.Context) {
+	ticker := time.NewTicker(schedulerLeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewed, err := renewLease.Run(ctx, l.RedisClient, []string{l.Env.OptiSchedulerKey}, l.owner, schedulerLeaseTTL.Milliseconds()).Int()
+		if err != nil {
+			// Renewed again on the next tick, the lease outlives a few failures
+			l.Logger.Warn("error while renewing the optimization scheduler lease", zap.Error(err))
+			continue
+		}
+		if renewed == 1 {
+			continue
+		}
+
+		// Expired, e.g. after Redis restarted, it is taken back unless another instance has it
+		taken, err := l.RedisClient.SetNX(ctx, l.Env.OptiSchedulerKey, l.owner, schedulerLeaseTTL).Result()
+		if err != nil {
+			l.Logger.Warn("error while taking back the optimization scheduler lease", zap.Error(err))
+			continue
+		}
+		if !taken {
+			l.Logger.Fatal("lost the optimization scheduler lease to another backend instance")
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	config_env "omnicam.com/backend/config"
+	db_client "omnicam.com/backend/pkg/db"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+const (
+	readBlock = 5 * time.Second
+	readCount = 10
+
+	// entries pending longer than this are considered abandoned by their consumer
+	claimMinIdle   = time.Minute
+	claimInterval  = 30 * time.Second
+	claimCount     = 50
+	maxDeliveries  = 5
+	retryOnFailure = time.Second
+)
+
+// errMalformed marks a response that can never be handled, it is moved to the dead-letter stream
+var errMalformed = errors.New("malformed optimization response")
+
+// Consumes the optimizer response stream through a consumer group, so that several
+// backend replicas can share it. Every response is persisted, then broadcast to all
+// replicas so that the one holding the websocket of the job can forward it.
+type ResponseListener struct {
+	Logger           *zap.Logger
+	Env              *config_env.AppEnv
+	DB               *db_client.DB
+	RedisClient      *redis.Client
+	ResponseRegistry *sync.Map
+	consumer         string
+}
+
+func (l *ResponseListener) Start(ctx context.Context) error {
+	l.consumer = l.Env.OptiResConsumer
+	if l.consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = uuid.NewString()
+		}
+		l.consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	// Start from the beginning of the stream, finished jobs are ignored while persisting
+	err := l.RedisClient.XGroupCreateMkStream(ctx, l.Env.OptiResTopic, l.Env.OptiResGroup, "0").Err()
+	if err != nil && !redis.HasErrorPrefix(err, "BUSYGROUP") {
+		return err
+	}
+
+	pubsub := l.RedisClient.Subscribe(ctx, l.Env.OptiDoneChannel)
+	// Wait for the subscription, so no response is published before we listen
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go l.deliverLoop(ctx, pubsub)
+	go l.readLoop(ctx)
+	go l.reclaimLoop(ctx)
+	go l.progressLoop(ctx)
+
+	return nil
+}
+
+func (l *ResponseListener) readLoop(ctx context.Context) {
+	for ctx.Err() == nil {
+		entries, err := l.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    l.Env.OptiResGroup,
+			Consumer: l.consumer,
+			Streams:  []string{l.Env.OptiResTopic, ">"},
+			Count:    readCount,
+			Block:    readBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			l.Logger.Error("error while reading optimization responses", zap.Error(err))
+			time.Sleep(retryOnFailure)
+			continue
+		}
+
+		for _, stream := range entries {
+			for _, msg := range stream.Messages {
+				l.process(ctx, msg)
+			}
+		}
+	}
+}
+
+// Takes over entries delivered to consumers that died or failed to handle them
+func (l *ResponseListener) reclaimLoop(ctx context.Context) {
+	ticker := time.NewTicker(claimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := "0-0"
+		for {
+			msgs, next, err := l.RedisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   l.Env.OptiResTopic,
+				Group:    l.Env.OptiResGroup,
+				Consumer: l.consumer,
+				MinIdle:  claimMinIdle,
+				Start:    start,
+				Count:    claimCount,
+			}).Result()
+			if err != nil {
+				l.Logger.Error("error while reclaiming optimization responses", zap.Error(err))
+				break
+			}
+
+			for _, msg := range msgs {
+				if l.deliveryCount(ctx, msg.ID) > maxDeliveries {
+					l.deadLetter(ctx, msg, "too many deliveries")
+					continue
+				}
+				l.process(ctx, msg)
+			}
+
+			if next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+func (l *ResponseListener) deliveryCount(ctx context.Context, id string) int64 {
+	pending, err := l.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: l.Env.OptiResTopic,
+		Group:  l.Env.OptiResGroup,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 0
+	}
+	return pending[0].RetryCount
+}
+
+// Handles a single entry, it is only acked once handled so failures are retried by the reclaim loop
+func (l *ResponseListener) process(ctx context.Context, msg redis.XMessage) {
+	resp, err := l.persist(ctx, msg.Values)
+	if errors.Is(err, errMalformed) {
+		l.deadLetter(ctx, msg, err.Error())
+		return
+	}
+	if err != nil {
+		l.Logger.Error("error while handling optimization response", zap.String("id", msg.ID), zap.Error(err))
+		return
+	}
+
+	if resp != nil {
+		bytes, err := proto.Marshal(resp)
+		if err != nil {
+			l.Logger.Error("error marshalling optimization response", zap.String("id", msg.ID), zap.Error(err))
+			return
+		}
+		if err := l.RedisClient.Publish(ctx, l.Env.OptiDoneChannel, bytes).Err(); err != nil {
+			l.Logger.Error("error while publishing optimization response", zap.String("id", msg.ID), zap.Error(err))
+			return
+		}
+	}
+
+	l.ack(ctx, msg.ID)
+}
+
+func (l *ResponseListener) ack(ctx context.Context, id string) {
+	err := l.RedisClient.XAck(ctx, l.Env.OptiResTopic, l.Env.OptiResGroup, id).Err()
+	if err != nil {
+		l.Logger.Error("error while acking optimization response", zap.String("id", id), zap.Error(err))
+	}
+}
+
+func (l *ResponseListener) deadLetter(ctx context.Context, msg redis.XMessage, reason string) {
+	values := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["reason"] = reason
+
+	err := l.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: l.Env.OptiDeadLetterTopic,
+		Values: values,
+	}).Err()
+	if err != nil {
+		// Keep it pending, it will be reclaimed later
+		l.Logger.Error("error while dead-lettering optimization response", zap.String("id", msg.ID), zap.Error(err))
+		return
+	}
+
+	l.Logger.Warn("optimization response moved to dead-letter stream", zap.String("id", msg.ID), zap.String("reason", reason))
+	l.ack(ctx, msg.ID)
+}
+
+// Persists the optimizer result of a job and returns the response to forward to the waiting websocket
+func (l *ResponseListener) persist(ctx context.Context, values map[string]interface{}) (*protobufs.OptimizationEventResp, error) {
+	data, ok := values["data"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing data", errMalformed)
+	}
+
+	resp, err := DecodeResult([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	finished, err := finishJob(ctx, l.DB.Queries, resp)
+	if err != nil {
+		return nil, err
+	}
+	if !finished {
+		// Already handled by another delivery, nobody is waiting anymore
+		l.Logger.Warn("optimization job not found or already finished", zap.String("job_id", resp.GetJobId()))
+		return nil, nil
+	}
+
+	return resp, nil
+}
+
+// Forwards responses published by any replica to the handler waiting in this process, if any
+func (l *ResponseListener) deliverLoop(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			resp := &protobufs.OptimizationEventResp{}
+			if err := proto.Unmarshal([]byte(msg.Payload), resp); err != nil {
+				l.Logger.Error("error unmarshalling optimization response", zap.Error(err))
+				continue
+			}
+
+			// Find the waiting handler
+			if waiter, ok := l.ResponseRegistry.LoadAndDelete(resp.GetJobId()); ok {
+				waiter.(*JobWaiter).Result <- resp
+			}
+		}
+	}
+}
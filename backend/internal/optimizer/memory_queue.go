@@ -0,0 +1,196 @@
+package optimizer
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Jobs waiting for a worker of the in-memory queue
+const memoryQueueSize = 64
+
+// Runs a job in the backend process. It returns the final response of the job, and may
+// report progress any number of times. It must return once ctx is done.
+type JobHandler func(ctx context.Context, job *protobufs.OptimizeJob, progress func(*protobufs.OptimizationEventResp)) *protobufs.OptimizationEventResp
+
+type memoryJob struct {
+	waiter *JobWaiter
+	// nil while the job is queued
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// Runs jobs in the backend process with a pool of workers, without Redis.
+// Jobs are lost on restart, and only the replica that enqueued a job sees it.
+type MemoryJobQueue struct {
+	logger  *zap.Logger
+	store   JobStore
+	handler JobHandler
+	workers int
+
+	queue chan *protobufs.OptimizeJob
+	mu    sync.Mutex
+	jobs  map[string]*memoryJob
+}
+
+func NewMemoryJobQueue(logger *zap.Logger, store JobStore, handler JobHandler, workers int) *MemoryJobQueue {
+	return &MemoryJobQueue{
+		logger:  logger,
+		store:   store,
+		handler: handler,
+		workers: max(workers, 1),
+		queue:   make(chan *protobufs.OptimizeJob, memoryQueueSize),
+		jobs:    make(map[string]*memoryJob),
+	}
+}
+
+func (q *MemoryJobQueue) Start(ctx context.Context) error {
+	for range q.workers {
+		go q.work(ctx)
+	}
+	return nil
+}
+
+func (q *MemoryJobQueue) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*JobWaiter, error) {
+	// Copied so that the job can't be modified by the caller while running
+	job = proto.Clone(job).(*protobufs.OptimizeJob)
+	job.SchemaVersion = SchemaVersion
+
+	waiter := NewJobWaiter()
+
+	q.mu.Lock()
+	q.jobs[job.GetJobId()] = &memoryJob{waiter: waiter}
+	q.mu.Unlock()
+
+	select {
+	case q.queue <- job:
+		return waiter, nil
+	default:
+		q.mu.Lock()
+		delete(q.jobs, job.GetJobId())
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+}
+
+func (q *MemoryJobQueue) Release(jobId string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job, ok := q.jobs[jobId]; ok {
+		job.waiter = nil
+	}
+}
+
+func (q *MemoryJobQueue) Cancel(ctx context.Context, jobId string) error {
+	q.mu.Lock()
+	job, ok := q.jobs[jobId]
+	if !ok {
+		q.mu.Unlock()
+		return nil
+	}
+
+	job.cancelled = true
+	if job.cancel != nil {
+		job.cancel()
+	} else {
+		// Not picked by a worker yet, it is skipped
+		delete(q.jobs, jobId)
+	}
+	waiter := job.waiter
+	job.waiter = nil
+	q.mu.Unlock()
+
+	if waiter != nil {
+		waiter.Result <- CancelledResponse(jobId)
+	}
+	return nil
+}
+
+func (q *MemoryJobQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.queue:
+			q.run(ctx, job)
+		}
+	}
+}
+
+func (q *MemoryJobQueue) run(ctx context.Context, job *protobufs.OptimizeJob) {
+	jobId := job.GetJobId()
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	state, ok := q.jobs[jobId]
+	if !ok || state.cancelled {
+		q.mu.Unlock()
+		return
+	}
+	state.cancel = cancel
+	q.mu.Unlock()
+
+	var resp *protobufs.OptimizationEventResp
+	if q.handler == nil {
+		resp = errorResponse(jobId, "no optimizer is running in this process")
+	} else {
+		resp = q.handler(jobCtx, job, func(progress *protobufs.OptimizationEventResp) {
+			q.progress(jobId, progress)
+		})
+	}
+
+	q.mu.Lock()
+	delete(q.jobs, jobId)
+	cancelled := state.cancelled
+	waiter := state.waiter
+	q.mu.Unlock()
+
+	// The cancelled response was already sent by Cancel
+	if cancelled || ctx.Err() != nil {
+		return
+	}
+
+	if resp == nil {
+		resp = errorResponse(jobId, "optimizer returned no result")
+	}
+	resp.JobId = jobId
+
+	finished, err := finishJob(ctx, q.store, resp)
+	if err != nil {
+		q.logger.Error("error while persisting optimization result", zap.String("job_id", jobId), zap.Error(err))
+		return
+	}
+	if !finished {
+		q.logger.Warn("optimization job not found or already finished", zap.String("job_id", jobId))
+		return
+	}
+
+	if waiter != nil {
+		waiter.Result <- resp
+	}
+}
+
+func (q *MemoryJobQueue) progress(jobId string, resp *protobufs.OptimizationEventResp) {
+	q.mu.Lock()
+	var waiter *JobWaiter
+	if job, ok := q.jobs[jobId]; ok {
+		waiter = job.waiter
+	}
+	q.mu.Unlock()
+
+	if waiter == nil {
+		return
+	}
+
+	resp.JobId = jobId
+	select {
+	case waiter.Progress <- resp:
+	default:
+		// The websocket is behind, skip this update
+	}
+}
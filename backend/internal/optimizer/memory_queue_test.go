@@ -0,0 +1,166 @@
+package optimizer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/optimizer"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+type fakeStore struct {
+	mu         sync.Mutex
+	statuses   map[uuid.UUID]db_sqlc_gen.OptimizationJobStatus
+	candidates map[uuid.UUID][]byte
+	// Jobs left pending by a previous run
+	pending int64
+}
+
+func (s *fakeStore) FinishOptimizationJob(ctx context.Context, arg db_sqlc_gen.FinishOptimizationJobParams) (db_sqlc_gen.FinishOptimizationJobRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.statuses == nil {
+		s.statuses = make(map[uuid.UUID]db_sqlc_gen.OptimizationJobStatus)
+	}
+	s.statuses[arg.ID] = arg.Status
+	return db_sqlc_gen.FinishOptimizationJobRow{ID: arg.ID, Status: arg.Status}, nil
+}
+
+func (s *fakeStore) CreateCandidateLayout(ctx context.Context, arg db_sqlc_gen.CreateCandidateLayoutParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.candidates == nil {
+		s.candidates = make(map[uuid.UUID][]byte)
+	}
+	if _, finished := s.statuses[arg.OptimizationJobID]; !finished {
+		s.candidates[arg.OptimizationJobID] = arg.Cameras
+	}
+	return nil
+}
+
+func (s *fakeStore) FailPendingOptimizationJobs(ctx context.Context, message string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := s.pending
+	s.pending = 0
+	return failed, nil
+}
+
+func (s *fakeStore) candidate(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.candidates[uuid.MustParse(id)]
+}
+
+func (s *fakeStore) status(id string) db_sqlc_gen.OptimizationJobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[uuid.MustParse(id)]
+}
+
+func receive(t *testing.T, ch chan *protobufs.OptimizationEventResp) *protobufs.OptimizationEventResp {
+	t.Helper()
+	select {
+	case resp := <-ch:
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a response")
+		return nil
+	}
+}
+
+func TestMemoryJobQueueResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &fakeStore{}
+	handler := func(ctx context.Context, job *protobufs.OptimizeJob, progress func(*protobufs.OptimizationEventResp)) *protobufs.OptimizationEventResp {
+		progress(&protobufs.OptimizationEventResp{
+			Payload: &protobufs.OptimizationEventResp_ProgressResp{
+				ProgressResp: &protobufs.ProgressOptimizationEventResp{Iteration: 1},
+			},
+		})
+		return &protobufs.OptimizationEventResp{
+			Payload: &protobufs.OptimizationEventResp_SuccessResp{
+				SuccessResp: &protobufs.SuccessOptimizationEventResp{},
+			},
+		}
+	}
+	queue := optimizer.NewMemoryJobQueue(zap.NewNop(), store, handler, 1)
+	require.NoError(t, queue.Start(ctx))
+
+	jobId := uuid.NewString()
+	waiter, err := queue.Enqueue(ctx, &protobufs.OptimizeJob{JobId: jobId})
+	require.NoError(t, err)
+
+	progress := receive(t, waiter.Progress)
+	require.Equal(t, jobId, progress.GetJobId())
+	require.EqualValues(t, 1, progress.GetProgressResp().GetIteration())
+
+	result := receive(t, waiter.Result)
+	require.Equal(t, jobId, result.GetJobId())
+	require.NotNil(t, result.GetSuccessResp())
+	require.Equal(t, db_sqlc_gen.OptimizationJobStatusSucceeded, store.status(jobId))
+	require.NotNil(t, store.candidate(jobId))
+}
+
+func TestMemoryJobQueueCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &fakeStore{}
+	started := make(chan struct{})
+	handler := func(ctx context.Context, job *protobufs.OptimizeJob, progress func(*protobufs.OptimizationEventResp)) *protobufs.OptimizationEventResp {
+		close(started)
+		<-ctx.Done()
+		return nil
+	}
+	queue := optimizer.NewMemoryJobQueue(zap.NewNop(), store, handler, 1)
+	require.NoError(t, queue.Start(ctx))
+
+	running := uuid.NewString()
+	runningWaiter, err := queue.Enqueue(ctx, &protobufs.OptimizeJob{JobId: running})
+	require.NoError(t, err)
+	<-started
+
+	// The only worker is busy, so this one is still queued
+	queued := uuid.NewString()
+	queuedWaiter, err := queue.Enqueue(ctx, &protobufs.OptimizeJob{JobId: queued})
+	require.NoError(t, err)
+
+	require.NoError(t, queue.Cancel(ctx, queued))
+	require.NotNil(t, receive(t, queuedWaiter.Result).GetCancelledResp())
+
+	require.NoError(t, queue.Cancel(ctx, running))
+	require.NotNil(t, receive(t, runningWaiter.Result).GetCancelledResp())
+
+	// Cancelled jobs are recorded by CancelJob, not by the queue
+	require.Empty(t, store.status(running))
+	require.Empty(t, store.status(queued))
+}
+
+func TestMemoryJobQueueWithoutHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &fakeStore{}
+	queue := optimizer.NewMemoryJobQueue(zap.NewNop(), store, nil, 1)
+	require.NoError(t, queue.Start(ctx))
+
+	jobId := uuid.NewString()
+	waiter, err := queue.Enqueue(ctx, &protobufs.OptimizeJob{JobId: jobId})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, receive(t, waiter.Result).GetErrorResp().GetError())
+	require.Equal(t, db_sqlc_gen.OptimizationJobStatusFailed, store.status(jobId))
+	require.Nil(t, store.candidate(jobId))
+}
@@ -0,0 +1,21 @@
+package optimizer
+
+import (
+	"path"
+	"strings"
+
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Prefix of the web path of uploaded files, the optimizer reads them from the upload directory
+const uploadsWebPrefix = "/uploads/"
+
+// Mesh of the model with the calibration of the workspace, so the optimizer accounts for its walls and floor
+func MeshFromModel(model db_sqlc_gen.Model, scaleFactor float64, modelHeight float64) *protobufs.ModelMesh {
+	return &protobufs.ModelMesh{
+		GlbPath:     path.Clean(strings.TrimPrefix(model.FilePath, uploadsWebPrefix) + model.ModelExtension),
+		ScaleFactor: scaleFactor,
+		ModelHeight: modelHeight,
+	}
+}
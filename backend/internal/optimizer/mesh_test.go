@@ -0,0 +1,20 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/optimizer"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+func TestMeshFromModel(t *testing.T) {
+	mesh := optimizer.MeshFromModel(db_sqlc_gen.Model{
+		FilePath:       "/uploads/3d_models/project/model",
+		ModelExtension: ".glb",
+	}, 0.5, 1.2)
+
+	require.Equal(t, "3d_models/project/model.glb", mesh.GetGlbPath())
+	require.Equal(t, 0.5, mesh.GetScaleFactor())
+	require.Equal(t, 1.2, mesh.GetModelHeight())
+}
@@ -0,0 +1,94 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+const (
+	DefaultSeed          = 2000
+	DefaultMaxIterations = 500
+	MaxIterations        = 5000
+	// Must stay below the time the websocket waits for a job
+	MaxTimeLimitSeconds = 9 * 60
+)
+
+var ErrInvalidParams = errors.New("invalid optimization parameters")
+
+// Same weights as the optimizer used before they were configurable
+func DefaultCostWeights() *protobufs.CostWeights {
+	return &protobufs.CostWeights{
+		Angle:      0.18,
+		Resolution: 0.31,
+		Occlusion:  0.37,
+		Mounting:   0.14,
+	}
+}
+
+func invalidParams(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParams, fmt.Sprintf(format, args...))
+}
+
+// Validates the tuning of the request and fills the defaults
+func ParamsFromRequest(req *protobufs.OptimizationEventReq) (*protobufs.OptimizeJobParams, error) {
+	params := &protobufs.OptimizeJobParams{
+		Seed:             DefaultSeed,
+		MaxIterations:    DefaultMaxIterations,
+		TimeLimitSeconds: MaxTimeLimitSeconds,
+		CostWeights:      DefaultCostWeights(),
+	}
+
+	switch req.GetAlgorithm() {
+	case protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_DEFAULT,
+		protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_DIFFERENTIAL_EVOLUTION:
+		params.Algorithm = protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_DIFFERENTIAL_EVOLUTION
+	case protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_PARTICLE_SWARM,
+		protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_QUICK_LAYOUT:
+		params.Algorithm = req.GetAlgorithm()
+	default:
+		return nil, invalidParams("unknown algorithm %d", req.GetAlgorithm())
+	}
+
+	if req.Seed != nil {
+		params.Seed = req.GetSeed()
+	}
+
+	if req.MaxIterations != nil {
+		if req.GetMaxIterations() < 1 || req.GetMaxIterations() > MaxIterations {
+			return nil, invalidParams("max iterations must be between 1 and %d", MaxIterations)
+		}
+		params.MaxIterations = req.GetMaxIterations()
+	}
+
+	if req.TimeLimitSeconds != nil {
+		limit := req.GetTimeLimitSeconds()
+		if math.IsNaN(limit) || limit <= 0 || limit > MaxTimeLimitSeconds {
+			return nil, invalidParams("time limit must be between 0 and %d seconds", MaxTimeLimitSeconds)
+		}
+		params.TimeLimitSeconds = limit
+	}
+
+	if w := req.GetCostWeights(); w != nil {
+		sum := 0.0
+		for _, weight := range []float64{w.GetAngle(), w.GetResolution(), w.GetOcclusion(), w.GetMounting()} {
+			if math.IsNaN(weight) || math.IsInf(weight, 0) || weight < 0 {
+				return nil, invalidParams("cost weights must be finite and non-negative")
+			}
+			sum += weight
+		}
+		if sum == 0 {
+			return nil, invalidParams("at least one cost weight must be set")
+		}
+		params.CostWeights = &protobufs.CostWeights{
+			Angle:      w.GetAngle(),
+			Resolution: w.GetResolution(),
+			Occlusion:  w.GetOcclusion(),
+			Mounting:   w.GetMounting(),
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,104 @@
+package optimizer_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+func TestParamsFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *protobufs.OptimizationEventReq
+		want    *protobufs.OptimizeJobParams
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			req:  &protobufs.OptimizationEventReq{},
+			want: &protobufs.OptimizeJobParams{
+				Algorithm:        protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_DIFFERENTIAL_EVOLUTION,
+				Seed:             optimizer.DefaultSeed,
+				MaxIterations:    optimizer.DefaultMaxIterations,
+				TimeLimitSeconds: optimizer.MaxTimeLimitSeconds,
+				CostWeights:      optimizer.DefaultCostWeights(),
+			},
+		},
+		{
+			name: "custom",
+			req: &protobufs.OptimizationEventReq{
+				Algorithm:        protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_PARTICLE_SWARM,
+				Seed:             proto.Uint32(0),
+				MaxIterations:    proto.Uint32(100),
+				TimeLimitSeconds: proto.Float64(30),
+				CostWeights: &protobufs.CostWeights{
+					Angle:     1,
+					Occlusion: 2,
+				},
+			},
+			want: &protobufs.OptimizeJobParams{
+				Algorithm:        protobufs.OptimizationAlgorithm_OPTIMIZATION_ALGORITHM_PARTICLE_SWARM,
+				Seed:             0,
+				MaxIterations:    100,
+				TimeLimitSeconds: 30,
+				CostWeights: &protobufs.CostWeights{
+					Angle:     1,
+					Occlusion: 2,
+				},
+			},
+		},
+		{
+			name:    "unknown algorithm",
+			req:     &protobufs.OptimizationEventReq{Algorithm: 42},
+			wantErr: true,
+		},
+		{
+			name:    "zero iterations",
+			req:     &protobufs.OptimizationEventReq{MaxIterations: proto.Uint32(0)},
+			wantErr: true,
+		},
+		{
+			name:    "too many iterations",
+			req:     &protobufs.OptimizationEventReq{MaxIterations: proto.Uint32(optimizer.MaxIterations + 1)},
+			wantErr: true,
+		},
+		{
+			name:    "time limit too long",
+			req:     &protobufs.OptimizationEventReq{TimeLimitSeconds: proto.Float64(optimizer.MaxTimeLimitSeconds + 1)},
+			wantErr: true,
+		},
+		{
+			name:    "time limit NaN",
+			req:     &protobufs.OptimizationEventReq{TimeLimitSeconds: proto.Float64(math.NaN())},
+			wantErr: true,
+		},
+		{
+			name:    "negative weight",
+			req:     &protobufs.OptimizationEventReq{CostWeights: &protobufs.CostWeights{Angle: -1, Mounting: 2}},
+			wantErr: true,
+		},
+		{
+			name:    "all weights zero",
+			req:     &protobufs.OptimizationEventReq{CostWeights: &protobufs.CostWeights{}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := optimizer.ParamsFromRequest(tc.req)
+			if tc.wantErr {
+				require.ErrorIs(t, err, optimizer.ErrInvalidParams)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, proto.Equal(tc.want, got), "got %v", got)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Progress updates kept per job, older updates are dropped when the websocket is slower than the optimizer
+const progressBuffer = 8
+
+// Handler of a job started from this process, receives the progress and result of the job
+type JobWaiter struct {
+	Result   chan *protobufs.OptimizationEventResp
+	Progress chan *protobufs.OptimizationEventResp
+}
+
+func NewJobWaiter() *JobWaiter {
+	return &JobWaiter{
+		Result:   make(chan *protobufs.OptimizationEventResp, 1),
+		Progress: make(chan *protobufs.OptimizationEventResp, progressBuffer),
+	}
+}
+
+// Progress is only useful live, so every replica reads the whole stream (no consumer group)
+// and forwards the entries of the jobs it is waiting for.
+func (l *ResponseListener) progressLoop(ctx context.Context) {
+	lastId := "$"
+	for ctx.Err() == nil {
+		entries, err := l.RedisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{l.Env.OptiProgressTopic, lastId},
+			Count:   readCount,
+			Block:   readBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			l.Logger.Error("error while reading optimization progress", zap.Error(err))
+			time.Sleep(retryOnFailure)
+			continue
+		}
+
+		for _, stream := range entries {
+			for _, msg := range stream.Messages {
+				lastId = msg.ID
+
+				data, ok := msg.Values["data"].(string)
+				if !ok {
+					continue
+				}
+
+				resp, err := DecodeResult([]byte(data))
+				if err != nil {
+					l.Logger.Warn("invalid optimization progress", zap.String("id", msg.ID), zap.Error(err))
+					continue
+				}
+
+				waiter, ok := l.ResponseRegistry.Load(resp.GetJobId())
+				if !ok {
+					continue
+				}
+
+				select {
+				case waiter.(*JobWaiter).Progress <- resp:
+				default:
+					// The websocket is behind, skip this update
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package optimizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/encoding/protojson"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+var ErrQueueFull = errors.New("optimization queue is full")
+
+// Transport of jobs between the backend and an optimizer
+type JobQueue interface {
+	// Sends the job to the optimizer, the returned waiter receives its progress and result
+	Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*JobWaiter, error)
+	// Stops delivering to the waiter of the job, once nobody listens to it anymore
+	Release(jobId string)
+	// Asks the optimizer to stop the job, and sends the cancelled response to its waiter
+	Cancel(ctx context.Context, jobId string) error
+}
+
+// Queries recording the outcome of jobs, implemented by db_sqlc_gen.Queries
+type JobStore interface {
+	FinishOptimizationJob(ctx context.Context, arg db_sqlc_gen.FinishOptimizationJobParams) (db_sqlc_gen.FinishOptimizationJobRow, error)
+	CreateCandidateLayout(ctx context.Context, arg db_sqlc_gen.CreateCandidateLayoutParams) error
+	FailPendingOptimizationJobs(ctx context.Context, message string) (int64, error)
+}
+
+// Records the final response of a job, returns false if the job was unknown or already finished
+func finishJob(ctx context.Context, store JobStore, resp *protobufs.OptimizationEventResp) (bool, error) {
+	jobUuid, err := uuid.Parse(resp.GetJobId())
+	if err != nil {
+		return false, fmt.Errorf("%w: invalid job id %q", errMalformed, resp.GetJobId())
+	}
+
+	params := db_sqlc_gen.FinishOptimizationJobParams{
+		ID: jobUuid,
+	}
+
+	switch payload := resp.GetPayload().(type) {
+	case *protobufs.OptimizationEventResp_SuccessResp:
+		// Stored as JSON so that it can be served as is by the REST API
+		result, err := protojson.Marshal(resp)
+		if err != nil {
+			return false, err
+		}
+		params.Status = db_sqlc_gen.OptimizationJobStatusSucceeded
+		params.Result = result
+
+		// Created before the job is finished, the insert is skipped once the job isn't pending anymore
+		cameras, err := json.Marshal(ResultCameras(payload.SuccessResp))
+		if err != nil {
+			return false, err
+		}
+		err = store.CreateCandidateLayout(ctx, db_sqlc_gen.CreateCandidateLayoutParams{
+			OptimizationJobID: jobUuid,
+			Name:              "Layout " + time.Now().UTC().Format(time.DateTime),
+			Cameras:           cameras,
+		})
+		if err != nil {
+			return false, err
+		}
+	case *protobufs.OptimizationEventResp_ErrorResp:
+		params.Status = db_sqlc_gen.OptimizationJobStatusFailed
+		params.Error = pgtype.Text{String: payload.ErrorResp.GetError(), Valid: true}
+	case *protobufs.OptimizationEventResp_CancelledResp:
+		params.Status = db_sqlc_gen.OptimizationJobStatusCancelled
+	default:
+		return false, fmt.Errorf("%w: unexpected payload %T", errMalformed, payload)
+	}
+
+	_, err = store.FinishOptimizationJob(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func errorResponse(jobId string, msg string) *protobufs.OptimizationEventResp {
+	return &protobufs.OptimizationEventResp{
+		JobId: jobId,
+		Payload: &protobufs.OptimizationEventResp_ErrorResp{
+			ErrorResp: &protobufs.ErrorOptimizationEventResp{
+				Error: msg,
+			},
+		},
+	}
+}
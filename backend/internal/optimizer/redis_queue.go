@@ -0,0 +1,90 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	config_env "omnicam.com/backend/config"
+	db_client "omnicam.com/backend/pkg/db"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+var ErrNoOptimizer = errors.New("no optimizer is running")
+
+// Sends jobs to the Python optimizer through Redis Streams, see ResponseListener for the responses
+type RedisJobQueue struct {
+	Logger      *zap.Logger
+	Env         *config_env.AppEnv
+	DB          *db_client.DB
+	RedisClient *redis.Client
+	waiters     sync.Map
+}
+
+// Starts listening to the optimizer responses, must be called before enqueueing jobs
+func (q *RedisJobQueue) Start(ctx context.Context) error {
+	listener := &ResponseListener{
+		Logger:           q.Logger,
+		Env:              q.Env,
+		DB:               q.DB,
+		RedisClient:      q.RedisClient,
+		ResponseRegistry: &q.waiters,
+	}
+	return listener.Start(ctx)
+}
+
+func (q *RedisJobQueue) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*JobWaiter, error) {
+	// Refreshed by the optimizer while it runs, without it the job would never be read
+	alive, err := q.RedisClient.Exists(ctx, q.Env.OptiHeartbeatKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if alive == 0 {
+		return nil, ErrNoOptimizer
+	}
+
+	data, err := EncodeJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	waiter := NewJobWaiter()
+	q.waiters.Store(job.GetJobId(), waiter)
+
+	err = q.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.Env.OptiReqTopic,
+		Values: map[string]interface{}{
+			"job_id": job.GetJobId(),
+			"data":   data,
+		},
+	}).Err()
+	if err != nil {
+		q.waiters.Delete(job.GetJobId())
+		return nil, err
+	}
+
+	return waiter, nil
+}
+
+func (q *RedisJobQueue) Release(jobId string) {
+	q.waiters.Delete(jobId)
+}
+
+// The optimizer checks the flag before starting the job and between iterations. The cancelled
+// response is broadcast, since the websocket of the job may be held by another replica.
+func (q *RedisJobQueue) Cancel(ctx context.Context, jobId string) error {
+	err := q.RedisClient.Set(ctx, q.Env.OptiCancelPrefix+jobId, 1, cancelFlagTTL).Err()
+	if err != nil {
+		return err
+	}
+
+	bytes, err := proto.Marshal(CancelledResponse(jobId))
+	if err != nil {
+		return err
+	}
+
+	return q.RedisClient.Publish(ctx, q.Env.OptiDoneChannel, bytes).Err()
+}
@@ -0,0 +1,376 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+var ErrInvalidLimits = errors.New("invalid optimization limits")
+
+// Longest time a job may run, it is cancelled and its slot freed afterward
+const JobTimeout = 10 * time.Minute
+
+// Result of a job that didn't answer before the timeout, the job is failed
+func TimedOutResponse(jobId string) *protobufs.OptimizationEventResp {
+	resp := errorResponse(jobId, "timed out")
+	resp.GetErrorResp().Code = protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_TIMED_OUT
+	return resp
+}
+
+type QuotaScope int
+
+const (
+	QuotaScopeUser QuotaScope = iota + 1
+	QuotaScopeProject
+	QuotaScopeGlobal
+)
+
+func (s QuotaScope) String() string {
+	switch s {
+	case QuotaScopeUser:
+		return "user"
+	case QuotaScopeProject:
+		return "project"
+	case QuotaScopeGlobal:
+		return "server"
+	default:
+		return "unknown"
+	}
+}
+
+// Returned when a job is rejected because too many jobs are waiting
+type QuotaExceededError struct {
+	Scope QuotaScope
+	Limit int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("too many optimizations waiting for this %s (limit %d)", e.Scope, e.Limit)
+}
+
+// Code sent to the client for an error returned by a JobQueue or while validating a request
+func ErrorCode(err error) protobufs.OptimizationErrorCode {
+	var quotaErr *QuotaExceededError
+	switch {
+	case errors.Is(err, ErrInvalidParams):
+		return protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_INVALID_PARAMS
+	case errors.As(err, &quotaErr) && quotaErr.Scope == QuotaScopeUser:
+		return protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_USER_QUOTA_EXCEEDED
+	case errors.As(err, &quotaErr) && quotaErr.Scope == QuotaScopeProject:
+		return protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_PROJECT_QUOTA_EXCEEDED
+	case errors.As(err, &quotaErr), errors.Is(err, ErrQueueFull):
+		return protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_QUEUE_FULL
+	default:
+		return protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_UNSPECIFIED
+	}
+}
+
+// Limits of the Scheduler, jobs over the Max*Running limits wait, jobs over the Max*Queued limits
+// are rejected. Every limit is at least 1.
+type Limits struct {
+	MaxRunning           int
+	MaxRunningPerUser    int
+	MaxRunningPerProject int
+	MaxQueued            int
+	MaxQueuedPerUser     int
+	MaxQueuedPerProject  int
+	// JobTimeout when zero
+	Timeout time.Duration
+}
+
+type scheduledJob struct {
+	job      *protobufs.OptimizeJob
+	waiter   *JobWaiter
+	released bool
+	// Last position sent to the waiter
+	position int
+}
+
+// Queues jobs in front of another JobQueue, so that only a limited number of jobs run at the
+// same time. Waiting jobs are started in FIFO order, a job blocked by the limit of its user or
+// project doesn't block the jobs behind it.
+//
+// The queue and the counts live in memory, so the limits only hold with a single scheduler:
+// a single backend instance runs one, see SchedulerLease.
+type Scheduler struct {
+	logger *zap.Logger
+	store  JobStore
+	queue  JobQueue
+	limits Limits
+
+	mu      sync.Mutex
+	waiting []*scheduledJob
+	running map[string]*scheduledJob
+}
+
+func (l Limits) validate() error {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"MaxRunning", l.MaxRunning},
+		{"MaxRunningPerUser", l.MaxRunningPerUser},
+		{"MaxRunningPerProject", l.MaxRunningPerProject},
+		{"MaxQueued", l.MaxQueued},
+		{"MaxQueuedPerUser", l.MaxQueuedPerUser},
+		{"MaxQueuedPerProject", l.MaxQueuedPerProject},
+	} {
+		if limit.value < 1 {
+			return fmt.Errorf("%w: %s is %d", ErrInvalidLimits, limit.name, limit.value)
+		}
+	}
+	if l.Timeout < 0 {
+		return fmt.Errorf("%w: Timeout is %s", ErrInvalidLimits, l.Timeout)
+	}
+	return nil
+}
+
+func NewScheduler(logger *zap.Logger, store JobStore, queue JobQueue, limits Limits) (*Scheduler, error) {
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		logger:  logger,
+		store:   store,
+		queue:   queue,
+		limits:  limits,
+		running: make(map[string]*scheduledJob),
+	}, nil
+}
+
+// Fails the jobs left pending by a previous run, their queue was lost with it. Must be called
+// before the first job is enqueued.
+func (s *Scheduler) Start(ctx context.Context) error {
+	failed, err := s.store.FailPendingOptimizationJobs(ctx, "backend restarted")
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		s.logger.Warn("failed optimization jobs left pending by a previous run", zap.Int64("jobs", failed))
+	}
+	return nil
+}
+
+type jobCounts struct {
+	total   int
+	user    int
+	project int
+}
+
+// Counts the jobs sharing the user or project of job
+func countJobs(jobs iter.Seq[*scheduledJob], job *protobufs.OptimizeJob) jobCounts {
+	counts := jobCounts{}
+	for j := range jobs {
+		counts.total++
+		if j.job.GetUserId() == job.GetUserId() {
+			counts.user++
+		}
+		if j.job.GetProjectId() == job.GetProjectId() {
+			counts.project++
+		}
+	}
+	return counts
+}
+
+func (s *Scheduler) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*JobWaiter, error) {
+	s.mu.Lock()
+
+	waiting := countJobs(slices.Values(s.waiting), job)
+	var err error
+	switch {
+	case waiting.user >= s.limits.MaxQueuedPerUser:
+		err = &QuotaExceededError{Scope: QuotaScopeUser, Limit: s.limits.MaxQueuedPerUser}
+	case waiting.project >= s.limits.MaxQueuedPerProject:
+		err = &QuotaExceededError{Scope: QuotaScopeProject, Limit: s.limits.MaxQueuedPerProject}
+	case waiting.total >= s.limits.MaxQueued:
+		err = &QuotaExceededError{Scope: QuotaScopeGlobal, Limit: s.limits.MaxQueued}
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	scheduled := &scheduledJob{
+		job:      job,
+		waiter:   NewJobWaiter(),
+		position: -1,
+	}
+	s.waiting = append(s.waiting, scheduled)
+	started := s.nextJobs()
+	s.reportPositions()
+	s.mu.Unlock()
+
+	s.start(ctx, started)
+	return scheduled.waiter, nil
+}
+
+// Takes the waiting jobs that fit in the limits out of the queue, must be called with the lock held
+func (s *Scheduler) nextJobs() []*scheduledJob {
+	started := []*scheduledJob{}
+	remaining := s.waiting[:0]
+	for _, j := range s.waiting {
+		running := countJobs(maps.Values(s.running), j.job)
+		if running.total >= s.limits.MaxRunning || running.user >= s.limits.MaxRunningPerUser || running.project >= s.limits.MaxRunningPerProject {
+			remaining = append(remaining, j)
+			continue
+		}
+		s.running[j.job.GetJobId()] = j
+		started = append(started, j)
+	}
+	clear(s.waiting[len(remaining):])
+	s.waiting = remaining
+	return started
+}
+
+// Sends their position to the waiting jobs that moved, must be called with the lock held
+func (s *Scheduler) reportPositions() {
+	for i, j := range s.waiting {
+		if j.position == i+1 {
+			continue
+		}
+		j.position = i + 1
+		s.sendProgress(j, queuedResponse(j.job.GetJobId(), j.position))
+	}
+}
+
+func queuedResponse(jobId string, position int) *protobufs.OptimizationEventResp {
+	return &protobufs.OptimizationEventResp{
+		JobId: jobId,
+		Payload: &protobufs.OptimizationEventResp_QueuedResp{
+			QueuedResp: &protobufs.QueuedOptimizationEventResp{
+				Position: uint32(position),
+			},
+		},
+	}
+}
+
+func (s *Scheduler) sendProgress(j *scheduledJob, resp *protobufs.OptimizationEventResp) {
+	if j.released {
+		return
+	}
+	select {
+	case j.waiter.Progress <- resp:
+	default:
+		// The websocket is behind, skip this update
+	}
+}
+
+func (s *Scheduler) start(ctx context.Context, jobs []*scheduledJob) {
+	for _, j := range jobs {
+		// Not bound to the request, the job outlives it
+		inner, err := s.queue.Enqueue(context.WithoutCancel(ctx), j.job)
+		if err != nil {
+			s.logger.Error("failed to start optimization job", zap.String("job_id", j.job.GetJobId()), zap.Error(err))
+			resp := errorResponse(j.job.GetJobId(), "failed to start optimization")
+			if _, err := finishJob(context.WithoutCancel(ctx), s.store, resp); err != nil {
+				s.logger.Error("failed to mark optimization job as failed", zap.String("job_id", j.job.GetJobId()), zap.Error(err))
+			}
+			s.finish(j, resp)
+			continue
+		}
+
+		s.mu.Lock()
+		s.sendProgress(j, queuedResponse(j.job.GetJobId(), 0))
+		s.mu.Unlock()
+
+		go s.forward(j, inner)
+	}
+}
+
+// Forwards the responses of a running job to its waiter, until the job ends
+func (s *Scheduler) forward(j *scheduledJob, inner *JobWaiter) {
+	limit := s.limits.Timeout
+	if limit == 0 {
+		limit = JobTimeout
+	}
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case resp := <-inner.Progress:
+			s.mu.Lock()
+			s.sendProgress(j, resp)
+			s.mu.Unlock()
+		case resp := <-inner.Result:
+			s.finish(j, resp)
+			return
+		case <-timeout.C:
+			s.logger.Warn("optimization job timed out, freeing its slot", zap.String("job_id", j.job.GetJobId()))
+			// Stops the job so that it doesn't keep running past the limit, its cancelled
+			// response isn't forwarded once released
+			s.queue.Release(j.job.GetJobId())
+			if err := s.queue.Cancel(context.Background(), j.job.GetJobId()); err != nil {
+				s.logger.Error("failed to cancel timed out optimization job", zap.String("job_id", j.job.GetJobId()), zap.Error(err))
+			}
+			resp := TimedOutResponse(j.job.GetJobId())
+			if _, err := finishJob(context.Background(), s.store, resp); err != nil {
+				s.logger.Error("failed to mark optimization job as failed", zap.String("job_id", j.job.GetJobId()), zap.Error(err))
+			}
+			s.finish(j, resp)
+			return
+		}
+	}
+}
+
+// Frees the slot of the job and starts the next ones
+func (s *Scheduler) finish(j *scheduledJob, resp *protobufs.OptimizationEventResp) {
+	s.mu.Lock()
+	delete(s.running, j.job.GetJobId())
+	if resp != nil && !j.released {
+		j.waiter.Result <- resp
+	}
+	j.released = true
+	started := s.nextJobs()
+	s.reportPositions()
+	s.mu.Unlock()
+
+	s.start(context.Background(), started)
+}
+
+// A released job keeps its place in the queue, it still runs and its result is still persisted
+func (s *Scheduler) Release(jobId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.waiting {
+		if j.job.GetJobId() == jobId {
+			j.released = true
+			return
+		}
+	}
+	if j, ok := s.running[jobId]; ok {
+		j.released = true
+	}
+}
+
+// Waiting jobs are removed from the queue, running jobs are cancelled by the underlying queue
+func (s *Scheduler) Cancel(ctx context.Context, jobId string) error {
+	s.mu.Lock()
+	for i, j := range s.waiting {
+		if j.job.GetJobId() != jobId {
+			continue
+		}
+
+		s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+		if !j.released {
+			j.waiter.Result <- CancelledResponse(jobId)
+		}
+		j.released = true
+		s.reportPositions()
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	return s.queue.Cancel(ctx, jobId)
+}
@@ -0,0 +1,200 @@
+package optimizer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/optimizer"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Keeps the jobs running until they are finished by the test
+type manualQueue struct {
+	mu      sync.Mutex
+	waiters map[string]*optimizer.JobWaiter
+}
+
+func (q *manualQueue) Enqueue(ctx context.Context, job *protobufs.OptimizeJob) (*optimizer.JobWaiter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.waiters == nil {
+		q.waiters = make(map[string]*optimizer.JobWaiter)
+	}
+	waiter := optimizer.NewJobWaiter()
+	q.waiters[job.GetJobId()] = waiter
+	return waiter, nil
+}
+
+func (q *manualQueue) Release(jobId string) {}
+
+func (q *manualQueue) Cancel(ctx context.Context, jobId string) error {
+	q.finish(jobId, optimizer.CancelledResponse(jobId))
+	return nil
+}
+
+func (q *manualQueue) isRunning(jobId string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.waiters[jobId]
+	return ok
+}
+
+func (q *manualQueue) finish(jobId string, resp *protobufs.OptimizationEventResp) {
+	q.mu.Lock()
+	waiter := q.waiters[jobId]
+	delete(q.waiters, jobId)
+	q.mu.Unlock()
+
+	waiter.Result <- resp
+}
+
+func success(jobId string) *protobufs.OptimizationEventResp {
+	return &protobufs.OptimizationEventResp{
+		JobId: jobId,
+		Payload: &protobufs.OptimizationEventResp_SuccessResp{
+			SuccessResp: &protobufs.SuccessOptimizationEventResp{},
+		},
+	}
+}
+
+func limits() optimizer.Limits {
+	return optimizer.Limits{
+		MaxRunning:           2,
+		MaxRunningPerUser:    1,
+		MaxRunningPerProject: 2,
+		MaxQueued:            3,
+		MaxQueuedPerUser:     2,
+		MaxQueuedPerProject:  3,
+	}
+}
+
+func requirePosition(t *testing.T, waiter *optimizer.JobWaiter, position uint32) {
+	t.Helper()
+	resp := receive(t, waiter.Progress)
+	require.NotNil(t, resp.GetQueuedResp(), "got %v", resp)
+	require.Equal(t, position, resp.GetQueuedResp().GetPosition())
+}
+
+func TestSchedulerQueuesPerUser(t *testing.T) {
+	inner := &manualQueue{}
+	scheduler, err := optimizer.NewScheduler(zap.NewNop(), &fakeStore{}, inner, limits())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a1", UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+	requirePosition(t, first, 0)
+
+	// Same user, waits for the first job
+	second, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a2", UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+	requirePosition(t, second, 1)
+	require.False(t, inner.isRunning("a2"))
+
+	// Another user isn't blocked by the queued job
+	other, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "b1", UserId: "b", ProjectId: "p"})
+	require.NoError(t, err)
+	requirePosition(t, other, 0)
+
+	inner.finish("a1", success("a1"))
+	require.NotNil(t, receive(t, first.Result).GetSuccessResp())
+	requirePosition(t, second, 0)
+	require.True(t, inner.isRunning("a2"))
+}
+
+func TestSchedulerRejectsOverQuota(t *testing.T) {
+	inner := &manualQueue{}
+	scheduler, err := optimizer.NewScheduler(zap.NewNop(), &fakeStore{}, inner, limits())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, id := range []string{"a1", "a2", "a3"} {
+		_, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: id, UserId: "a", ProjectId: "p"})
+		require.NoError(t, err)
+	}
+
+	// a1 runs, a2 and a3 wait
+	_, err = scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a4", UserId: "a", ProjectId: "p"})
+	var quotaErr *optimizer.QuotaExceededError
+	require.True(t, errors.As(err, &quotaErr))
+	require.Equal(t, optimizer.QuotaScopeUser, quotaErr.Scope)
+	require.Equal(t, protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_USER_QUOTA_EXCEEDED, optimizer.ErrorCode(err))
+}
+
+func TestSchedulerCancelQueued(t *testing.T) {
+	inner := &manualQueue{}
+	scheduler, err := optimizer.NewScheduler(zap.NewNop(), &fakeStore{}, inner, limits())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a1", UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+	queued, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a2", UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.Cancel(ctx, "a2"))
+	require.NotNil(t, receive(t, queued.Result).GetCancelledResp())
+
+	inner.finish("a1", success("a1"))
+	// The cancelled job never starts
+	_, err = scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: "a3", UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+	require.False(t, inner.isRunning("a2"))
+}
+
+func TestSchedulerTimesOut(t *testing.T) {
+	inner := &manualQueue{}
+	store := &fakeStore{}
+	jobLimits := limits()
+	jobLimits.Timeout = 10 * time.Millisecond
+	scheduler, err := optimizer.NewScheduler(zap.NewNop(), store, inner, jobLimits)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	jobId := uuid.NewString()
+	waiter, err := scheduler.Enqueue(ctx, &protobufs.OptimizeJob{JobId: jobId, UserId: "a", ProjectId: "p"})
+	require.NoError(t, err)
+	requirePosition(t, waiter, 0)
+
+	// The job never answers
+	resp := receive(t, waiter.Result)
+	require.Equal(t, protobufs.OptimizationErrorCode_OPTIMIZATION_ERROR_CODE_TIMED_OUT, resp.GetErrorResp().GetCode())
+	require.Equal(t, db_sqlc_gen.OptimizationJobStatusFailed, store.status(jobId))
+	// The optimizer is told to stop the job
+	require.False(t, inner.isRunning(jobId))
+}
+
+func TestSchedulerRejectsInvalidLimits(t *testing.T) {
+	t.Parallel()
+
+	noRunning := limits()
+	noRunning.MaxRunning = 0
+	negativeQueue := limits()
+	negativeQueue.MaxQueuedPerUser = -1
+	negativeTimeout := limits()
+	negativeTimeout.Timeout = -time.Second
+
+	for _, invalid := range []optimizer.Limits{noRunning, negativeQueue, negativeTimeout} {
+		_, err := optimizer.NewScheduler(zap.NewNop(), &fakeStore{}, &manualQueue{}, invalid)
+		require.ErrorIs(t, err, optimizer.ErrInvalidLimits)
+	}
+}
+
+func TestSchedulerStartFailsPendingJobs(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeStore{pending: 2}
+	scheduler, err := optimizer.NewScheduler(zap.NewNop(), store, &manualQueue{}, limits())
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.Start(context.Background()))
+	require.Zero(t, store.pending)
+}
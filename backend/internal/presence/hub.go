@@ -0,0 +1,456 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+const (
+	heartbeatPeriod = 10 * time.Second
+	// Members of an instance not heard from for this long are dropped
+	instanceTimeout = 3 * heartbeatPeriod
+)
+
+// Relays presence messages between the hubs of all backend instances
+type Broker interface {
+	Publish(ctx context.Context, msg *protobufs.PresenceBroadcast) error
+	// Calls handle with the messages published by every instance, this one included, until ctx is done
+	Subscribe(ctx context.Context, handle func(*protobufs.PresenceBroadcast)) error
+}
+
+// Receives the presence of the other members of a model and the notifications about the model.
+// Called with the hub locked, so it must not block, e.g. by dropping messages for a slow connection.
+type Deliver func(*protobufs.WorkspaceEventResponse)
+
+// Closes the connection of a member with the reason. Called with the hub locked, like Deliver.
+type Disconnect func(reason string)
+
+type member struct {
+	instanceId string
+	member     *protobufs.PresenceMember
+	// Workspace the connection edits, unknown for the members of other instances
+	workspaceId uuid.UUID
+	// nil for the members of other instances
+	deliver    Deliver
+	disconnect Disconnect
+}
+
+// Knows every member of every model, those of the other instances are learnt from the broker.
+// Also relays the notifications about a model to all its members.
+type Hub struct {
+	logger     *zap.Logger
+	broker     Broker
+	instanceId string
+
+	mu    sync.Mutex
+	rooms map[uuid.UUID]map[string]*member
+	// Last message received from each other instance
+	instances map[string]time.Time
+}
+
+// broker is nil when a single backend instance runs
+func NewHub(logger *zap.Logger, broker Broker) *Hub {
+	return &Hub{
+		logger:     logger,
+		broker:     broker,
+		instanceId: uuid.NewString(),
+		rooms:      make(map[uuid.UUID]map[string]*member),
+		instances:  make(map[string]time.Time),
+	}
+}
+
+// Subscribes to the other instances and asks them for their members
+func (h *Hub) Start(ctx context.Context) error {
+	if h.broker == nil {
+		return nil
+	}
+
+	if err := h.broker.Subscribe(ctx, h.handle); err != nil {
+		return err
+	}
+	h.publish(ctx, &protobufs.PresenceBroadcast{
+		Payload: &protobufs.PresenceBroadcast_Sync{Sync: true},
+	})
+
+	go h.heartbeatLoop(ctx)
+	return nil
+}
+
+func (h *Hub) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.publish(ctx, &protobufs.PresenceBroadcast{
+				Payload: &protobufs.PresenceBroadcast_Heartbeat{Heartbeat: true},
+			})
+			h.sweep(now)
+		}
+	}
+}
+
+func (h *Hub) publish(ctx context.Context, msg *protobufs.PresenceBroadcast) {
+	if h.broker == nil {
+		return
+	}
+
+	msg.InstanceId = h.instanceId
+	if err := h.broker.Publish(ctx, msg); err != nil {
+		h.logger.Warn("error while publishing presence", zap.Error(err))
+	}
+}
+
+func (h *Hub) publishEvent(ctx context.Context, modelId uuid.UUID, event *protobufs.PresenceEvent) {
+	h.publish(ctx, &protobufs.PresenceBroadcast{
+		ModelId: modelId.String(),
+		Payload: &protobufs.PresenceBroadcast_Event{Event: event},
+	})
+}
+
+// Sends the event to the members of this instance on the model, except the member it is about
+func (h *Hub) fanOut(room map[string]*member, event *protobufs.PresenceEvent) {
+	resp := &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Presence{
+			Presence: &protobufs.PresenceResponse{
+				Payload: &protobufs.PresenceResponse_Event{Event: event},
+			},
+		},
+	}
+	for id, m := range room {
+		if m.deliver != nil && id != event.GetMember().GetMemberId() {
+			m.deliver(resp)
+		}
+	}
+}
+
+// Applies an event to the members of a model and forwards it to the local members
+func (h *Hub) apply(modelId uuid.UUID, event *protobufs.PresenceEvent, m *member) {
+	room := h.rooms[modelId]
+	if room == nil {
+		if event.GetType() == protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE {
+			return
+		}
+		room = make(map[string]*member)
+		h.rooms[modelId] = room
+	}
+
+	id := event.GetMember().GetMemberId()
+	switch event.GetType() {
+	case protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_JOIN, protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_UPDATE:
+		if existing, ok := room[id]; ok {
+			existing.member = event.GetMember()
+		} else {
+			room[id] = m
+		}
+	case protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE:
+		if _, ok := room[id]; !ok {
+			return
+		}
+		delete(room, id)
+	default:
+		return
+	}
+
+	h.fanOut(room, event)
+	if len(room) == 0 {
+		delete(h.rooms, modelId)
+	}
+}
+
+// Handles a message of the broker
+func (h *Hub) handle(msg *protobufs.PresenceBroadcast) {
+	if msg.GetInstanceId() == h.instanceId {
+		// Already applied when published
+		return
+	}
+
+	h.mu.Lock()
+	h.instances[msg.GetInstanceId()] = time.Now()
+
+	switch payload := msg.GetPayload().(type) {
+	case *protobufs.PresenceBroadcast_Event:
+		modelId, err := uuid.Parse(msg.GetModelId())
+		if err != nil || payload.Event.GetMember().GetMemberId() == "" {
+			h.mu.Unlock()
+			h.logger.Warn("invalid presence event", zap.String("instance_id", msg.GetInstanceId()))
+			return
+		}
+		h.apply(modelId, payload.Event, &member{
+			instanceId: msg.GetInstanceId(),
+			member:     payload.Event.GetMember(),
+		})
+		h.mu.Unlock()
+	case *protobufs.PresenceBroadcast_MainUpdated:
+		modelId, err := uuid.Parse(msg.GetModelId())
+		if err != nil {
+			h.mu.Unlock()
+			h.logger.Warn("invalid main update", zap.String("instance_id", msg.GetInstanceId()))
+			return
+		}
+		h.notify(modelId, payload.MainUpdated, msg.GetExceptWorkspaceId())
+		h.mu.Unlock()
+	case *protobufs.PresenceBroadcast_WorkspaceUpdated:
+		modelId, err := uuid.Parse(msg.GetModelId())
+		if err != nil {
+			h.mu.Unlock()
+			h.logger.Warn("invalid workspace update", zap.String("instance_id", msg.GetInstanceId()))
+			return
+		}
+		h.notifyWorkspace(modelId, payload.WorkspaceUpdated, msg.GetWorkspaceId())
+		h.mu.Unlock()
+	case *protobufs.PresenceBroadcast_WorkspaceClosed:
+		modelId, err := uuid.Parse(msg.GetModelId())
+		if err != nil {
+			h.mu.Unlock()
+			h.logger.Warn("invalid workspace close", zap.String("instance_id", msg.GetInstanceId()))
+			return
+		}
+		h.closeWorkspace(modelId, msg.GetWorkspaceId(), payload.WorkspaceClosed)
+		h.mu.Unlock()
+	case *protobufs.PresenceBroadcast_Sync:
+		// A new instance doesn't know the members of the running ones
+		joins := []*protobufs.PresenceBroadcast{}
+		for modelId, room := range h.rooms {
+			for _, m := range room {
+				if m.deliver == nil {
+					continue
+				}
+				joins = append(joins, &protobufs.PresenceBroadcast{
+					ModelId: modelId.String(),
+					Payload: &protobufs.PresenceBroadcast_Event{Event: &protobufs.PresenceEvent{
+						Type:   protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_JOIN,
+						Member: m.member,
+					}},
+				})
+			}
+		}
+		h.mu.Unlock()
+
+		for _, join := range joins {
+			h.publish(context.Background(), join)
+		}
+	default:
+		h.mu.Unlock()
+	}
+}
+
+// Sends a notification to the members of this instance on the model
+func (h *Hub) notify(modelId uuid.UUID, update *protobufs.MainUpdatedResponse, exceptWorkspaceId string) {
+	resp := &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_MainUpdated{MainUpdated: update},
+	}
+	for _, m := range h.rooms[modelId] {
+		if m.deliver != nil && m.workspaceId.String() != exceptWorkspaceId {
+			m.deliver(resp)
+		}
+	}
+}
+
+// Tells the members of the model, on any instance, that its cameras were saved to main. The
+// members on the workspace that was merged are skipped, it already has the change. Every
+// member is told when exceptWorkspaceId is uuid.Nil.
+func (h *Hub) AnnounceMainUpdate(ctx context.Context, modelId uuid.UUID, exceptWorkspaceId uuid.UUID, update *protobufs.MainUpdatedResponse) {
+	h.mu.Lock()
+	h.notify(modelId, update, exceptWorkspaceId.String())
+	h.mu.Unlock()
+
+	h.publish(ctx, &protobufs.PresenceBroadcast{
+		ModelId:           modelId.String(),
+		Payload:           &protobufs.PresenceBroadcast_MainUpdated{MainUpdated: update},
+		ExceptWorkspaceId: exceptWorkspaceId.String(),
+	})
+}
+
+// Sends the changes of a workspace to the members of this instance on it
+func (h *Hub) notifyWorkspace(modelId uuid.UUID, update *protobufs.HistoryResponse, workspaceId string) {
+	resp := &protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_History{History: update},
+	}
+	for _, m := range h.rooms[modelId] {
+		if m.deliver != nil && m.workspaceId.String() == workspaceId {
+			m.deliver(resp)
+		}
+	}
+}
+
+// Sends the changes made to a workspace outside of its connections to the members on it, on
+// any instance
+func (h *Hub) AnnounceWorkspaceUpdate(ctx context.Context, modelId uuid.UUID, workspaceId uuid.UUID, update *protobufs.HistoryResponse) {
+	h.mu.Lock()
+	h.notifyWorkspace(modelId, update, workspaceId.String())
+	h.mu.Unlock()
+
+	h.publish(ctx, &protobufs.PresenceBroadcast{
+		ModelId:     modelId.String(),
+		Payload:     &protobufs.PresenceBroadcast_WorkspaceUpdated{WorkspaceUpdated: update},
+		WorkspaceId: workspaceId.String(),
+	})
+}
+
+// Disconnects the members of this instance on a workspace
+func (h *Hub) closeWorkspace(modelId uuid.UUID, workspaceId string, reason string) {
+	for _, m := range h.rooms[modelId] {
+		if m.disconnect != nil && m.workspaceId.String() == workspaceId {
+			m.disconnect(reason)
+		}
+	}
+}
+
+// Disconnects the members on a workspace, on any instance, e.g. once it is deleted. They leave
+// the model when their connection ends.
+func (h *Hub) CloseWorkspace(ctx context.Context, modelId uuid.UUID, workspaceId uuid.UUID, reason string) {
+	h.mu.Lock()
+	h.closeWorkspace(modelId, workspaceId.String(), reason)
+	h.mu.Unlock()
+
+	h.publish(ctx, &protobufs.PresenceBroadcast{
+		ModelId:     modelId.String(),
+		Payload:     &protobufs.PresenceBroadcast_WorkspaceClosed{WorkspaceClosed: reason},
+		WorkspaceId: workspaceId.String(),
+	})
+}
+
+// Drops the members of the instances that stopped sending heartbeats
+func (h *Hub) sweep(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for instanceId, seen := range h.instances {
+		if now.Sub(seen) < instanceTimeout {
+			continue
+		}
+		h.logger.Warn("dropping presence of unresponsive instance", zap.String("instance_id", instanceId))
+		delete(h.instances, instanceId)
+
+		for modelId, room := range h.rooms {
+			for _, m := range room {
+				if m.instanceId == instanceId {
+					h.apply(modelId, &protobufs.PresenceEvent{
+						Type:   protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE,
+						Member: m.member,
+					}, nil)
+				}
+			}
+		}
+	}
+}
+
+// Adds a member of this instance, editing the workspace, to a model. deliver first receives a
+// snapshot of the members already there, then their events.
+func (h *Hub) Join(ctx context.Context, modelId uuid.UUID, workspaceId uuid.UUID, user *protobufs.PresenceUser, deliver Deliver, disconnect Disconnect) *Client {
+	c := &Client{
+		hub:     h,
+		modelId: modelId,
+		id:      uuid.NewString(),
+	}
+	self := &protobufs.PresenceMember{
+		MemberId: c.id,
+		User:     user,
+		State:    &protobufs.PresenceState{},
+	}
+	event := &protobufs.PresenceEvent{
+		Type:   protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_JOIN,
+		Member: self,
+	}
+
+	h.mu.Lock()
+	snapshot := &protobufs.PresenceSnapshot{MemberId: c.id}
+	for _, m := range h.rooms[modelId] {
+		snapshot.Members = append(snapshot.Members, m.member)
+	}
+	deliver(&protobufs.WorkspaceEventResponse{
+		Resp: &protobufs.WorkspaceEventResponse_Presence{
+			Presence: &protobufs.PresenceResponse{
+				Payload: &protobufs.PresenceResponse_Snapshot{Snapshot: snapshot},
+			},
+		},
+	})
+	h.apply(modelId, event, &member{
+		instanceId:  h.instanceId,
+		member:      self,
+		workspaceId: workspaceId,
+		deliver:     deliver,
+		disconnect:  disconnect,
+	})
+	h.mu.Unlock()
+
+	h.publishEvent(ctx, modelId, event)
+	return c
+}
+
+// A member of this instance
+type Client struct {
+	hub     *Hub
+	modelId uuid.UUID
+	id      string
+
+	mu   sync.Mutex
+	left bool
+}
+
+func (c *Client) ID() string {
+	return c.id
+}
+
+// Replaces the state of the member and sends it to the other members of the model
+func (c *Client) Update(ctx context.Context, state *protobufs.PresenceState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.left {
+		return
+	}
+
+	h := c.hub
+	h.mu.Lock()
+	current, ok := h.rooms[c.modelId][c.id]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	updated := proto.Clone(current.member).(*protobufs.PresenceMember)
+	updated.State = state
+	event := &protobufs.PresenceEvent{
+		Type:   protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_UPDATE,
+		Member: updated,
+	}
+	h.apply(c.modelId, event, nil)
+	h.mu.Unlock()
+
+	h.publishEvent(ctx, c.modelId, event)
+}
+
+// Removes the member from the model, only the first call has an effect
+func (c *Client) Leave(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.left {
+		return
+	}
+	c.left = true
+
+	h := c.hub
+	h.mu.Lock()
+	current, ok := h.rooms[c.modelId][c.id]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	event := &protobufs.PresenceEvent{
+		Type:   protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE,
+		Member: current.member,
+	}
+	h.apply(c.modelId, event, nil)
+	h.mu.Unlock()
+
+	h.publishEvent(ctx, c.modelId, event)
+}
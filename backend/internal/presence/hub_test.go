@@ -0,0 +1,285 @@
+package presence_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"omnicam.com/backend/internal/presence"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Delivers every message to every subscriber before Publish returns
+type memoryBroker struct {
+	mu       sync.Mutex
+	handlers []func(*protobufs.PresenceBroadcast)
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, msg *protobufs.PresenceBroadcast) error {
+	b.mu.Lock()
+	handlers := append([]func(*protobufs.PresenceBroadcast){}, b.handlers...)
+	b.mu.Unlock()
+
+	for _, handle := range handlers {
+		handle(msg)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, handle func(*protobufs.PresenceBroadcast)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handle)
+	return nil
+}
+
+type inbox struct {
+	mu        sync.Mutex
+	responses []*protobufs.WorkspaceEventResponse
+	// Reason the connection was closed with
+	closed string
+}
+
+func (i *inbox) deliver(resp *protobufs.WorkspaceEventResponse) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.responses = append(i.responses, resp)
+}
+
+func (i *inbox) disconnect(reason string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.closed = reason
+}
+
+func (i *inbox) closedWith() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.closed
+}
+
+func (i *inbox) take() []*protobufs.WorkspaceEventResponse {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	responses := i.responses
+	i.responses = nil
+	return responses
+}
+
+func user(name string) *protobufs.PresenceUser {
+	return &protobufs.PresenceUser{UserId: uuid.NewString(), Username: name}
+}
+
+func requireEvent(t *testing.T, resp *protobufs.WorkspaceEventResponse, eventType protobufs.PresenceEventType, memberId string) *protobufs.PresenceMember {
+	t.Helper()
+	event := resp.GetPresence().GetEvent()
+	require.NotNil(t, event)
+	require.Equal(t, eventType, event.GetType())
+	require.Equal(t, memberId, event.GetMember().GetMemberId())
+	return event.GetMember()
+}
+
+func TestHubSingleInstance(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	hub := presence.NewHub(zap.NewNop(), nil)
+	require.NoError(t, hub.Start(ctx))
+
+	modelId := uuid.New()
+	alice, bob, other := &inbox{}, &inbox{}, &inbox{}
+
+	aliceClient := hub.Join(ctx, modelId, uuid.New(), user("alice"), alice.deliver, nil)
+	responses := alice.take()
+	require.Len(t, responses, 1)
+	require.Equal(t, aliceClient.ID(), responses[0].GetPresence().GetSnapshot().GetMemberId())
+	require.Empty(t, responses[0].GetPresence().GetSnapshot().GetMembers())
+
+	// Members of other models are not visible
+	hub.Join(ctx, uuid.New(), uuid.New(), user("carol"), other.deliver, nil)
+	require.Empty(t, alice.take())
+
+	bobClient := hub.Join(ctx, modelId, uuid.New(), user("bob"), bob.deliver, nil)
+	responses = bob.take()
+	require.Len(t, responses, 1)
+	require.Len(t, responses[0].GetPresence().GetSnapshot().GetMembers(), 1)
+	require.Equal(t, "alice", responses[0].GetPresence().GetSnapshot().GetMembers()[0].GetUser().GetUsername())
+
+	responses = alice.take()
+	require.Len(t, responses, 1)
+	requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_JOIN, bobClient.ID())
+
+	bobClient.Update(ctx, &protobufs.PresenceState{SelectedCameraId: "cam-1"})
+	require.Empty(t, bob.take())
+	responses = alice.take()
+	require.Len(t, responses, 1)
+	member := requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_UPDATE, bobClient.ID())
+	require.Equal(t, "cam-1", member.GetState().GetSelectedCameraId())
+	require.Equal(t, "bob", member.GetUser().GetUsername())
+
+	bobClient.Leave(ctx)
+	bobClient.Leave(ctx)
+	bobClient.Update(ctx, &protobufs.PresenceState{SelectedCameraId: "cam-2"})
+	responses = alice.take()
+	require.Len(t, responses, 1)
+	requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE, bobClient.ID())
+
+	// The snapshot has the last state of each member
+	aliceClient.Update(ctx, &protobufs.PresenceState{SelectedCameraId: "cam-3"})
+	dave := &inbox{}
+	hub.Join(ctx, modelId, uuid.New(), user("dave"), dave.deliver, nil)
+	responses = dave.take()
+	require.Len(t, responses, 1)
+	require.Len(t, responses[0].GetPresence().GetSnapshot().GetMembers(), 1)
+	require.Equal(t, "cam-3", responses[0].GetPresence().GetSnapshot().GetMembers()[0].GetState().GetSelectedCameraId())
+}
+
+func TestHubMultipleInstances(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	broker := &memoryBroker{}
+	modelId := uuid.New()
+
+	first := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, first.Start(ctx))
+	alice := &inbox{}
+	aliceClient := first.Join(ctx, modelId, uuid.New(), user("alice"), alice.deliver, nil)
+	alice.take()
+
+	// Started after alice joined, learns about her through the sync
+	second := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, second.Start(ctx))
+	bob := &inbox{}
+	bobClient := second.Join(ctx, modelId, uuid.New(), user("bob"), bob.deliver, nil)
+
+	responses := bob.take()
+	require.Len(t, responses, 1)
+	require.Len(t, responses[0].GetPresence().GetSnapshot().GetMembers(), 1)
+	require.Equal(t, aliceClient.ID(), responses[0].GetPresence().GetSnapshot().GetMembers()[0].GetMemberId())
+
+	responses = alice.take()
+	require.Len(t, responses, 1)
+	requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_JOIN, bobClient.ID())
+
+	aliceClient.Update(ctx, &protobufs.PresenceState{
+		Spectator: &protobufs.SpectatorPose{
+			Position: &protobufs.ProtoVector3{X: 1, Y: 2, Z: 3},
+			Fov:      75,
+		},
+	})
+	responses = bob.take()
+	require.Len(t, responses, 1)
+	member := requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_UPDATE, aliceClient.ID())
+	require.Equal(t, 2.0, member.GetState().GetSpectator().GetPosition().GetY())
+
+	aliceClient.Leave(ctx)
+	responses = bob.take()
+	require.Len(t, responses, 1)
+	requireEvent(t, responses[0], protobufs.PresenceEventType_PRESENCE_EVENT_TYPE_LEAVE, aliceClient.ID())
+	require.Empty(t, alice.take())
+}
+
+func TestHubMainUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	broker := &memoryBroker{}
+	modelId := uuid.New()
+
+	first := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, first.Start(ctx))
+	second := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, second.Start(ctx))
+
+	alice, aliceOtherTab, aliceBranch, bob, dave, other := &inbox{}, &inbox{}, &inbox{}, &inbox{}, &inbox{}, &inbox{}
+	aliceUser := user("alice")
+	merged := uuid.New()
+	first.Join(ctx, modelId, merged, aliceUser, alice.deliver, nil)
+	second.Join(ctx, modelId, merged, aliceUser, aliceOtherTab.deliver, nil)
+	first.Join(ctx, modelId, uuid.New(), aliceUser, aliceBranch.deliver, nil)
+	first.Join(ctx, modelId, uuid.New(), user("bob"), bob.deliver, nil)
+	second.Join(ctx, modelId, uuid.New(), user("dave"), dave.deliver, nil)
+	second.Join(ctx, uuid.New(), uuid.New(), user("carol"), other.deliver, nil)
+	for _, i := range []*inbox{alice, aliceOtherTab, aliceBranch, bob, dave, other} {
+		i.take()
+	}
+
+	first.AnnounceMainUpdate(ctx, modelId, merged, &protobufs.MainUpdatedResponse{
+		MainVersion:     3,
+		PreviousVersion: 2,
+		Cameras:         &protobufs.MainCamerasChange{Changed: []string{"cam-1"}},
+	})
+
+	// Another branch of the user who merged doesn't have the change either
+	for _, i := range []*inbox{aliceBranch, bob, dave} {
+		responses := i.take()
+		require.Len(t, responses, 1)
+		require.Equal(t, int32(3), responses[0].GetMainUpdated().GetMainVersion())
+		require.Equal(t, []string{"cam-1"}, responses[0].GetMainUpdated().GetCameras().GetChanged())
+	}
+	// The merged workspace already has the change
+	require.Empty(t, alice.take())
+	require.Empty(t, aliceOtherTab.take())
+	require.Empty(t, other.take())
+}
+
+func TestHubWorkspaceUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	broker := &memoryBroker{}
+	modelId := uuid.New()
+
+	first := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, first.Start(ctx))
+	second := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, second.Start(ctx))
+
+	tab, otherTab, branch := &inbox{}, &inbox{}, &inbox{}
+	aliceUser := user("alice")
+	workspaceId := uuid.New()
+	first.Join(ctx, modelId, workspaceId, aliceUser, tab.deliver, nil)
+	second.Join(ctx, modelId, workspaceId, aliceUser, otherTab.deliver, nil)
+	first.Join(ctx, modelId, uuid.New(), aliceUser, branch.deliver, nil)
+	for _, i := range []*inbox{tab, otherTab, branch} {
+		i.take()
+	}
+
+	first.AnnounceWorkspaceUpdate(ctx, modelId, workspaceId, &protobufs.HistoryResponse{
+		LastUpdatedVersion: 4,
+		CanUndo:            true,
+	})
+
+	for _, i := range []*inbox{tab, otherTab} {
+		responses := i.take()
+		require.Len(t, responses, 1)
+		require.Equal(t, int32(4), responses[0].GetHistory().GetLastUpdatedVersion())
+		require.True(t, responses[0].GetHistory().GetCanUndo())
+	}
+	require.Empty(t, branch.take())
+}
+
+func TestHubCloseWorkspace(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	broker := &memoryBroker{}
+	modelId := uuid.New()
+
+	first := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, first.Start(ctx))
+	second := presence.NewHub(zap.NewNop(), broker)
+	require.NoError(t, second.Start(ctx))
+
+	tab, otherTab, branch := &inbox{}, &inbox{}, &inbox{}
+	aliceUser := user("alice")
+	workspaceId := uuid.New()
+	first.Join(ctx, modelId, workspaceId, aliceUser, tab.deliver, tab.disconnect)
+	second.Join(ctx, modelId, workspaceId, aliceUser, otherTab.deliver, otherTab.disconnect)
+	first.Join(ctx, modelId, uuid.New(), aliceUser, branch.deliver, branch.disconnect)
+
+	first.CloseWorkspace(ctx, modelId, workspaceId, "workspace deleted")
+
+	require.Equal(t, "workspace deleted", tab.closedWith())
+	require.Equal(t, "workspace deleted", otherTab.closedWith())
+	require.Empty(t, branch.closedWith())
+}
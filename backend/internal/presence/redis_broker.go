@@ -0,0 +1,59 @@
+package presence
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Broker over a Redis pub/sub channel. Presence is only useful live, so messages published
+// while an instance is disconnected are simply lost.
+type RedisBroker struct {
+	Logger      *zap.Logger
+	RedisClient *redis.Client
+	Channel     string
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, msg *protobufs.PresenceBroadcast) error {
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.RedisClient.Publish(ctx, b.Channel, bytes).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, handle func(*protobufs.PresenceBroadcast)) error {
+	pubsub := b.RedisClient.Subscribe(ctx, b.Channel)
+	// Waits for the subscription, so that nothing published after Subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				broadcast := &protobufs.PresenceBroadcast{}
+				if err := proto.Unmarshal([]byte(msg.Payload), broadcast); err != nil {
+					b.Logger.Warn("error unmarshalling presence", zap.Error(err))
+					continue
+				}
+				handle(broadcast)
+			}
+		}
+	}()
+	return nil
+}
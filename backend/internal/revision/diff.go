@@ -0,0 +1,66 @@
+package revision
+
+import (
+	"fmt"
+
+	"omnicam.com/backend/internal/merge"
+	"omnicam.com/backend/internal/timeline"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+	"omnicam.com/backend/pkg/messages/protobufs"
+)
+
+// Structured difference between the models of two revisions, per camera and per face
+func Diff(from timeline.State, to timeline.State) (messages_model_workspace.RevisionDiff, error) {
+	cameras, err := merge.DiffDocuments(from.Cameras, to.Cameras)
+	if err != nil {
+		return messages_model_workspace.RevisionDiff{}, fmt.Errorf("diffing cameras: %w", err)
+	}
+	faces, err := merge.DiffDocuments(from.Faces, to.Faces)
+	if err != nil {
+		return messages_model_workspace.RevisionDiff{}, fmt.Errorf("diffing faces: %w", err)
+	}
+
+	calibration := []messages_model_workspace.FieldChange{}
+	if from.Calibration.ScaleFactor != to.Calibration.ScaleFactor {
+		calibration = append(calibration, messages_model_workspace.FieldChange{
+			Path: []string{"scaleFactor"},
+			From: from.Calibration.ScaleFactor,
+			To:   to.Calibration.ScaleFactor,
+		})
+	}
+	if from.Calibration.ModelHeight != to.Calibration.ModelHeight {
+		calibration = append(calibration, messages_model_workspace.FieldChange{
+			Path: []string{"modelHeight"},
+			From: from.Calibration.ModelHeight,
+			To:   to.Calibration.ModelHeight,
+		})
+	}
+
+	return messages_model_workspace.RevisionDiff{
+		Cameras:     cameras,
+		Faces:       faces,
+		Calibration: calibration,
+	}, nil
+}
+
+// Ids of the cameras added, removed and changed when main moved from before to after, sorted
+func CamerasChange(before messages_cameras.Cameras, after messages_cameras.Cameras) (*protobufs.MainCamerasChange, error) {
+	changes, err := merge.DiffDocuments(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("diffing cameras: %w", err)
+	}
+
+	change := &protobufs.MainCamerasChange{}
+	for _, camera := range changes {
+		switch camera.Change {
+		case merge.ChangeAdded:
+			change.Added = append(change.Added, camera.Id)
+		case merge.ChangeRemoved:
+			change.Removed = append(change.Removed, camera.Id)
+		default:
+			change.Changed = append(change.Changed, camera.Id)
+		}
+	}
+	return change, nil
+}
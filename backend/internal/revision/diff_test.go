@@ -0,0 +1,83 @@
+package revision_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/merge"
+	"omnicam.com/backend/internal/revision"
+	"omnicam.com/backend/internal/timeline"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	from, err := timeline.StateOf(
+		[]byte(`{"cam-a":{"name":"a","posX":1,"frustumColor":{"r":0,"g":1}},"cam-b":{"name":"b"}}`),
+		[]byte(`{"face-a":{"id":"face-a","points":[{"x":0},{"x":1}]}}`),
+		1, 0,
+	)
+	require.NoError(t, err)
+	to, err := timeline.StateOf(
+		[]byte(`{"cam-a":{"posX":2,"name":"a","frustumColor":{"g":1,"r":0.5}},"cam-c":{"name":"c"}}`),
+		[]byte(`{"face-a":{"id":"face-a","points":[{"x":0},{"x":2}]}}`),
+		1, 3,
+	)
+	require.NoError(t, err)
+
+	diff, err := revision.Diff(from, to)
+	require.NoError(t, err)
+
+	require.Equal(t, []messages_model_workspace.DocumentChange{
+		{Id: "cam-a", Change: merge.ChangeChanged, Fields: []messages_model_workspace.FieldChange{
+			{Path: []string{"frustumColor", "r"}, From: 0.0, To: 0.5},
+			{Path: []string{"posX"}, From: 1.0, To: 2.0},
+		}},
+		{Id: "cam-b", Change: merge.ChangeRemoved, Fields: []messages_model_workspace.FieldChange{
+			{Path: []string{"name"}, From: "b"},
+		}},
+		{Id: "cam-c", Change: merge.ChangeAdded, Fields: []messages_model_workspace.FieldChange{
+			{Path: []string{"name"}, To: "c"},
+		}},
+	}, diff.Cameras)
+
+	// The corners are compared as a whole
+	require.Len(t, diff.Faces, 1)
+	require.Equal(t, []string{"points"}, diff.Faces[0].Fields[0].Path)
+
+	require.Equal(t, []messages_model_workspace.FieldChange{
+		{Path: []string{"modelHeight"}, From: 0.0, To: 3.0},
+	}, diff.Calibration)
+}
+
+func TestDiffSameRevision(t *testing.T) {
+	t.Parallel()
+
+	state, err := timeline.StateOf([]byte(`{"cam-a":{"name":"a"}}`), nil, 1, 0)
+	require.NoError(t, err)
+
+	diff, err := revision.Diff(state, state)
+	require.NoError(t, err)
+	require.Empty(t, diff.Cameras)
+	require.Empty(t, diff.Faces)
+	require.Empty(t, diff.Calibration)
+}
+
+func TestCamerasChange(t *testing.T) {
+	t.Parallel()
+
+	cam := messages_cameras.CameraStruct{Name: "cam"}
+	moved := cam
+	moved.PosY = 1
+
+	change, err := revision.CamerasChange(
+		messages_cameras.Cameras{"cam-b": cam, "cam-a": cam, "cam-c": cam},
+		messages_cameras.Cameras{"cam-b": moved, "cam-a": cam, "cam-d": cam},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"cam-d"}, change.Added)
+	require.Equal(t, []string{"cam-c"}, change.Removed)
+	require.Equal(t, []string{"cam-b"}, change.Changed)
+}
@@ -0,0 +1,96 @@
+package revision
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/timeline"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+// Messages of the revisions saved without one
+const (
+	MessageInitial = "Initial revision"
+	MessageMerge   = "Merge workspace"
+	MessageResolve = "Resolve merge conflicts"
+)
+
+// What is saved to main, nil fields are left as they are. Message describes the revision.
+type Change struct {
+	Cameras     []byte
+	Faces       []byte
+	Calibration *history.Calibration
+	Message     string
+}
+
+// Model version and revision after a change
+type Saved struct {
+	Version  int32
+	Revision int32
+}
+
+// Saves the change to the model, records it in the model timeline and snapshots the model as a
+// new revision. queries must belong to a transaction. The version is only bumped by new cameras
+// or faces.
+func Save(ctx context.Context, queries *db_sqlc_gen.Queries, modelId uuid.UUID, userId uuid.UUID, change Change) (Saved, error) {
+	current, err := queries.GetModelStateForUpdate(ctx, modelId)
+	if err != nil {
+		return Saved{}, err
+	}
+	before, err := timeline.StateOf(current.Cameras, current.TargetAreaTrapezoids, current.ScaleFactor, current.ModelHeight)
+	if err != nil {
+		return Saved{}, err
+	}
+	after := before.Clone()
+
+	version := current.Version
+	if change.Cameras != nil || change.Faces != nil {
+		version, err = queries.UpdateModelLayout(ctx, db_sqlc_gen.UpdateModelLayoutParams{
+			Cameras:              change.Cameras,
+			TargetAreaTrapezoids: change.Faces,
+			ModelID:              modelId,
+		})
+		if err != nil {
+			return Saved{}, err
+		}
+	}
+	if change.Cameras != nil {
+		after.Cameras = make(map[string]json.RawMessage)
+		if err := json.Unmarshal(change.Cameras, &after.Cameras); err != nil {
+			return Saved{}, err
+		}
+	}
+	if change.Faces != nil {
+		after.Faces = make(map[string]json.RawMessage)
+		if err := json.Unmarshal(change.Faces, &after.Faces); err != nil {
+			return Saved{}, err
+		}
+	}
+
+	if change.Calibration != nil {
+		if _, err := queries.UpdateModelCalibration(ctx, db_sqlc_gen.UpdateModelCalibrationParams{
+			ModelID:     modelId,
+			ScaleFactor: change.Calibration.ScaleFactor,
+			ModelHeight: change.Calibration.ModelHeight,
+		}); err != nil {
+			return Saved{}, err
+		}
+		after.Calibration = *change.Calibration
+	}
+
+	if err := timeline.Record(ctx, queries, modelId, userId, version, before, after); err != nil {
+		return Saved{}, err
+	}
+
+	revision, err := queries.CreateModelRevision(ctx, db_sqlc_gen.CreateModelRevisionParams{
+		UserID:  userId,
+		Message: change.Message,
+		ModelID: modelId,
+	})
+	if err != nil {
+		return Saved{}, err
+	}
+	return Saved{Version: version, Revision: revision}, nil
+}
@@ -1,34 +1,34 @@
 package api_routes
 
 import (
-	"net/http"
-	"sync"
-
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/optimizer"
+	"omnicam.com/backend/internal/presence"
 
 	// controller_test "omnicam.com/backend/internal/controllers"
 	"omnicam.com/backend/internal/controllers/authentication"
 	controller_files "omnicam.com/backend/internal/controllers/files"
 	controller_users "omnicam.com/backend/internal/controllers/users"
 	"omnicam.com/backend/internal/middleware"
+	"omnicam.com/backend/internal/utils"
 
 	controller_camera "omnicam.com/backend/internal/controllers/cameras"
 	controller_model "omnicam.com/backend/internal/controllers/models"
+	controller_optimizations "omnicam.com/backend/internal/controllers/optimizations"
 	controller_projects "omnicam.com/backend/internal/controllers/projects"
 	controller_workspaces "omnicam.com/backend/internal/controllers/workspaces"
 	db_client "omnicam.com/backend/pkg/db"
 )
 
 type Dependencies struct {
-	Logger          *zap.Logger
-	Env             *config_env.AppEnv
-	DB              *db_client.DB
-	RedisClient     *redis.Client
-	OptimizeRespMap *sync.Map
+	Logger   *zap.Logger
+	Env      *config_env.AppEnv
+	DB       *db_client.DB
+	JobQueue optimizer.JobQueue
+	Presence *presence.Hub
 }
 
 func InitRoutes(deps Dependencies, router gin.IRouter) {
@@ -89,6 +89,13 @@ func InitRoutes(deps Dependencies, router gin.IRouter) {
 	}
 	putModelRoute.InitUpdateModelRoute(protectedRoute)
 
+	putModelProtectionRoute := controller_model.PutModelProtectionRoute{
+		Logger: deps.Logger,
+		Env:    deps.Env,
+		DB:     deps.DB,
+	}
+	putModelProtectionRoute.InitPutModelProtectionRoute(protectedRoute)
+
 	deleteModelRoute := controller_model.DeleteModelRoute{
 		Logger: deps.Logger,
 		Env:    deps.Env,
@@ -96,29 +103,52 @@ func InitRoutes(deps Dependencies, router gin.IRouter) {
 	}
 	deleteModelRoute.InitDeleteModelRoute(protectedRoute)
 
+	modelTimelineRoute := controller_model.ModelTimelineRoute{
+		Logger: deps.Logger,
+		Env:    deps.Env,
+		DB:     deps.DB,
+	}
+	modelTimelineRoute.InitModelTimelineRoute(protectedRoute)
+
+	modelRevisionRoute := controller_model.ModelRevisionRoute{
+		Logger:   deps.Logger,
+		Env:      deps.Env,
+		DB:       deps.DB,
+		Presence: deps.Presence,
+	}
+	modelRevisionRoute.InitModelRevisionRoute(protectedRoute)
+
 	cameraAutosaveRoute := controller_camera.UpdateEventRoute{
-		Logger:          deps.Logger,
-		Env:             deps.Env,
-		DB:              deps.DB,
-		RedisClient:     deps.RedisClient,
-		OptimizeRespMap: deps.OptimizeRespMap,
+		Logger:   deps.Logger,
+		Env:      deps.Env,
+		DB:       deps.DB,
+		JobQueue: deps.JobQueue,
+		Presence: deps.Presence,
 		Upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return r.Header.Get("Origin") == deps.Env.FrontendHost
-			},
+			CheckOrigin:     utils.CheckOrigin(deps.Env.FrontendHost),
 		},
 	}
 	cameraAutosaveRoute.InitRoute(protectedRoute)
 
 	workspaceRoute := controller_workspaces.WorkspaceRoute{
-		Logger: deps.Logger,
-		Env:    deps.Env,
-		DB:     deps.DB,
+		Logger:   deps.Logger,
+		Env:      deps.Env,
+		DB:       deps.DB,
+		Presence: deps.Presence,
 	}
 	workspaceRoute.InitRoute(protectedRoute)
 
+	optimizationRoute := controller_optimizations.OptimizationRoute{
+		Logger:   deps.Logger,
+		Env:      deps.Env,
+		DB:       deps.DB,
+		JobQueue: deps.JobQueue,
+		Presence: deps.Presence,
+	}
+	optimizationRoute.InitRoute(protectedRoute)
+
 	putImageModelRoute := controller_model.PutImageModelRoute{
 		Logger: deps.Logger,
 		Env:    deps.Env,
@@ -0,0 +1,143 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/utils"
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+// Layout of a model, rebuilt by replaying its events
+type State struct {
+	Cameras     map[string]json.RawMessage
+	Faces       map[string]json.RawMessage
+	Calibration history.Calibration
+}
+
+// State of a model before its first event
+func NewState() State {
+	return State{
+		Cameras:     make(map[string]json.RawMessage),
+		Faces:       make(map[string]json.RawMessage),
+		Calibration: history.Calibration{ScaleFactor: 1.0},
+	}
+}
+
+// State from the columns of a model, cameras and faces may be empty
+func StateOf(cameras []byte, faces []byte, scaleFactor float64, modelHeight float64) (State, error) {
+	s := NewState()
+	if len(cameras) > 0 {
+		if err := json.Unmarshal(cameras, &s.Cameras); err != nil {
+			return State{}, fmt.Errorf("unmarshalling cameras: %w", err)
+		}
+	}
+	if len(faces) > 0 {
+		if err := json.Unmarshal(faces, &s.Faces); err != nil {
+			return State{}, fmt.Errorf("unmarshalling faces: %w", err)
+		}
+	}
+	s.Calibration = history.Calibration{ScaleFactor: scaleFactor, ModelHeight: modelHeight}
+	return s, nil
+}
+
+func (s State) Clone() State {
+	return State{
+		Cameras:     maps.Clone(s.Cameras),
+		Faces:       maps.Clone(s.Faces),
+		Calibration: s.Calibration,
+	}
+}
+
+// Change of a camera, a face or the calibration. Value is nil when the target was deleted.
+type Event struct {
+	Kind     string
+	TargetId string
+	Value    json.RawMessage
+}
+
+func (s *State) Apply(e Event) error {
+	switch e.Kind {
+	case history.KindCamera:
+		setOrDelete(s.Cameras, e.TargetId, e.Value)
+	case history.KindFace:
+		setOrDelete(s.Faces, e.TargetId, e.Value)
+	case history.KindCalibration:
+		if err := json.Unmarshal(e.Value, &s.Calibration); err != nil {
+			return fmt.Errorf("unmarshalling calibration: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown event kind %q", e.Kind)
+	}
+	return nil
+}
+
+func setOrDelete(values map[string]json.RawMessage, key string, value json.RawMessage) {
+	if value == nil {
+		delete(values, key)
+		return
+	}
+	values[key] = value
+}
+
+func keyedChanges(kind string, before map[string]json.RawMessage, after map[string]json.RawMessage) []Event {
+	union := make(map[string]json.RawMessage, len(before)+len(after))
+	maps.Copy(union, before)
+	maps.Copy(union, after)
+
+	events := []Event{}
+	for _, id := range slices.Sorted(maps.Keys(union)) {
+		value, ok := after[id]
+		if !ok {
+			events = append(events, Event{Kind: kind, TargetId: id})
+			continue
+		}
+		if !utils.SameJSON(before[id], value) {
+			events = append(events, Event{Kind: kind, TargetId: id, Value: value})
+		}
+	}
+	return events
+}
+
+// Events that turn before into after, the cameras first, then the faces and the calibration
+func Changes(before State, after State) ([]Event, error) {
+	events := keyedChanges(history.KindCamera, before.Cameras, after.Cameras)
+	events = append(events, keyedChanges(history.KindFace, before.Faces, after.Faces)...)
+
+	if before.Calibration != after.Calibration {
+		value, err := json.Marshal(after.Calibration)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, Event{Kind: history.KindCalibration, Value: value})
+	}
+	return events, nil
+}
+
+// Records what a user saved to a model, in the transaction that saved it. version is the model
+// version after the change.
+func Record(ctx context.Context, queries *db_sqlc_gen.Queries, modelId uuid.UUID, userId uuid.UUID, version int32, before State, after State) error {
+	events, err := Changes(before, after)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if err := queries.CreateModelEvent(ctx, db_sqlc_gen.CreateModelEventParams{
+			ModelID:  modelId,
+			Version:  version,
+			UserID:   userId,
+			Kind:     e.Kind,
+			TargetID: e.TargetId,
+			Value:    e.Value,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
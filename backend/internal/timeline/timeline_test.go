@@ -0,0 +1,71 @@
+package timeline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"omnicam.com/backend/internal/history"
+	"omnicam.com/backend/internal/timeline"
+)
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+
+	before, err := timeline.StateOf(
+		[]byte(`{"cam-a":{"name":"a","posX":1},"cam-b":{"name":"b"}}`),
+		[]byte(`{"face-a":{"id":"face-a","name":"a"}}`),
+		1, 0,
+	)
+	require.NoError(t, err)
+	after, err := timeline.StateOf(
+		[]byte(`{"cam-a":{"posX":1,"name":"a"},"cam-c":{"name":"c"}}`),
+		[]byte(`{"face-a":{"id":"face-a","name":"renamed"}}`),
+		2, 0,
+	)
+	require.NoError(t, err)
+
+	events, err := timeline.Changes(before, after)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	// Same camera with its keys in another order isn't a change
+	require.Equal(t, timeline.Event{Kind: history.KindCamera, TargetId: "cam-b"}, events[0])
+	require.Equal(t, "cam-c", events[1].TargetId)
+	require.Equal(t, history.KindFace, events[2].Kind)
+	require.Equal(t, history.KindCalibration, events[3].Kind)
+	require.JSONEq(t, `{"scaleFactor":2,"modelHeight":0}`, string(events[3].Value))
+
+	// Replaying the changes over before gives after
+	replayed := before.Clone()
+	for _, e := range events {
+		require.NoError(t, replayed.Apply(e))
+	}
+	require.Equal(t, after.Calibration, replayed.Calibration)
+	require.Len(t, replayed.Cameras, 2)
+	require.JSONEq(t, `{"name":"c"}`, string(replayed.Cameras["cam-c"]))
+	require.JSONEq(t, `{"id":"face-a","name":"renamed"}`, string(replayed.Faces["face-a"]))
+
+	// before is left as it was
+	require.Contains(t, before.Cameras, "cam-b")
+}
+
+func TestChangesFromEmpty(t *testing.T) {
+	t.Parallel()
+
+	after := timeline.NewState()
+	after.Cameras["cam-a"] = json.RawMessage(`{"name":"a"}`)
+
+	events, err := timeline.Changes(timeline.State{}, after)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "cam-a", events[0].TargetId)
+	require.Equal(t, history.KindCalibration, events[1].Kind)
+}
+
+func TestApplyUnknownKind(t *testing.T) {
+	t.Parallel()
+
+	state := timeline.NewState()
+	require.Error(t, state.Apply(timeline.Event{Kind: "model"}))
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Compares JSON values, ignoring the formatting and the order of the keys. nil is only equal to
+// nil, unlike the JSON null.
+func SameJSON(a json.RawMessage, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	var decodedA, decodedB any
+	if json.Unmarshal(a, &decodedA) != nil || json.Unmarshal(b, &decodedB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(decodedA, decodedB)
+}
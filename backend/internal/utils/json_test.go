@@ -0,0 +1,32 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"omnicam.com/backend/internal/utils"
+)
+
+func TestSameJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a    json.RawMessage
+		b    json.RawMessage
+		want bool
+	}{
+		{"key order", json.RawMessage(`{"x":1,"y":2}`), json.RawMessage(`{"y":2,"x":1}`), true},
+		{"formatting", json.RawMessage(`{"x": 1.0}`), json.RawMessage(`{"x":1}`), true},
+		{"nested", json.RawMessage(`{"c":{"r":1}}`), json.RawMessage(`{"c":{"r":2}}`), false},
+		{"both nil", nil, nil, true},
+		{"nil and null", nil, json.RawMessage(`null`), false},
+		{"invalid", json.RawMessage(`{`), json.RawMessage(`{`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.SameJSON(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameJSON(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
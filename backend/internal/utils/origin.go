@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Splits an origin into its lowercased scheme, host and port, the port defaulting to the one of the scheme
+func parseOrigin(origin string) (scheme string, host string, port string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return "", "", "", false
+	}
+
+	scheme = strings.ToLower(u.Scheme)
+	host = strings.ToLower(u.Hostname())
+	port = u.Port()
+	if port == "" {
+		port = defaultPorts[scheme]
+	}
+	return scheme, host, port, true
+}
+
+// Reports whether origin has the same scheme, host and port as one of the allowed origins
+func SameOrigin(origin string, allowed ...string) bool {
+	scheme, host, port, ok := parseOrigin(origin)
+	if !ok {
+		return false
+	}
+
+	for _, a := range allowed {
+		aScheme, aHost, aPort, ok := parseOrigin(a)
+		if ok && scheme == aScheme && net.JoinHostPort(host, port) == net.JoinHostPort(aHost, aPort) {
+			return true
+		}
+	}
+	return false
+}
+
+// Origin check for websocket upgrades. Requests without an Origin header don't come from a
+// browser, so they can't be forged by another site and are accepted.
+func CheckOrigin(allowed ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return SameOrigin(origin, allowed...)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -18,3 +19,29 @@ func UuidToPgUuid(uuid uuid.UUID) (pgtype.UUID, error) {
 		Valid: true,
 	}, nil
 }
+
+// Nil when the value is NULL
+func OptionalUuid(id pgtype.UUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	parsed := uuid.UUID(id.Bytes)
+	return &parsed
+}
+
+// Nil when the value is NULL
+func OptionalText(t pgtype.Text) *string {
+	if !t.Valid {
+		return nil
+	}
+	return &t.String
+}
+
+// RFC 3339 time, nil when the value is NULL
+func FormatOptionalTime(t pgtype.Timestamptz) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.Time.Format(time.RFC3339)
+	return &formatted
+}
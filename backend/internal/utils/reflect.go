@@ -2,6 +2,7 @@ package utils
 
 import (
 	"reflect"
+	"strings"
 )
 
 func SetFieldByJSONTag(ptr any, key string, val reflect.Value) bool {
@@ -10,7 +11,8 @@ func SetFieldByJSONTag(ptr any, key string, val reflect.Value) bool {
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if field.Tag.Get("json") == key {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",") // drop options like omitempty
+		if name == key {
 			f := v.Field(i)
 			if f.CanSet() {
 				if val.Type().AssignableTo(f.Type()) {
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	db_sqlc_gen "omnicam.com/backend/pkg/db/sqlc-gen"
+)
+
+// Roles allowed to apply merge requests, to protect main and to change a protected main
+func CanMerge(role db_sqlc_gen.NullRole) bool {
+	if !role.Valid {
+		return false
+	}
+	switch role.Role {
+	case db_sqlc_gen.RoleOwner, db_sqlc_gen.RoleProjectManager:
+		return true
+	default:
+		return false
+	}
+}
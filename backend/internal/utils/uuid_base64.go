@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func ParseUuidBase64(strId string) (uuid.UUID, error) {
@@ -20,6 +21,18 @@ func ParseUuidBase64(strId string) (uuid.UUID, error) {
 	return parsedId, nil
 }
 
+// Parses an optional id, the empty string is NULL
+func ParseNullUuidBase64(strId string) (pgtype.UUID, error) {
+	if strId == "" {
+		return pgtype.UUID{}, nil
+	}
+	parsedId, err := ParseUuidBase64(strId)
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return pgtype.UUID{Bytes: parsedId, Valid: true}, nil
+}
+
 func GetUuidFromCtx(c *gin.Context, key string) (uuid.UUID, error) {
 	anyUserId, exists := c.Get(key)
 	if !exists {
@@ -2,63 +2,23 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"sync"
+	"net"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 	config_env "omnicam.com/backend/config"
+	"omnicam.com/backend/internal/optimizer"
+	optimizer_greedy "omnicam.com/backend/internal/optimizer/greedy"
+	"omnicam.com/backend/internal/presence"
 	api_routes "omnicam.com/backend/internal/routes"
 	"omnicam.com/backend/internal/utils"
 	db_client "omnicam.com/backend/pkg/db"
 	"omnicam.com/backend/pkg/logger"
 )
 
-func StartResponseListener(redisClient *redis.Client, env *config_env.AppEnv, responseRegistry *sync.Map) {
-	go func() {
-		ctx := context.Background()
-		for {
-			// Read from the single response stream
-			entries, err := redisClient.XRead(ctx, &redis.XReadArgs{
-				Streams: []string{env.OptiResTopic, "$"},
-				Block:   0, // Block indefinitely for new results
-				Count:   10,
-			}).Result()
-
-			if err != nil {
-				continue
-			}
-
-			for _, stream := range entries {
-				for _, msg := range stream.Messages {
-					rawJSON, ok := msg.Values["data"].(string)
-
-					if !ok {
-						// TODO: Error handling
-						continue
-					}
-
-					// Peek at the Job ID in the JSON
-					var temp struct {
-						JobID string `json:"jobId"`
-					}
-					json.Unmarshal([]byte(rawJSON), &temp)
-
-					// Find the waiting handler
-					if ch, ok := responseRegistry.Load(temp.JobID); ok {
-						// Send the raw data to that specific handler's channel
-						ch.(chan string) <- rawJSON
-						// Cleanup the registry
-						responseRegistry.Delete(temp.JobID)
-					}
-				}
-			}
-		}
-	}()
-}
-
 func main() {
 	utils.RegisterCustomValidations()
 
@@ -69,15 +29,92 @@ func main() {
 
 	client_db := db_client.InitDatabase(env)
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
+	var redisClient *redis.Client
+	if env.OptiQueue == "redis" || env.PresenceBroker == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     net.JoinHostPort(env.RedisHost, env.RedisPort),
+			Password: env.RedisPassword,
+			DB:       env.RedisDB,
+		})
+	}
+
+	var jobQueue optimizer.JobQueue
+	switch env.OptiQueue {
+	case "memory":
+		memoryQueue := optimizer.NewMemoryJobQueue(logger, client_db.Queries, optimizer_greedy.Handle, env.OptiWorkers)
+		if err := memoryQueue.Start(context.Background()); err != nil {
+			logger.Fatal("Error while starting optimization workers", zap.Error(err))
+		}
+		jobQueue = memoryQueue
+	case "redis":
+		redisQueue := &optimizer.RedisJobQueue{
+			Logger:      logger,
+			Env:         env,
+			DB:          client_db,
+			RedisClient: redisClient,
+		}
+		if err := redisQueue.Start(context.Background()); err != nil {
+			logger.Fatal("Error while starting optimization response listener", zap.Error(err))
+		}
+
+		// Runs quick layouts, and jobs sent while the Python optimizer is down
+		localQueue := optimizer.NewMemoryJobQueue(logger, client_db.Queries, optimizer_greedy.Handle, env.OptiWorkers)
+		if err := localQueue.Start(context.Background()); err != nil {
+			logger.Fatal("Error while starting optimization workers", zap.Error(err))
+		}
+
+		jobQueue = &optimizer.FallbackJobQueue{
+			Logger:  logger,
+			Primary: redisQueue,
+			Local:   localQueue,
+		}
+	default:
+		logger.Fatal("Invalid OPTI_QUEUE", zap.String("queue", env.OptiQueue))
+	}
+
+	// The scheduler queue lives in memory, a single instance runs it so that its limits hold
+	if redisClient != nil {
+		lease := &optimizer.SchedulerLease{
+			Logger:      logger,
+			Env:         env,
+			RedisClient: redisClient,
+		}
+		if err := lease.Acquire(context.Background()); err != nil {
+			logger.Fatal("Error while acquiring the optimization scheduler lease", zap.Error(err))
+		}
+	}
 
-	var optimizeRespMap sync.Map
+	scheduler, err := optimizer.NewScheduler(logger, client_db.Queries, jobQueue, optimizer.Limits{
+		MaxRunning:           env.OptiMaxRunning,
+		MaxRunningPerUser:    env.OptiMaxRunningPerUser,
+		MaxRunningPerProject: env.OptiMaxRunningPerProject,
+		MaxQueued:            env.OptiMaxQueued,
+		MaxQueuedPerUser:     env.OptiMaxQueuedPerUser,
+		MaxQueuedPerProject:  env.OptiMaxQueuedPerProject,
+	})
+	if err != nil {
+		logger.Fatal("Invalid optimization limits", zap.Error(err))
+	}
+	if err := scheduler.Start(context.Background()); err != nil {
+		logger.Fatal("Error while starting the optimization scheduler", zap.Error(err))
+	}
 
-	StartResponseListener(redisClient, env, &optimizeRespMap)
+	var presenceBroker presence.Broker
+	switch env.PresenceBroker {
+	case "memory":
+	case "redis":
+		presenceBroker = &presence.RedisBroker{
+			Logger:      logger,
+			RedisClient: redisClient,
+			Channel:     env.PresenceChannel,
+		}
+	default:
+		logger.Fatal("Invalid PRESENCE_BROKER", zap.String("broker", env.PresenceBroker))
+	}
+	presenceHub := presence.NewHub(logger, presenceBroker)
+	if err := presenceHub.Start(context.Background()); err != nil {
+		logger.Fatal("Error while subscribing to presence", zap.Error(err))
+	}
 
 	router := gin.Default()
 
@@ -99,11 +136,11 @@ func main() {
 
 	apiV1 := router.Group("/api/v1")
 	api_routes.InitRoutes(api_routes.Dependencies{
-		Logger:          logger,
-		Env:             env,
-		DB:              client_db,
-		RedisClient:     redisClient,
-		OptimizeRespMap: &optimizeRespMap,
+		Logger:   logger,
+		Env:      env,
+		DB:       client_db,
+		JobQueue: scheduler,
+		Presence: presenceHub,
 	}, apiV1)
 
 	router.Run()
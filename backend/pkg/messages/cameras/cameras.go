@@ -55,29 +55,38 @@ func UnmarshalCameras(data []byte) (Cameras, error) {
 	}
 	result := make(Cameras)
 	for id, cameraBlob := range raw {
-		// Start with a fresh set of default values for EACH camera
-		cam := DefaultCam()
-
-		// Unmarshal the JSON blob into the defaulted struct.
-		// json.Unmarshal only overwrites fields that ARE present in the JSON.
-		if err := json.Unmarshal(cameraBlob, &cam); err != nil {
+		cam, err := UnmarshalCamera(cameraBlob)
+		if err != nil {
 			return nil, err
 		}
-
-		if cam.AspectWidth != nil {
-			cam.WidthRes = *cam.AspectWidth
-			cam.AspectWidth = nil
-		}
-		if cam.AspectHeight != nil {
-			cam.HeightRes = *cam.AspectHeight
-			cam.AspectHeight = nil
-		}
 		result[CamId(id)] = cam
 	}
 
 	return result, nil
 }
 
+// Unmarshals a single camera of a cameras column
+func UnmarshalCamera(data []byte) (CameraStruct, error) {
+	// Start with a fresh set of default values for EACH camera
+	cam := DefaultCam()
+
+	// Unmarshal the JSON blob into the defaulted struct.
+	// json.Unmarshal only overwrites fields that ARE present in the JSON.
+	if err := json.Unmarshal(data, &cam); err != nil {
+		return CameraStruct{}, err
+	}
+
+	if cam.AspectWidth != nil {
+		cam.WidthRes = *cam.AspectWidth
+		cam.AspectWidth = nil
+	}
+	if cam.AspectHeight != nil {
+		cam.HeightRes = *cam.AspectHeight
+		cam.AspectHeight = nil
+	}
+	return cam, nil
+}
+
 func ProtoColorToColor(protoColor *camera.ColorRGBA) ColorRGBA {
 	color := ColorRGBA{R: 0.5, G: 0.5, B: 0.5, A: 0.5}
 	if protoColor != nil {
@@ -145,6 +154,31 @@ func ProtoCamToCam(protoCam *camera.Camera) CameraStruct {
 	return cam
 }
 
+func CamToProtoCam(id string, cam CameraStruct) *camera.Camera {
+	return &camera.Camera{
+		Id:                id,
+		Name:              cam.Name,
+		AngleX:            cam.AngleX,
+		AngleY:            cam.AngleY,
+		AngleZ:            cam.AngleZ,
+		AngleW:            cam.AngleW,
+		PosX:              cam.PosX,
+		PosY:              cam.PosY,
+		PosZ:              cam.PosZ,
+		Fov:               cam.Fov,
+		FrustumColor:      &camera.ColorRGBA{R: cam.FrustumColor.R, G: cam.FrustumColor.G, B: cam.FrustumColor.B, A: cam.FrustumColor.A},
+		FrustumLength:     cam.FrustumLength,
+		IsHidingArrows:    cam.IsHidingArrows,
+		IsHidingWheels:    cam.IsHidingWheels,
+		IsLockingPosition: cam.IsLockingPosition,
+		IsLockingRotation: cam.IsLockingRotation,
+		IsHidingFrustum:   cam.IsHidingFrustum,
+		WidthRes:          cam.WidthRes,
+		HeightRes:         cam.HeightRes,
+		Distortion:        &camera.Distortion{Enabled: cam.Distortion.Enabled, IsFisheye: cam.Distortion.IsFisheye},
+	}
+}
+
 func DefaultCam() CameraStruct {
 	return CameraStruct{
 		Name:              "Untitled",
@@ -22,4 +22,5 @@ type ModelWorkspace struct {
 	ModelExtension   string                          `json:"fileExtension"`
 	ImagePath        string                          `json:"imagePath"`
 	ImageExtension   string                          `json:"imageExtension"`
+	Protected        bool                            `json:"protected"`
 }
@@ -0,0 +1,38 @@
+package messages_model_workspace
+
+import (
+	"github.com/google/uuid"
+)
+
+// Snapshot of main saved by a merge, a resolve or a revert
+type Revision struct {
+	Revision  int32      `json:"revision"`
+	Version   int32      `json:"version"`
+	UserId    *uuid.UUID `json:"userId"`
+	Username  *string    `json:"username"`
+	Message   string     `json:"message"`
+	CreatedAt string     `json:"createdAt"`
+}
+
+// Field that differs between two revisions, From is null when it was added and To when it
+// was removed
+type FieldChange struct {
+	Path []string `json:"path"`
+	From any      `json:"from"`
+	To   any      `json:"to"`
+}
+
+// Camera or face that differs between two revisions, Change is added, removed or changed
+type DocumentChange struct {
+	Id     string        `json:"id"`
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields"`
+}
+
+type RevisionDiff struct {
+	From        int32            `json:"from"`
+	To          int32            `json:"to"`
+	Cameras     []DocumentChange `json:"cameras"`
+	Faces       []DocumentChange `json:"faces"`
+	Calibration []FieldChange    `json:"calibration"`
+}
@@ -0,0 +1,30 @@
+package messages_model_workspace
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+	messages_trapezoids "omnicam.com/backend/pkg/messages/trapezoids"
+)
+
+// Layout of a model rebuilt from its events
+type TimelineState struct {
+	Version          int32                          `json:"version"`
+	Cameras          messages_cameras.Cameras       `json:"cameras"`
+	TargetTrapezoids messages_trapezoids.Trapezoids `json:"targetTrapezoids"`
+	ScaleFactor      float64                        `json:"scaleFactor"`
+	ModelHeight      float64                        `json:"modelHeight"`
+}
+
+// Change merged into a model, Value is null when the target was deleted
+type TimelineEvent struct {
+	Id        int64           `json:"id"`
+	Version   int32           `json:"version"`
+	UserId    *uuid.UUID      `json:"userId"`
+	Username  *string         `json:"username"`
+	Kind      string          `json:"kind"`
+	TargetId  string          `json:"targetId"`
+	Value     json.RawMessage `json:"value"`
+	CreatedAt string          `json:"createdAt"`
+}
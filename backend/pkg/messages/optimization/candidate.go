@@ -0,0 +1,39 @@
+package messages_optimization
+
+import (
+	"github.com/google/uuid"
+	messages_cameras "omnicam.com/backend/pkg/messages/cameras"
+)
+
+type CandidateLayout struct {
+	ID             uuid.UUID                `json:"id"`
+	ModelId        uuid.UUID                `json:"modelId"`
+	UserId         uuid.UUID                `json:"userId"`
+	Username       string                   `json:"username"`
+	JobId          uuid.UUID                `json:"jobId"`
+	Name           string                   `json:"name"`
+	Cameras        messages_cameras.Cameras `json:"cameras,omitempty"`
+	FixedCameraIds []string                 `json:"fixedCameraIds"`
+	CreatedAt      string                   `json:"createdAt"`
+	UpdatedAt      string                   `json:"updatedAt"`
+}
+
+type CameraEntry struct {
+	ID     messages_cameras.CamId        `json:"id"`
+	Camera messages_cameras.CameraStruct `json:"camera"`
+}
+
+type CameraChange struct {
+	ID     messages_cameras.CamId        `json:"id"`
+	Fields []string                      `json:"fields"`
+	Before messages_cameras.CameraStruct `json:"before"`
+	After  messages_cameras.CameraStruct `json:"after"`
+}
+
+// Changes to the workspace cameras if a candidate were applied
+type CamerasDiff struct {
+	Added     []CameraEntry            `json:"added"`
+	Removed   []CameraEntry            `json:"removed"`
+	Changed   []CameraChange           `json:"changed"`
+	Unchanged []messages_cameras.CamId `json:"unchanged"`
+}
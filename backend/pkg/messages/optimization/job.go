@@ -0,0 +1,21 @@
+package messages_optimization
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+type OptimizationJob struct {
+	ID         uuid.UUID       `json:"id"`
+	ModelId    uuid.UUID       `json:"modelId"`
+	UserId     uuid.UUID       `json:"userId"`
+	Username   string          `json:"username"`
+	Status     string          `json:"status"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *string         `json:"error"`
+	CreatedAt  string          `json:"createdAt"`
+	UpdatedAt  string          `json:"updatedAt"`
+	FinishedAt *string         `json:"finishedAt"`
+}
@@ -42,3 +42,18 @@ func ProtoTrapezoidToTrapezoid(protoCam *protobufs.CoverageFace) TrapezoidStruct
 		Hidden: protoCam.Hidden,
 	}
 }
+
+func TrapezoidToProtoTrapezoid(trapezoid TrapezoidStruct) *protobufs.CoverageFace {
+	points := make([]*protobufs.ProtoVector3, len(trapezoid.Points))
+	for i, p := range trapezoid.Points {
+		points[i] = &protobufs.ProtoVector3{X: p.X, Y: p.Y, Z: p.Z}
+	}
+
+	return &protobufs.CoverageFace{
+		Id:     trapezoid.ID,
+		Name:   trapezoid.Name,
+		Points: points,
+		Color:  trapezoid.Color,
+		Hidden: trapezoid.Hidden,
+	}
+}
@@ -0,0 +1,12 @@
+package messages_workspace
+
+import "github.com/google/uuid"
+
+type Branch struct {
+	Id          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Version     int32     `json:"version"`
+	BaseVersion int32     `json:"baseVersion"`
+	CreatedAt   string    `json:"createdAt"`
+	UpdatedAt   string    `json:"updatedAt"`
+}
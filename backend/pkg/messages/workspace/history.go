@@ -0,0 +1,18 @@
+package messages_workspace
+
+import "encoding/json"
+
+type HistoryEvent struct {
+	Kind     string          `json:"kind"`
+	TargetId string          `json:"targetId"`
+	Before   json.RawMessage `json:"before"`
+	After    json.RawMessage `json:"after"`
+}
+
+type HistoryStep struct {
+	Version       int32          `json:"version"`
+	Action        string         `json:"action"`
+	TargetVersion *int32         `json:"targetVersion"`
+	Events        []HistoryEvent `json:"events"`
+	CreatedAt     string         `json:"createdAt"`
+}
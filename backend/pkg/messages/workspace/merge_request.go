@@ -0,0 +1,53 @@
+package messages_workspace
+
+import (
+	"github.com/google/uuid"
+	messages_model_workspace "omnicam.com/backend/pkg/messages/model_workspace"
+)
+
+// Changes of a workspace against its base, Calibration is against main
+type MergeRequestDiff struct {
+	BaseVersion int32                                     `json:"baseVersion"`
+	Version     int32                                     `json:"version"`
+	Cameras     []messages_model_workspace.DocumentChange `json:"cameras"`
+	Faces       []messages_model_workspace.DocumentChange `json:"faces"`
+	Calibration []messages_model_workspace.FieldChange    `json:"calibration"`
+}
+
+type MergeRequest struct {
+	Id               uuid.UUID  `json:"id"`
+	BranchId         *uuid.UUID `json:"branchId"`
+	UserId           uuid.UUID  `json:"userId"`
+	Username         string     `json:"username"`
+	Description      string     `json:"description"`
+	WorkspaceVersion int32      `json:"workspaceVersion"`
+	Status           string     `json:"status"`
+	MergedBy         *uuid.UUID `json:"mergedBy"`
+	MergedByUsername *string    `json:"mergedByUsername"`
+	Revision         *int32     `json:"revision"`
+	ApprovalCount    int64      `json:"approvalCount"`
+	CreatedAt        string     `json:"createdAt"`
+	UpdatedAt        string     `json:"updatedAt"`
+	ClosedAt         *string    `json:"closedAt"`
+}
+
+type MergeRequestComment struct {
+	Id        uuid.UUID `json:"id"`
+	UserId    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	Body      string    `json:"body"`
+	CreatedAt string    `json:"createdAt"`
+}
+
+type MergeRequestApproval struct {
+	UserId    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	CreatedAt string    `json:"createdAt"`
+}
+
+type MergeRequestDetail struct {
+	MergeRequest
+	Diff      MergeRequestDiff       `json:"diff"`
+	Comments  []MergeRequestComment  `json:"comments"`
+	Approvals []MergeRequestApproval `json:"approvals"`
+}
@@ -0,0 +1,3 @@
+DROP TABLE "optimization_job";
+
+DROP TYPE optimization_job_status;
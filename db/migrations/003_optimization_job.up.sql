@@ -0,0 +1,20 @@
+CREATE TYPE optimization_job_status AS ENUM('pending', 'succeeded', 'failed');
+
+-- optimization requests sent to the optimizer, kept after the websocket is gone
+CREATE TABLE "optimization_job" (
+  id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+  model_id UUID NOT NULL REFERENCES "model" (id) ON DELETE CASCADE,
+  -- user who requested the optimization
+  user_id UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  status optimization_job_status NOT NULL DEFAULT 'pending',
+  -- payload published to the optimizer
+  request JSONB NOT NULL,
+  -- optimizer response, set once the job is finished
+  result JSONB,
+  error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX optimization_job_model_id_created_at_idx ON "optimization_job" (model_id, created_at DESC);
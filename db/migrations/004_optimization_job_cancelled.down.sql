@@ -0,0 +1,17 @@
+UPDATE "optimization_job"
+SET
+  status = 'failed',
+  error = 'cancelled'
+WHERE
+  status = 'cancelled';
+
+ALTER TYPE optimization_job_status RENAME TO optimization_job_status_old;
+
+CREATE TYPE optimization_job_status AS ENUM('pending', 'succeeded', 'failed');
+
+ALTER TABLE "optimization_job"
+ALTER COLUMN status DROP DEFAULT,
+ALTER COLUMN status TYPE optimization_job_status USING status::TEXT::optimization_job_status,
+ALTER COLUMN status SET DEFAULT 'pending';
+
+DROP TYPE optimization_job_status_old;
@@ -0,0 +1 @@
+ALTER TYPE optimization_job_status ADD VALUE 'cancelled';
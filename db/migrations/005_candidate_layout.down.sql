@@ -0,0 +1 @@
+DROP TABLE "candidate_layout";
@@ -0,0 +1,17 @@
+-- successful optimization results, kept so that several runs can be compared before applying one
+CREATE TABLE "candidate_layout" (
+  id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+  model_id UUID NOT NULL REFERENCES "model" (id) ON DELETE CASCADE,
+  -- user who requested the optimization
+  user_id UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  optimization_job_id UUID NOT NULL UNIQUE REFERENCES "optimization_job" (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  -- optimized cameras, in the same format as user_model_workspace.cameras
+  cameras JSONB NOT NULL DEFAULT '{}'::JSONB,
+  -- workspace cameras the optimizer kept in place, applied along with the candidate
+  fixed_camera_ids TEXT[] NOT NULL DEFAULT '{}'::TEXT[],
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX candidate_layout_model_id_created_at_idx ON "candidate_layout" (model_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP TABLE "workspace_event";
+
+DROP TABLE "workspace_step";
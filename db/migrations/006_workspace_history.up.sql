@@ -0,0 +1,35 @@
+-- undo history of the workspaces, a step is an autosave batch, an undo or a redo. Rows are only
+-- appended, so that the history survives a reload of the editor.
+CREATE TABLE "workspace_step" (
+  model_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  -- workspace version the step produced
+  version INT NOT NULL,
+  action TEXT NOT NULL CHECK (action IN ('edit', 'undo', 'redo')),
+  -- version of the edit step undone or redone
+  target_version INT,
+  -- edit steps that undo and redo apply next, the last element first
+  undo_stack INT[] NOT NULL DEFAULT '{}'::INT[],
+  redo_stack INT[] NOT NULL DEFAULT '{}'::INT[],
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (model_id, user_id, version),
+  FOREIGN KEY (model_id, user_id) REFERENCES "user_model_workspace" (model_id, user_id) ON DELETE CASCADE
+);
+
+-- changes of a step, with the value before so that they can be inverted
+CREATE TABLE "workspace_event" (
+  model_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  version INT NOT NULL,
+  -- order of the event in its step
+  seq INT NOT NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('camera', 'face', 'calibration')),
+  -- camera or face id, empty for the calibration
+  target_id TEXT NOT NULL,
+  -- NULL when the target didn't exist
+  before_value JSONB,
+  -- NULL when the target was deleted
+  after_value JSONB,
+  PRIMARY KEY (model_id, user_id, version, seq),
+  FOREIGN KEY (model_id, user_id, version) REFERENCES "workspace_step" (model_id, user_id, version) ON DELETE CASCADE
+);
@@ -0,0 +1,60 @@
+-- changes saved to the models, never updated, so that the layout of a model can be rebuilt as it
+-- was at any point
+CREATE TABLE "model_event" (
+  id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  model_id UUID NOT NULL REFERENCES "model" (id) ON DELETE CASCADE,
+  -- model version once the event is applied, calibration changes don't bump it
+  version INT NOT NULL,
+  -- NULL for the state the models had when the log was introduced, or once the author is deleted
+  user_id UUID REFERENCES "user" (id) ON DELETE SET NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('camera', 'face', 'calibration')),
+  -- camera or face id, empty for the calibration
+  target_id TEXT NOT NULL,
+  -- NULL when the target was deleted
+  value JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX model_event_model_id_id_idx ON "model_event" (model_id, id);
+
+-- existing models start from their current state
+INSERT INTO
+  "model_event" (model_id, version, kind, target_id, value, created_at)
+SELECT
+  m.id,
+  m.version,
+  'camera',
+  cam.key,
+  cam.value,
+  m.updated_at
+FROM
+  "model" AS m,
+  JSONB_EACH(m.cameras) AS cam;
+
+INSERT INTO
+  "model_event" (model_id, version, kind, target_id, value, created_at)
+SELECT
+  m.id,
+  m.version,
+  'face',
+  face.key,
+  face.value,
+  m.updated_at
+FROM
+  "model" AS m,
+  JSONB_EACH(m.target_area_trapezoids) AS face;
+
+INSERT INTO
+  "model_event" (model_id, version, kind, target_id, value, created_at)
+SELECT
+  id,
+  version,
+  'calibration',
+  '',
+  JSONB_BUILD_OBJECT('scaleFactor', scale_factor, 'modelHeight', model_height),
+  updated_at
+FROM
+  "model"
+WHERE
+  scale_factor <> 1.0
+  OR model_height <> 0.0;
@@ -0,0 +1,2 @@
+ALTER TABLE "user_model_workspace"
+DROP COLUMN base_target_area_trapezoids;
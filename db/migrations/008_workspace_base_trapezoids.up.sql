@@ -0,0 +1,4 @@
+-- faces of main when the workspace was created or last merged, merged against like base_cameras.
+-- Existing workspaces never copied the faces of main, so they started from none.
+ALTER TABLE "user_model_workspace"
+ADD COLUMN base_target_area_trapezoids JSONB NOT NULL DEFAULT '{}'::JSONB;
@@ -0,0 +1,42 @@
+-- snapshot of main after every merge, resolve or revert, numbered per model
+CREATE TABLE "model_revision" (
+  PRIMARY KEY (model_id, revision),
+  model_id UUID NOT NULL REFERENCES "model" (id) ON DELETE CASCADE,
+  revision INT NOT NULL,
+  -- model version of the snapshot, calibration changes don't bump it
+  version INT NOT NULL,
+  -- NULL for the revisions of existing models, or once the author is deleted
+  user_id UUID REFERENCES "user" (id) ON DELETE SET NULL,
+  message TEXT NOT NULL DEFAULT '',
+  cameras JSONB NOT NULL,
+  target_area_trapezoids JSONB NOT NULL,
+  scale_factor FLOAT NOT NULL,
+  model_height FLOAT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- existing models start from their current state
+INSERT INTO
+  "model_revision" (
+    model_id,
+    revision,
+    version,
+    message,
+    cameras,
+    target_area_trapezoids,
+    scale_factor,
+    model_height,
+    created_at
+  )
+SELECT
+  id,
+  1,
+  version,
+  'Initial revision',
+  cameras,
+  target_area_trapezoids,
+  scale_factor,
+  model_height,
+  updated_at
+FROM
+  "model";
@@ -0,0 +1,57 @@
+-- only the default workspaces fit the previous keys, the other branches are lost
+DELETE FROM "user_model_workspace"
+WHERE
+  name <> 'default';
+
+ALTER TABLE "workspace_step"
+ADD COLUMN model_id UUID,
+ADD COLUMN user_id UUID;
+
+UPDATE "workspace_step" AS s
+SET
+  model_id = w.model_id,
+  user_id = w.user_id
+FROM
+  "user_model_workspace" AS w
+WHERE
+  w.id = s.workspace_id;
+
+ALTER TABLE "workspace_event"
+ADD COLUMN model_id UUID,
+ADD COLUMN user_id UUID;
+
+UPDATE "workspace_event" AS e
+SET
+  model_id = w.model_id,
+  user_id = w.user_id
+FROM
+  "user_model_workspace" AS w
+WHERE
+  w.id = e.workspace_id;
+
+ALTER TABLE "workspace_event"
+DROP COLUMN workspace_id,
+ALTER COLUMN model_id
+SET NOT NULL,
+ALTER COLUMN user_id
+SET NOT NULL;
+
+ALTER TABLE "workspace_step"
+DROP COLUMN workspace_id,
+ALTER COLUMN model_id
+SET NOT NULL,
+ALTER COLUMN user_id
+SET NOT NULL;
+
+ALTER TABLE "user_model_workspace"
+DROP COLUMN id,
+DROP COLUMN name,
+ADD PRIMARY KEY (model_id, user_id);
+
+ALTER TABLE "workspace_step"
+ADD PRIMARY KEY (model_id, user_id, version),
+ADD FOREIGN KEY (model_id, user_id) REFERENCES "user_model_workspace" (model_id, user_id) ON DELETE CASCADE;
+
+ALTER TABLE "workspace_event"
+ADD PRIMARY KEY (model_id, user_id, version, seq),
+ADD FOREIGN KEY (model_id, user_id, version) REFERENCES "workspace_step" (model_id, user_id, version) ON DELETE CASCADE;
@@ -0,0 +1,56 @@
+-- named workspaces (branches), a user can have several per model. The one named 'default' is the
+-- workspace of the /workspaces/me endpoints.
+ALTER TABLE "user_model_workspace"
+ADD COLUMN id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN name TEXT NOT NULL DEFAULT 'default';
+
+-- the history belongs to a branch instead of a user and a model
+ALTER TABLE "workspace_step"
+ADD COLUMN workspace_id UUID;
+
+UPDATE "workspace_step" AS s
+SET
+  workspace_id = w.id
+FROM
+  "user_model_workspace" AS w
+WHERE
+  w.model_id = s.model_id
+  AND w.user_id = s.user_id;
+
+ALTER TABLE "workspace_event"
+ADD COLUMN workspace_id UUID;
+
+UPDATE "workspace_event" AS e
+SET
+  workspace_id = w.id
+FROM
+  "user_model_workspace" AS w
+WHERE
+  w.model_id = e.model_id
+  AND w.user_id = e.user_id;
+
+-- dropping the columns drops the keys built on them
+ALTER TABLE "workspace_event"
+DROP COLUMN model_id,
+DROP COLUMN user_id,
+ALTER COLUMN workspace_id
+SET NOT NULL;
+
+ALTER TABLE "workspace_step"
+DROP COLUMN model_id,
+DROP COLUMN user_id,
+ALTER COLUMN workspace_id
+SET NOT NULL;
+
+ALTER TABLE "user_model_workspace"
+DROP CONSTRAINT user_model_workspace_pkey,
+ADD PRIMARY KEY (id),
+ADD UNIQUE (model_id, user_id, name);
+
+ALTER TABLE "workspace_step"
+ADD PRIMARY KEY (workspace_id, version),
+ADD FOREIGN KEY (workspace_id) REFERENCES "user_model_workspace" (id) ON DELETE CASCADE;
+
+ALTER TABLE "workspace_event"
+ADD PRIMARY KEY (workspace_id, version, seq),
+ADD FOREIGN KEY (workspace_id, version) REFERENCES "workspace_step" (workspace_id, version) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+DROP TABLE "merge_request_approval";
+
+DROP TABLE "merge_request_comment";
+
+DROP TABLE "merge_request";
+
+DROP TYPE merge_request_status;
+
+ALTER TABLE "model"
+DROP COLUMN protected;
@@ -0,0 +1,52 @@
+-- a protected main only changes through approved merge requests
+ALTER TABLE "model"
+ADD COLUMN protected BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TYPE merge_request_status AS ENUM('open', 'merged', 'closed');
+
+-- request to merge a workspace into main, reviewed by the members of the project
+CREATE TABLE "merge_request" (
+  id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+  model_id UUID NOT NULL REFERENCES "model" (id) ON DELETE CASCADE,
+  -- NULL once the workspace is deleted, a resolve deletes it
+  workspace_id UUID REFERENCES "user_model_workspace" (id) ON DELETE SET NULL,
+  -- author, owner of the workspace
+  user_id UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  description TEXT NOT NULL DEFAULT '',
+  -- changes of the workspace against its base, computed when opened or refreshed
+  diff JSONB NOT NULL,
+  -- workspace version of the diff, the approvals are for this version
+  workspace_version INT NOT NULL,
+  status merge_request_status NOT NULL DEFAULT 'open',
+  merged_by UUID REFERENCES "user" (id) ON DELETE SET NULL,
+  -- revision of main saved by the merge
+  revision INT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX merge_request_model_id_created_at_idx ON "merge_request" (model_id, created_at DESC);
+
+-- a workspace has at most one open merge request
+CREATE UNIQUE INDEX merge_request_open_workspace_idx ON "merge_request" (workspace_id)
+WHERE
+  status = 'open';
+
+CREATE TABLE "merge_request_comment" (
+  id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+  merge_request_id UUID NOT NULL REFERENCES "merge_request" (id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX merge_request_comment_merge_request_id_idx ON "merge_request_comment" (merge_request_id, created_at);
+
+-- approvals of the current diff, dropped when the merge request is refreshed
+CREATE TABLE "merge_request_approval" (
+  PRIMARY KEY (merge_request_id, user_id),
+  merge_request_id UUID NOT NULL REFERENCES "merge_request" (id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,28 @@
+-- name: CreateCandidateLayout :exec
+INSERT INTO
+  "candidate_layout" (
+    model_id,
+    user_id,
+    optimization_job_id,
+    name,
+    cameras,
+    fixed_camera_ids
+  )
+SELECT
+  j.model_id,
+  j.user_id,
+  j.id,
+  SQLC.ARG(name)::TEXT,
+  SQLC.ARG(cameras)::JSONB,
+  ARRAY(
+    SELECT
+      fixed ->> 'id'
+    FROM
+      JSONB_ARRAY_ELEMENTS(COALESCE(j.request -> 'fixedCameras', '[]'::JSONB)) AS fixed
+  )
+FROM
+  "optimization_job" AS j
+WHERE
+  j.id = SQLC.ARG(optimization_job_id)::UUID
+  AND j.status = 'pending'
+ON CONFLICT (optimization_job_id) DO NOTHING;
@@ -0,0 +1,24 @@
+-- name: GetCandidateLayoutsByModel :many
+SELECT
+  c.id,
+  c.model_id,
+  c.user_id,
+  u.username,
+  c.optimization_job_id,
+  c.name,
+  c.fixed_camera_ids,
+  c.created_at,
+  c.updated_at
+FROM
+  "candidate_layout" AS c
+  JOIN "model" AS m ON m.id = c.model_id
+  JOIN "user" AS u ON u.id = c.user_id
+WHERE
+  c.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID
+ORDER BY
+  c.created_at DESC
+LIMIT
+  SQLC.ARG(page_size)::INT
+OFFSET
+  SQLC.ARG(page_offset)::INT;
@@ -0,0 +1,9 @@
+-- name: CountCandidateLayoutsByModel :one
+SELECT
+  COUNT(*)::BIGINT
+FROM
+  "candidate_layout" AS c
+  JOIN "model" AS m ON m.id = c.model_id
+WHERE
+  c.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID;
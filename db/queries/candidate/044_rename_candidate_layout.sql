@@ -0,0 +1,16 @@
+-- name: RenameCandidateLayout :one
+UPDATE "candidate_layout" AS c
+SET
+  name = SQLC.ARG(name)::TEXT,
+  updated_at = NOW()
+FROM
+  "model" AS m
+WHERE
+  m.id = c.model_id
+  AND c.id = SQLC.ARG(id)::UUID
+  AND c.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID
+RETURNING
+  c.id,
+  c.name,
+  c.updated_at;
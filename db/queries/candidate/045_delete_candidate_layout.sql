@@ -0,0 +1,9 @@
+-- name: DeleteCandidateLayout :one
+DELETE FROM "candidate_layout" AS c USING "model" AS m
+WHERE
+  m.id = c.model_id
+  AND c.id = SQLC.ARG(id)::UUID
+  AND c.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID
+RETURNING
+  c.id;
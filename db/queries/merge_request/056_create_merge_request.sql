@@ -0,0 +1,24 @@
+-- name: CreateMergeRequest :one
+INSERT INTO
+  "merge_request" (
+    model_id,
+    workspace_id,
+    user_id,
+    description,
+    diff,
+    workspace_version
+  )
+VALUES
+  (
+    SQLC.ARG(model_id)::UUID,
+    SQLC.ARG(workspace_id)::UUID,
+    SQLC.ARG(user_id)::UUID,
+    SQLC.ARG(description)::TEXT,
+    SQLC.ARG(diff)::JSONB,
+    SQLC.ARG(workspace_version)::INT
+  )
+RETURNING
+  id,
+  status,
+  created_at,
+  updated_at;
@@ -0,0 +1,24 @@
+-- name: GetMergeRequest :one
+SELECT
+  mr.id,
+  mr.model_id,
+  mr.workspace_id,
+  mr.user_id,
+  u.username,
+  mr.description,
+  mr.diff,
+  mr.workspace_version,
+  mr.status,
+  mr.merged_by,
+  mu.username AS merged_by_username,
+  mr.revision,
+  mr.created_at,
+  mr.updated_at,
+  mr.closed_at
+FROM
+  "merge_request" AS mr
+  JOIN "user" AS u ON u.id = mr.user_id
+  LEFT JOIN "user" AS mu ON mu.id = mr.merged_by
+WHERE
+  mr.id = SQLC.ARG(id)::UUID
+  AND mr.model_id = SQLC.ARG(model_id)::UUID;
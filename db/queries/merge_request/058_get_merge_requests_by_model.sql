@@ -0,0 +1,36 @@
+-- name: GetMergeRequestsByModel :many
+SELECT
+  mr.id,
+  mr.workspace_id,
+  mr.user_id,
+  u.username,
+  mr.description,
+  mr.workspace_version,
+  mr.status,
+  mr.merged_by,
+  mu.username AS merged_by_username,
+  mr.revision,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      "merge_request_approval" AS a
+    WHERE
+      a.merge_request_id = mr.id
+  )::BIGINT AS approval_count,
+  mr.created_at,
+  mr.updated_at,
+  mr.closed_at
+FROM
+  "merge_request" AS mr
+  JOIN "user" AS u ON u.id = mr.user_id
+  LEFT JOIN "user" AS mu ON mu.id = mr.merged_by
+WHERE
+  mr.model_id = SQLC.ARG(model_id)::UUID
+  AND COALESCE(mr.status = SQLC.NARG(status)::merge_request_status, TRUE)
+ORDER BY
+  mr.created_at DESC
+LIMIT
+  SQLC.ARG(page_size)::INT
+OFFSET
+  SQLC.ARG(page_offset)::INT;
@@ -0,0 +1,8 @@
+-- name: CountMergeRequestsByModel :one
+SELECT
+  COUNT(*)::BIGINT
+FROM
+  "merge_request"
+WHERE
+  model_id = SQLC.ARG(model_id)::UUID
+  AND COALESCE(status = SQLC.NARG(status)::merge_request_status, TRUE);
@@ -0,0 +1,13 @@
+-- name: RefreshMergeRequest :one
+UPDATE "merge_request"
+SET
+  description = COALESCE(SQLC.NARG(description)::TEXT, description),
+  diff = SQLC.ARG(diff)::JSONB,
+  workspace_version = SQLC.ARG(workspace_version)::INT,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(id)::UUID
+  AND status = 'open'
+RETURNING
+  id,
+  updated_at;
@@ -0,0 +1,14 @@
+-- name: FinishMergeRequest :one
+UPDATE "merge_request"
+SET
+  status = SQLC.ARG(status)::merge_request_status,
+  merged_by = SQLC.NARG(merged_by)::UUID,
+  revision = SQLC.NARG(revision)::INT,
+  updated_at = NOW(),
+  closed_at = NOW()
+WHERE
+  id = SQLC.ARG(id)::UUID
+  AND status = 'open'
+RETURNING
+  id,
+  status;
@@ -0,0 +1,12 @@
+-- name: CreateMergeRequestComment :one
+INSERT INTO
+  "merge_request_comment" (merge_request_id, user_id, body)
+VALUES
+  (
+    SQLC.ARG(merge_request_id)::UUID,
+    SQLC.ARG(user_id)::UUID,
+    SQLC.ARG(body)::TEXT
+  )
+RETURNING
+  id,
+  created_at;
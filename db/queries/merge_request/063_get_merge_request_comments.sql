@@ -0,0 +1,14 @@
+-- name: GetMergeRequestComments :many
+SELECT
+  c.id,
+  c.user_id,
+  u.username,
+  c.body,
+  c.created_at
+FROM
+  "merge_request_comment" AS c
+  JOIN "user" AS u ON u.id = c.user_id
+WHERE
+  c.merge_request_id = SQLC.ARG(merge_request_id)::UUID
+ORDER BY
+  c.created_at;
@@ -0,0 +1,9 @@
+-- name: ApproveMergeRequest :exec
+INSERT INTO
+  "merge_request_approval" (merge_request_id, user_id)
+VALUES
+  (
+    SQLC.ARG(merge_request_id)::UUID,
+    SQLC.ARG(user_id)::UUID
+  )
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,12 @@
+-- name: GetMergeRequestApprovals :many
+SELECT
+  a.user_id,
+  u.username,
+  a.created_at
+FROM
+  "merge_request_approval" AS a
+  JOIN "user" AS u ON u.id = a.user_id
+WHERE
+  a.merge_request_id = SQLC.ARG(merge_request_id)::UUID
+ORDER BY
+  a.created_at;
@@ -0,0 +1,5 @@
+-- name: DeleteMergeRequestApproval :exec
+DELETE FROM "merge_request_approval"
+WHERE
+  merge_request_id = SQLC.ARG(merge_request_id)::UUID
+  AND user_id = SQLC.ARG(user_id)::UUID;
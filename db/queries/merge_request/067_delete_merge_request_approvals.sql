@@ -0,0 +1,4 @@
+-- name: DeleteMergeRequestApprovals :exec
+DELETE FROM "merge_request_approval"
+WHERE
+  merge_request_id = SQLC.ARG(merge_request_id)::UUID;
@@ -19,6 +19,7 @@ SELECT
     ELSE NULL::JSONB
   END AS target_area_trapezoids,
   (umw.model_id IS NOT NULL)::BOOLEAN AS workspace_exists,
+  m.protected,
   m.version,
   m.created_at,
   m.updated_at
@@ -26,6 +27,7 @@ FROM
   "model" AS m
   LEFT JOIN "user_model_workspace" AS umw ON m.id = umw.model_id
   AND SQLC.NARG(user_id) = umw.user_id
+  AND umw.name = 'default'
 WHERE
   id = SQLC.ARG(id)::UUID
   AND COALESCE(umw.user_id = SQLC.NARG(user_id)::UUID, TRUE);
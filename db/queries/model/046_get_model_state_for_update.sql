@@ -0,0 +1,15 @@
+-- name: GetModelStateForUpdate :one
+-- locks the model until the end of the transaction, so that the changes saved to it can be recorded
+-- and merges are computed against the main they are saved to
+SELECT
+  version,
+  cameras,
+  target_area_trapezoids,
+  scale_factor,
+  model_height,
+  protected
+FROM
+  "model"
+WHERE
+  id = SQLC.ARG(id)::UUID
+FOR UPDATE;
@@ -0,0 +1,12 @@
+-- name: CreateModelEvent :exec
+INSERT INTO
+  "model_event" (model_id, version, user_id, kind, target_id, value)
+VALUES
+  (
+    SQLC.ARG(model_id)::UUID,
+    SQLC.ARG(version)::INT,
+    SQLC.ARG(user_id)::UUID,
+    SQLC.ARG(kind)::TEXT,
+    SQLC.ARG(target_id)::TEXT,
+    SQLC.NARG(value)::JSONB
+  );
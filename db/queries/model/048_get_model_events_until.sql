@@ -0,0 +1,22 @@
+-- name: GetModelEventsUntil :many
+-- events to replay for the state of the model at a time, at a version, or both
+SELECT
+  id,
+  version,
+  kind,
+  target_id,
+  value
+FROM
+  "model_event"
+WHERE
+  model_id = SQLC.ARG(model_id)::UUID
+  AND (
+    SQLC.NARG(at)::TIMESTAMPTZ IS NULL
+    OR created_at <= SQLC.NARG(at)::TIMESTAMPTZ
+  )
+  AND (
+    SQLC.NARG(version)::INT IS NULL
+    OR version <= SQLC.NARG(version)::INT
+  )
+ORDER BY
+  id;
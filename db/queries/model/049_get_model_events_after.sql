@@ -0,0 +1,20 @@
+-- name: GetModelEventsAfter :many
+SELECT
+  e.id,
+  e.version,
+  e.user_id,
+  u.username,
+  e.kind,
+  e.target_id,
+  e.value,
+  e.created_at
+FROM
+  "model_event" AS e
+  LEFT JOIN "user" AS u ON u.id = e.user_id
+WHERE
+  e.model_id = SQLC.ARG(model_id)::UUID
+  AND e.id > SQLC.ARG(after_id)::BIGINT
+ORDER BY
+  e.id
+LIMIT
+  SQLC.ARG(page_size)::INT;
@@ -0,0 +1,15 @@
+-- name: UpdateModelLayout :one
+-- replaces the cameras, the faces or both in one version, NULL keeps the current document
+UPDATE "model"
+SET
+  cameras = COALESCE(SQLC.NARG(cameras)::JSONB, cameras),
+  target_area_trapezoids = COALESCE(
+    SQLC.NARG(target_area_trapezoids)::JSONB,
+    target_area_trapezoids
+  ),
+  version = version + 1,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(model_id)::UUID
+RETURNING
+  version;
@@ -0,0 +1,37 @@
+-- name: CreateModelRevision :one
+-- snapshots the model as it is in the transaction, the model row must be locked
+INSERT INTO
+  "model_revision" (
+    model_id,
+    revision,
+    version,
+    user_id,
+    message,
+    cameras,
+    target_area_trapezoids,
+    scale_factor,
+    model_height
+  )
+SELECT
+  m.id,
+  (
+    SELECT
+      COALESCE(MAX(r.revision), 0) + 1
+    FROM
+      "model_revision" AS r
+    WHERE
+      r.model_id = m.id
+  ),
+  m.version,
+  SQLC.ARG(user_id)::UUID,
+  SQLC.ARG(message)::TEXT,
+  m.cameras,
+  m.target_area_trapezoids,
+  m.scale_factor,
+  m.model_height
+FROM
+  "model" AS m
+WHERE
+  m.id = SQLC.ARG(model_id)::UUID
+RETURNING
+  revision;
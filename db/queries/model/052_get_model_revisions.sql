@@ -0,0 +1,19 @@
+-- name: GetModelRevisions :many
+SELECT
+  r.revision,
+  r.version,
+  r.user_id,
+  u.username,
+  r.message,
+  r.created_at
+FROM
+  "model_revision" AS r
+  LEFT JOIN "user" AS u ON u.id = r.user_id
+WHERE
+  r.model_id = SQLC.ARG(model_id)::UUID
+ORDER BY
+  r.revision DESC
+LIMIT
+  SQLC.ARG(page_size)::INT
+OFFSET
+  SQLC.ARG(page_offset)::INT;
@@ -0,0 +1,7 @@
+-- name: CountModelRevisions :one
+SELECT
+  COUNT(*)::BIGINT
+FROM
+  "model_revision"
+WHERE
+  model_id = SQLC.ARG(model_id)::UUID;
@@ -0,0 +1,13 @@
+-- name: GetModelRevision :one
+SELECT
+  revision,
+  version,
+  cameras,
+  target_area_trapezoids,
+  scale_factor,
+  model_height
+FROM
+  "model_revision"
+WHERE
+  model_id = SQLC.ARG(model_id)::UUID
+  AND revision = SQLC.ARG(revision)::INT;
@@ -0,0 +1,10 @@
+-- name: SetModelProtection :one
+UPDATE "model"
+SET
+  protected = SQLC.ARG(protected)::BOOLEAN,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(model_id)::UUID
+  AND project_id = SQLC.ARG(project_id)::UUID
+RETURNING
+  protected;
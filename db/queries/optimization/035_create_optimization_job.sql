@@ -0,0 +1,14 @@
+-- name: CreateOptimizationJob :one
+INSERT INTO
+  "optimization_job" (id, model_id, user_id, request)
+VALUES
+  (
+    SQLC.ARG(id)::UUID,
+    SQLC.ARG(model_id)::UUID,
+    SQLC.ARG(user_id)::UUID,
+    SQLC.ARG(request)::JSONB
+  )
+RETURNING
+  id,
+  status,
+  created_at;
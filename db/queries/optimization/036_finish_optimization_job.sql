@@ -0,0 +1,16 @@
+-- name: FinishOptimizationJob :one
+UPDATE "optimization_job"
+SET
+  status = SQLC.ARG(status)::optimization_job_status,
+  result = SQLC.NARG(result)::JSONB,
+  error = SQLC.NARG(error)::TEXT,
+  updated_at = NOW(),
+  finished_at = NOW()
+WHERE
+  id = SQLC.ARG(id)::UUID
+  AND status = 'pending'
+RETURNING
+  id,
+  model_id,
+  user_id,
+  status;
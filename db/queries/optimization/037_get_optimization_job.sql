@@ -0,0 +1,21 @@
+-- name: GetOptimizationJob :one
+SELECT
+  j.id,
+  j.model_id,
+  j.user_id,
+  u.username,
+  j.status,
+  j.request,
+  j.result,
+  j.error,
+  j.created_at,
+  j.updated_at,
+  j.finished_at
+FROM
+  "optimization_job" AS j
+  JOIN "model" AS m ON m.id = j.model_id
+  JOIN "user" AS u ON u.id = j.user_id
+WHERE
+  j.id = SQLC.ARG(id)::UUID
+  AND j.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID;
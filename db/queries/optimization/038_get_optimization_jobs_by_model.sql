@@ -0,0 +1,24 @@
+-- name: GetOptimizationJobsByModel :many
+SELECT
+  j.id,
+  j.model_id,
+  j.user_id,
+  u.username,
+  j.status,
+  j.error,
+  j.created_at,
+  j.updated_at,
+  j.finished_at
+FROM
+  "optimization_job" AS j
+  JOIN "model" AS m ON m.id = j.model_id
+  JOIN "user" AS u ON u.id = j.user_id
+WHERE
+  j.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID
+ORDER BY
+  j.created_at DESC
+LIMIT
+  SQLC.ARG(page_size)::INT
+OFFSET
+  SQLC.ARG(page_offset)::INT;
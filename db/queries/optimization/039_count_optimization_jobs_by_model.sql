@@ -0,0 +1,9 @@
+-- name: CountOptimizationJobsByModel :one
+SELECT
+  COUNT(*)::BIGINT
+FROM
+  "optimization_job" AS j
+  JOIN "model" AS m ON m.id = j.model_id
+WHERE
+  j.model_id = SQLC.ARG(model_id)::UUID
+  AND m.project_id = SQLC.ARG(project_id)::UUID;
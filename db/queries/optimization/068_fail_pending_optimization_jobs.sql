@@ -0,0 +1,10 @@
+-- name: FailPendingOptimizationJobs :execrows
+-- jobs left pending by a stopped backend, their waiters are gone
+UPDATE "optimization_job"
+SET
+  status = 'failed',
+  error = SQLC.ARG(message)::TEXT,
+  updated_at = NOW(),
+  finished_at = NOW()
+WHERE
+  status = 'pending';
@@ -3,8 +3,11 @@ INSERT INTO
   "user_model_workspace" (
     user_id,
     model_id,
+    name,
     cameras,
     base_cameras,
+    target_area_trapezoids,
+    base_target_area_trapezoids,
     scale_factor,
     model_height,
     version,
@@ -15,8 +18,11 @@ INSERT INTO
 SELECT
   SQLC.ARG(user_id)::UUID,
   SQLC.ARG(model_id)::UUID,
+  SQLC.ARG(name)::TEXT,
   cameras,
   cameras,
+  target_area_trapezoids,
+  target_area_trapezoids,
   1.0,
   0.0,
   version,
@@ -28,6 +34,8 @@ FROM
 WHERE
   id = SQLC.ARG(model_id)::UUID
 RETURNING
+  id,
+  name,
   user_id,
   model_id,
   cameras,
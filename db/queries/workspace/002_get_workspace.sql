@@ -1,6 +1,8 @@
 -- name: GetWorkspaceByID :one
 SELECT
   SQLC.EMBED(m),
+  umw.id,
+  umw.name,
   CASE
     WHEN 'cameras' = ANY (COALESCE(SQLC.NARG(fields)::TEXT[], '{}'::TEXT[])) THEN umw.cameras::JSONB
     ELSE NULL::JSONB
@@ -13,6 +15,10 @@ SELECT
     WHEN 'target_area_trapezoids' = ANY (COALESCE(SQLC.NARG(fields)::TEXT[], '{}'::TEXT[])) THEN umw.target_area_trapezoids::JSONB
     ELSE NULL::JSONB
   END AS target_area_trapezoids,
+  CASE
+    WHEN 'base_target_area_trapezoids' = ANY (COALESCE(SQLC.NARG(fields)::TEXT[], '{}'::TEXT[])) THEN base_target_area_trapezoids::JSONB
+    ELSE NULL::JSONB
+  END AS base_target_area_trapezoids,
   umw.scale_factor,
   umw.model_height,
   umw.version,
@@ -24,4 +30,6 @@ FROM
   LEFT JOIN "model" AS m ON m.id = umw.model_id
 WHERE
   user_id = SQLC.ARG(user_id)::UUID
-  AND model_id = SQLC.ARG(model_id)::UUID;
+  AND model_id = SQLC.ARG(model_id)::UUID
+  -- the default workspace without a workspace id
+  AND COALESCE(umw.id = SQLC.NARG(workspace_id)::UUID, umw.name = 'default');
@@ -13,7 +13,6 @@ SET
   version = version + 1,
   updated_at = NOW()
 WHERE
-  user_id = SQLC.ARG(user_id)::UUID
-  AND model_id = SQLC.ARG(model_id)::UUID
+  id = SQLC.ARG(workspace_id)::UUID
 RETURNING
   version;
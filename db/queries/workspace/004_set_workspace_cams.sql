@@ -7,5 +7,4 @@ SET
   base_version = SQLC.ARG(base_version)::INT,
   updated_at = NOW()
 WHERE
-  model_id = SQLC.ARG(model_id)::UUID
-  AND user_id = SQLC.ARG(user_id)::UUID;
+  id = SQLC.ARG(workspace_id)::UUID;
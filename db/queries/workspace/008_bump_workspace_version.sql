@@ -0,0 +1,10 @@
+-- name: BumpWorkspaceVersion :one
+UPDATE "user_model_workspace"
+SET
+  version = version + 1,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID
+  AND version = SQLC.ARG(version)::INT
+RETURNING
+  version;
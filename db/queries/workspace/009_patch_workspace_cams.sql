@@ -0,0 +1,15 @@
+-- name: PatchWorkspaceCams :exec
+-- same as UpdateWorkspaceCams, the version is bumped once per batch by BumpWorkspaceVersion
+UPDATE "user_model_workspace"
+SET
+  cameras = CASE
+    WHEN SQLC.NARG(value)::JSONB IS NULL THEN cameras - SQLC.ARG(key)::TEXT[] -- delete key if value is NULL
+    ELSE JSONB_SET(
+      cameras,
+      SQLC.ARG(key)::TEXT[],
+      SQLC.NARG(value)::JSONB,
+      TRUE
+    ) -- upsert key
+  END
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID;
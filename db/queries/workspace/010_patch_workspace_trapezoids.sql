@@ -0,0 +1,15 @@
+-- name: PatchWorkspaceTargetTrapezoids :exec
+-- same as UpdateWorkspaceTargetTrapezoids, the version is bumped once per batch by BumpWorkspaceVersion
+UPDATE "user_model_workspace"
+SET
+  target_area_trapezoids = CASE
+    WHEN SQLC.NARG(value)::JSONB IS NULL THEN target_area_trapezoids - SQLC.ARG(key)::TEXT[] -- delete key if value is NULL
+    ELSE JSONB_SET(
+      target_area_trapezoids,
+      SQLC.ARG(key)::TEXT[],
+      SQLC.NARG(value)::JSONB,
+      TRUE
+    ) -- upsert key
+  END
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID;
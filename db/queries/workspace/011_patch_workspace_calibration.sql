@@ -0,0 +1,8 @@
+-- name: PatchWorkspaceCalibration :exec
+-- same as UpdateWorkspaceCalibration, the version is bumped once per batch by BumpWorkspaceVersion
+UPDATE "user_model_workspace"
+SET
+  scale_factor = SQLC.ARG(scale_factor)::FLOAT,
+  model_height = SQLC.ARG(model_height)::FLOAT
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID;
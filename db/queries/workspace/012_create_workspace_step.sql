@@ -0,0 +1,19 @@
+-- name: CreateWorkspaceStep :exec
+INSERT INTO
+  "workspace_step" (
+    workspace_id,
+    version,
+    action,
+    target_version,
+    undo_stack,
+    redo_stack
+  )
+VALUES
+  (
+    SQLC.ARG(workspace_id)::UUID,
+    SQLC.ARG(version)::INT,
+    SQLC.ARG(action)::TEXT,
+    SQLC.NARG(target_version)::INT,
+    SQLC.ARG(undo_stack)::INT[],
+    SQLC.ARG(redo_stack)::INT[]
+  );
@@ -0,0 +1,21 @@
+-- name: CreateWorkspaceEvent :exec
+INSERT INTO
+  "workspace_event" (
+    workspace_id,
+    version,
+    seq,
+    kind,
+    target_id,
+    before_value,
+    after_value
+  )
+VALUES
+  (
+    SQLC.ARG(workspace_id)::UUID,
+    SQLC.ARG(version)::INT,
+    SQLC.ARG(seq)::INT,
+    SQLC.ARG(kind)::TEXT,
+    SQLC.ARG(target_id)::TEXT,
+    SQLC.NARG(before_value)::JSONB,
+    SQLC.NARG(after_value)::JSONB
+  );
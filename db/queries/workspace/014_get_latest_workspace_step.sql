@@ -0,0 +1,16 @@
+-- name: GetLatestWorkspaceStep :one
+SELECT
+  version,
+  action,
+  target_version,
+  undo_stack,
+  redo_stack,
+  created_at
+FROM
+  "workspace_step"
+WHERE
+  workspace_id = SQLC.ARG(workspace_id)::UUID
+ORDER BY
+  version DESC
+LIMIT
+  1;
@@ -0,0 +1,16 @@
+-- name: GetWorkspaceEventsByVersions :many
+SELECT
+  version,
+  seq,
+  kind,
+  target_id,
+  before_value,
+  after_value
+FROM
+  "workspace_event"
+WHERE
+  workspace_id = SQLC.ARG(workspace_id)::UUID
+  AND version = ANY (SQLC.ARG(versions)::INT[])
+ORDER BY
+  version,
+  seq;
@@ -0,0 +1,16 @@
+-- name: GetWorkspaceSteps :many
+SELECT
+  version,
+  action,
+  target_version,
+  created_at
+FROM
+  "workspace_step"
+WHERE
+  workspace_id = SQLC.ARG(workspace_id)::UUID
+ORDER BY
+  version DESC
+LIMIT
+  SQLC.ARG(page_size)::INT
+OFFSET
+  SQLC.ARG(page_offset)::INT;
@@ -0,0 +1,7 @@
+-- name: CountWorkspaceSteps :one
+SELECT
+  COUNT(*)::BIGINT
+FROM
+  "workspace_step"
+WHERE
+  workspace_id = SQLC.ARG(workspace_id)::UUID;
@@ -0,0 +1,13 @@
+-- name: UpdateSetWorkspaceLayout :exec
+-- same as UpdateSetWorkspaceCams, for the cameras and the faces merged with main
+UPDATE "user_model_workspace"
+SET
+  cameras = SQLC.ARG(cameras)::JSONB,
+  base_cameras = SQLC.ARG(cameras)::JSONB,
+  target_area_trapezoids = SQLC.ARG(target_area_trapezoids)::JSONB,
+  base_target_area_trapezoids = SQLC.ARG(target_area_trapezoids)::JSONB,
+  version = SQLC.ARG(base_version)::INT,
+  base_version = SQLC.ARG(base_version)::INT,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID;
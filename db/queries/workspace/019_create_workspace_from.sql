@@ -0,0 +1,46 @@
+-- name: CreateWorkspaceFrom :one
+-- branch of another workspace of the user, with the same base so that it merges the same way.
+-- The history isn't copied.
+INSERT INTO
+  "user_model_workspace" (
+    user_id,
+    model_id,
+    name,
+    cameras,
+    base_cameras,
+    target_area_trapezoids,
+    base_target_area_trapezoids,
+    scale_factor,
+    model_height,
+    version,
+    base_version,
+    created_at,
+    updated_at
+  )
+SELECT
+  user_id,
+  model_id,
+  SQLC.ARG(name)::TEXT,
+  cameras,
+  base_cameras,
+  target_area_trapezoids,
+  base_target_area_trapezoids,
+  scale_factor,
+  model_height,
+  version,
+  base_version,
+  NOW(),
+  NOW()
+FROM
+  "user_model_workspace"
+WHERE
+  id = SQLC.ARG(source_id)::UUID
+  AND user_id = SQLC.ARG(user_id)::UUID
+  AND model_id = SQLC.ARG(model_id)::UUID
+RETURNING
+  id,
+  name,
+  version,
+  base_version,
+  created_at,
+  updated_at;
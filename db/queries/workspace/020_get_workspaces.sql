@@ -0,0 +1,16 @@
+-- name: GetWorkspaces :many
+SELECT
+  id,
+  name,
+  version,
+  base_version,
+  created_at,
+  updated_at
+FROM
+  "user_model_workspace"
+WHERE
+  user_id = SQLC.ARG(user_id)::UUID
+  AND model_id = SQLC.ARG(model_id)::UUID
+ORDER BY
+  created_at,
+  name;
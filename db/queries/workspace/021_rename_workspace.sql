@@ -0,0 +1,16 @@
+-- name: RenameWorkspace :one
+UPDATE "user_model_workspace"
+SET
+  name = SQLC.ARG(name)::TEXT,
+  updated_at = NOW()
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID
+  AND user_id = SQLC.ARG(user_id)::UUID
+  AND model_id = SQLC.ARG(model_id)::UUID
+RETURNING
+  id,
+  name,
+  version,
+  base_version,
+  created_at,
+  updated_at;
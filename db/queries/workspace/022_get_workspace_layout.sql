@@ -0,0 +1,11 @@
+-- name: GetWorkspaceLayout :one
+SELECT
+  cameras,
+  target_area_trapezoids,
+  scale_factor,
+  model_height,
+  version
+FROM
+  "user_model_workspace"
+WHERE
+  id = SQLC.ARG(workspace_id)::UUID;
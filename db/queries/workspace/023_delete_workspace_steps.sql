@@ -0,0 +1,5 @@
+-- name: DeleteWorkspaceSteps :exec
+-- the events are deleted with their step
+DELETE FROM "workspace_step"
+WHERE
+  workspace_id = SQLC.ARG(workspace_id)::UUID;
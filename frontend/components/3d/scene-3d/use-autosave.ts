@@ -1,13 +1,19 @@
 import type { ICamera } from "~/types/camera";
 import type { SceneStates } from "~/types/scene-states";
 import { Quaternion } from "three";
-import type { ProcessedCoverageFace } from "../scene-states-provider/create-scene-states";
+import {
+  transformProtoEventToCamera,
+  transformProtoEventToTrapezoid,
+  type ProcessedCoverageFace,
+} from "../scene-states-provider/create-scene-states";
 import type { Camera } from "~/messages/protobufs/camera";
 import type { CoverageFace } from "~/messages/protobufs/optimization";
 import type { AutosaveEvent } from "~/messages/protobufs/workspace_autosave";
+import type { HistoryResponse } from "~/messages/protobufs/workspace_history";
 import {
   WorkspaceEventResponse,
   WorkspaceEventRequest,
+  type SessionResponse,
 } from "~/messages/protobufs/workspace_event";
 
 function isEqual<T>(a: T, b: T): boolean {
@@ -92,119 +98,228 @@ export function useAutosave(
     { deep: true },
   );
 
-  onMounted(() => {
-    watch(
-      () => sceneStates.websocket?.data.value,
-      async (messageBlob) => {
-        if (!messageBlob) return;
-        const buf = await (messageBlob as Blob).arrayBuffer();
-        const resp = WorkspaceEventResponse.decode(new Uint8Array(buf));
+  // Kept by the server, so that undo survives a reload
+  const canUndo = ref(false);
+  const canRedo = ref(false);
 
-        handleWorkspaceEvent(resp);
-      },
-    );
+  // Session of the connection, taken over by the next connection after a reconnection
+  let sessionId: string | undefined;
+  let resuming = false;
 
-    watch(
-      () => [
-        sceneStates.calibration.scale,
-        sceneStates.calibration.heightOffset,
-      ],
-      ([newScale, newHeight], [oldScale, oldHeight]) => {
-        if (isServerUpdate.value) return;
-        if (newScale !== oldScale || newHeight !== oldHeight) {
-          sceneStates.calibration.dirty = true;
+  function handleSession(session: SessionResponse) {
+    if (sessionId && !resuming && session.sessionId !== sessionId) {
+      resuming = true;
+      const encoded = WorkspaceEventRequest.encode({
+        resume: {
+          sessionId,
+          lastAckedVersion: sceneStates.lastSyncedVersion.value,
+        },
+      }).finish();
+      sceneStates.websocket?.send(encoded.buffer);
+      return;
+    }
+    if (resuming && !session.resumed) {
+      console.warn(
+        "workspace session expired, changes made elsewhere are not shown",
+      );
+    }
+    resuming = false;
+    sessionId = session.sessionId;
+    sceneStates.lastSyncedVersion.value = session.lastUpdatedVersion;
+    canUndo.value = session.canUndo;
+    canRedo.value = session.canRedo;
+  }
+
+  // Applies the changes of an undo or a redo, they are already saved
+  function applyHistory(history: HistoryResponse) {
+    isServerUpdate.value = true;
+    for (const event of history.events) {
+      if (event.upsert?.camera) {
+        const id = event.upsert.camera.id;
+        const cam = transformProtoEventToCamera(event.upsert.camera);
+        sceneStates.cameras[id] = cam;
+        lastSyncedCams.set(id, transformCameraToProtoEventWithId(id, cam));
+      } else if (event.delete) {
+        const id = event.delete.id;
+        if (sceneStates.currentCamId.value == id) {
+          sceneStates.currentCamId.value = null;
         }
-      },
+        delete sceneStates.cameras[id];
+        lastSyncedCams.delete(id);
+      } else if (event.faceUpsert?.coverageFace) {
+        const id = event.faceUpsert.coverageFace.id;
+        const face = transformProtoEventToTrapezoid(
+          event.faceUpsert.coverageFace,
+        );
+        sceneStates.facesManagement.faces[id] = face;
+        lastSyncedFaces.set(id, transformFaceToProto(id, face));
+      } else if (event.faceDelete) {
+        delete sceneStates.facesManagement.faces[event.faceDelete.id];
+        lastSyncedFaces.delete(event.faceDelete.id);
+      } else if (event.calibrate) {
+        sceneStates.calibration.scale = event.calibrate.scaleFactor;
+        sceneStates.calibration.heightOffset = event.calibrate.modelHeight;
+      }
+    }
+    nextTick(() => {
+      isServerUpdate.value = false;
+    });
+
+    sceneStates.lastSyncedVersion.value = history.lastUpdatedVersion;
+    sceneStates.localVersion.value = Math.max(
+      sceneStates.localVersion.value,
+      history.lastUpdatedVersion,
     );
+    canUndo.value = history.canUndo;
+    canRedo.value = history.canRedo;
+  }
 
-    function updateCams(changed: AutosaveEvent[]) {
-      if (!sceneStates.markedForCheck.value) {
-        return;
-      }
+  function updateCams(changed: AutosaveEvent[]) {
+    if (!sceneStates.markedForCheck.value) {
+      return;
+    }
 
-      // Cameras
-      if (sceneStates.markedForCheck.value) {
-        for (const [camId, cam] of Object.entries(sceneStates.cameras)) {
-          const prev = lastSyncedCams.get(camId);
-          const formattedCam = transformCameraToProtoEventWithId(camId, cam);
+    // Cameras
+    if (sceneStates.markedForCheck.value) {
+      for (const [camId, cam] of Object.entries(sceneStates.cameras)) {
+        const prev = lastSyncedCams.get(camId);
+        const formattedCam = transformCameraToProtoEventWithId(camId, cam);
 
-          if (prev == undefined || !isEqual(prev, formattedCam)) {
-            changed.push({ upsert: { camera: formattedCam } });
-            lastSyncedCams.set(camId, formattedCam);
-          }
+        if (prev == undefined || !isEqual(prev, formattedCam)) {
+          changed.push({ upsert: { camera: formattedCam } });
+          lastSyncedCams.set(camId, formattedCam);
         }
+      }
 
-        // Check for deleted cameras
-        for (const camId of lastSyncedCams.keys()) {
-          if (!sceneStates.cameras[camId]) {
-            lastSyncedCams.delete(camId);
-            changed.push({ delete: { id: camId } });
-          }
+      // Check for deleted cameras
+      for (const camId of lastSyncedCams.keys()) {
+        if (!sceneStates.cameras[camId]) {
+          lastSyncedCams.delete(camId);
+          changed.push({ delete: { id: camId } });
         }
       }
-
-      sceneStates.markedForCheck.value = false;
     }
 
-    function updateCalibration(changed: AutosaveEvent[]) {
-      if (sceneStates.calibration.dirty) {
-        changed.push({
-          calibrate: {
-            scaleFactor: sceneStates.calibration.scale,
-            modelHeight: sceneStates.calibration.heightOffset,
-          },
-        });
-        sceneStates.calibration.dirty = false;
-      }
+    sceneStates.markedForCheck.value = false;
+  }
+
+  function updateCalibration(changed: AutosaveEvent[]) {
+    if (sceneStates.calibration.dirty) {
+      changed.push({
+        calibrate: {
+          scaleFactor: sceneStates.calibration.scale,
+          modelHeight: sceneStates.calibration.heightOffset,
+        },
+      });
+      sceneStates.calibration.dirty = false;
     }
+  }
 
-    function updateFaces(changed: AutosaveEvent[]) {
-      for (const faceId in sceneStates.facesManagement.faces) {
-        const prev = lastSyncedFaces.get(faceId);
-        const face = sceneStates.facesManagement.faces[faceId]!;
+  function updateFaces(changed: AutosaveEvent[]) {
+    for (const faceId in sceneStates.facesManagement.faces) {
+      const prev = lastSyncedFaces.get(faceId);
+      const face = sceneStates.facesManagement.faces[faceId]!;
 
-        // Handle Upsert (New or Changed)
-        const formattedFace = transformFaceToProto(faceId, face);
+      // Handle Upsert (New or Changed)
+      const formattedFace = transformFaceToProto(faceId, face);
 
-        if (prev === undefined || !isEqual(prev, formattedFace)) {
-          changed.push({ faceUpsert: { coverageFace: formattedFace } });
-          lastSyncedFaces.set(faceId, formattedFace);
-        }
+      if (prev === undefined || !isEqual(prev, formattedFace)) {
+        changed.push({ faceUpsert: { coverageFace: formattedFace } });
+        lastSyncedFaces.set(faceId, formattedFace);
       }
+    }
 
-      for (const faceId of lastSyncedFaces.keys()) {
-        const face = sceneStates.facesManagement.faces[faceId];
-        // Handle Deletion
-        if (face === undefined) {
-          lastSyncedFaces.delete(faceId);
-          changed.push({ faceDelete: { id: faceId } });
-        }
+    for (const faceId of lastSyncedFaces.keys()) {
+      const face = sceneStates.facesManagement.faces[faceId];
+      // Handle Deletion
+      if (face === undefined) {
+        lastSyncedFaces.delete(faceId);
+        changed.push({ faceDelete: { id: faceId } });
       }
+    }
+
+    sceneStates.markedFacesForCheck.value = false;
+  }
+
+  // Sends the changes made since the last batch
+  function flush() {
+    if (!sceneStates.websocket) return;
+
+    const changed: AutosaveEvent[] = [];
 
-      sceneStates.markedFacesForCheck.value = false;
+    updateCams(changed);
+
+    updateCalibration(changed);
+
+    updateFaces(changed);
+
+    if (changed.length > 0) {
+      sceneStates.localVersion.value += 1;
+      const encoded = WorkspaceEventRequest.encode({
+        autosave: {
+          version: sceneStates.localVersion.value,
+          events: changed,
+        },
+      }).finish();
+      sceneStates.websocket.send(encoded.buffer);
     }
+  }
 
-    setInterval(() => {
-      if (!sceneStates.websocket) return;
+  // Pending changes are sent first, so that they are what gets undone
+  function undo() {
+    if (!sceneStates.websocket) return;
+    flush();
+    const encoded = WorkspaceEventRequest.encode({ undo: {} }).finish();
+    sceneStates.websocket.send(encoded.buffer);
+  }
 
-      const changed: AutosaveEvent[] = [];
+  function redo() {
+    if (!sceneStates.websocket) return;
+    flush();
+    const encoded = WorkspaceEventRequest.encode({ redo: {} }).finish();
+    sceneStates.websocket.send(encoded.buffer);
+  }
 
-      updateCams(changed);
+  onMounted(() => {
+    watch(
+      () => sceneStates.websocket?.data.value,
+      async (messageBlob) => {
+        if (!messageBlob) return;
+        const buf = await (messageBlob as Blob).arrayBuffer();
+        const resp = WorkspaceEventResponse.decode(new Uint8Array(buf));
 
-      updateCalibration(changed);
+        if (resp.session) {
+          handleSession(resp.session);
+          return;
+        }
+        if (resp.history) {
+          applyHistory(resp.history);
+          return;
+        }
+        // The ACKs sent on connect and on resume don't carry the history
+        if (resp.autosave?.requestVersion) {
+          canUndo.value = resp.autosave.canUndo;
+          canRedo.value = resp.autosave.canRedo;
+        }
+        handleWorkspaceEvent(resp);
+      },
+    );
 
-      updateFaces(changed);
+    watch(
+      () => [
+        sceneStates.calibration.scale,
+        sceneStates.calibration.heightOffset,
+      ],
+      ([newScale, newHeight], [oldScale, oldHeight]) => {
+        if (isServerUpdate.value) return;
+        if (newScale !== oldScale || newHeight !== oldHeight) {
+          sceneStates.calibration.dirty = true;
+        }
+      },
+    );
 
-      if (changed.length > 0) {
-        sceneStates.localVersion.value += 1;
-        const encoded = WorkspaceEventRequest.encode({
-          autosave: {
-            version: sceneStates.localVersion.value,
-            events: changed,
-          },
-        }).finish();
-        sceneStates.websocket.send(encoded.buffer);
-      }
-    }, 2000);
+    setInterval(flush, 2000);
   });
+
+  return { canUndo, canRedo, undo, redo };
 }
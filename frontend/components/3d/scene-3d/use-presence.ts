@@ -0,0 +1,93 @@
+import type { SceneStates } from "~/types/scene-states";
+import {
+  PresenceEventType,
+  type PresenceMember,
+  type PresenceResponse,
+  type PresenceState,
+} from "~/messages/protobufs/presence";
+import { WorkspaceEventRequest } from "~/messages/protobufs/workspace_event";
+
+// Minimum delay between two presence updates sent to the server
+const PRESENCE_SEND_INTERVAL = 100;
+
+export function usePresence(
+  sceneStates: SceneStates,
+  workspace: string | null,
+) {
+  // Other users on the model, by member id
+  const members: Record<string, PresenceMember> = reactive({});
+  const memberId: Ref<string | null> = ref(null);
+  let dirty = false;
+
+  function handlePresence(resp: PresenceResponse) {
+    if (resp.snapshot) {
+      memberId.value = resp.snapshot.memberId;
+      for (const id of Object.keys(members)) {
+        delete members[id];
+      }
+      for (const member of resp.snapshot.members) {
+        members[member.memberId] = member;
+      }
+      // A new connection starts without a state
+      dirty = true;
+      return;
+    }
+
+    const event = resp.event;
+    if (!event?.member) return;
+    switch (event.type) {
+      case PresenceEventType.PRESENCE_EVENT_TYPE_JOIN:
+      case PresenceEventType.PRESENCE_EVENT_TYPE_UPDATE:
+        members[event.member.memberId] = event.member;
+        break;
+      case PresenceEventType.PRESENCE_EVENT_TYPE_LEAVE:
+        delete members[event.member.memberId];
+        break;
+    }
+  }
+
+  function currentState(): PresenceState {
+    const position = sceneStates.spectatorCameraPosition;
+    const rotation = sceneStates.spectatorCameraRotation;
+    return {
+      spectator: {
+        position: { x: position.x, y: position.y, z: position.z },
+        rotation: { x: rotation.x, y: rotation.y, z: rotation.z },
+        fov: sceneStates.spectatorCameraFov.value,
+      },
+      selectedCameraId: sceneStates.currentCamId.value ?? "",
+    };
+  }
+
+  if (workspace === "me") {
+    onMounted(() => {
+      watch(
+        () => [
+          sceneStates.spectatorCameraPosition,
+          sceneStates.spectatorCameraRotation,
+          sceneStates.spectatorCameraFov.value,
+          sceneStates.currentCamId.value,
+        ],
+        () => {
+          dirty = true;
+        },
+        { deep: true },
+      );
+
+      const interval = setInterval(() => {
+        if (!dirty || !sceneStates.websocket || memberId.value == null) return;
+        dirty = false;
+        const encoded = WorkspaceEventRequest.encode({
+          presence: { state: currentState() },
+        }).finish();
+        sceneStates.websocket.send(encoded.buffer);
+      }, PRESENCE_SEND_INTERVAL);
+
+      onUnmounted(() => {
+        clearInterval(interval);
+      });
+    });
+  }
+
+  return { members, memberId, handlePresence };
+}
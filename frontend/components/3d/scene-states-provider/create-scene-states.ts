@@ -22,7 +22,12 @@ import type { Camera } from "~/messages/protobufs/camera";
 import type { CoverageFace } from "~/messages/protobufs/optimization";
 import type { ProtoVector3 } from "~/messages/protobufs/vector";
 import { useAutosave } from "~/components/3d/scene-3d/use-autosave";
-import type { WorkspaceEventResponse } from "~/messages/protobufs/workspace_event";
+import { usePresence } from "~/components/3d/scene-3d/use-presence";
+import type { MainUpdatedResponse } from "~/messages/protobufs/main_update";
+import {
+  WorkspaceRequestType,
+  type WorkspaceEventResponse,
+} from "~/messages/protobufs/workspace_event";
 
 export interface ProcessedCoverageFace {
   name: string;
@@ -107,7 +112,7 @@ function protoVecToNumbers(vec: ProtoVector3): [number, number, number] {
   return [vec.x, vec.y, vec.z];
 }
 
-function transformProtoEventToTrapezoid(
+export function transformProtoEventToTrapezoid(
   rawTrapezoid: CoverageFace,
 ): ProcessedCoverageFace {
   return {
@@ -400,7 +405,39 @@ export function createSceneStatesWithHelper(
 
   const optimization = useOptimize(sceneStates, workspace);
 
+  const presence = usePresence(sceneStates, workspace);
+
+  // Latest save to main the workspace doesn't include yet, until it is merged
+  const mainUpdate: Ref<MainUpdatedResponse | null> = ref(null);
+
   function handle(resp: WorkspaceEventResponse) {
+    if (resp.presence) {
+      presence.handlePresence(resp.presence);
+      return;
+    }
+    if (resp.mainUpdated) {
+      mainUpdate.value = resp.mainUpdated;
+      return;
+    }
+    if (resp.error) {
+      console.error("workspace request failed", resp.error);
+      switch (resp.error.request) {
+        case WorkspaceRequestType.WORKSPACE_REQUEST_TYPE_AUTOSAVE:
+        case WorkspaceRequestType.WORKSPACE_REQUEST_TYPE_UNDO:
+        case WorkspaceRequestType.WORKSPACE_REQUEST_TYPE_REDO:
+          // Nothing was saved, the next batch must be newer than the server version
+          sceneStates.lastSyncedVersion.value = resp.error.lastUpdatedVersion;
+          sceneStates.localVersion.value = Math.max(
+            sceneStates.localVersion.value,
+            resp.error.lastUpdatedVersion,
+          );
+          break;
+        case WorkspaceRequestType.WORKSPACE_REQUEST_TYPE_OPTIMIZE:
+          optimization!.submitStatus!.value = "idle";
+          break;
+      }
+      return;
+    }
     if (resp.autosave) {
       sceneStates.lastSyncedVersion.value = resp.autosave.lastUpdatedVersion;
     } else {
@@ -421,7 +458,7 @@ export function createSceneStatesWithHelper(
     }
   }
 
-  useAutosave(sceneStates, workspace, handle);
+  const history = useAutosave(sceneStates, workspace, handle);
 
   onMounted(() => {
     watch(
@@ -445,6 +482,9 @@ export function createSceneStatesWithHelper(
     spectatorPosition: useSpectatorPosition(sceneStates, workspace),
     spectatorRotation: useSpectatorRotation(sceneStates, workspace),
     optimization,
+    presence,
+    mainUpdate,
+    history,
   };
   return sceneStatesWithCam;
 }
@@ -180,6 +180,8 @@ class CartesianSerialize(AlgorithmSerialization):
         """Flattens State into a 1D numpy array."""
         vec = []
         for cam in state.cameras:
+            if cam.fixed:
+                continue
             vec.extend(cam.pos - cam.center_of_faces)  # Just 3 params: x, y, z
         return np.array(vec)
 
@@ -188,6 +190,10 @@ class CartesianSerialize(AlgorithmSerialization):
         new_cameras = []
         idx = 0
         for i in range(len(template_state.cameras)):
+            if template_state.cameras[i].fixed:
+                new_cameras.append(template_state.cameras[i])
+                continue
+
             rel_pos = vec[idx : idx + 3]
 
             face_center = template_state.cameras[i].center_of_faces
@@ -207,7 +213,7 @@ class CartesianSerialize(AlgorithmSerialization):
     def init_bounds(self, template: State) -> List[Tuple[float, float]]:
         # Define bounds
         bounds = []
-        for _ in range(len(template.cameras)):
+        for _ in [cam for cam in template.cameras if not cam.fixed]:
             bounds.extend([(-100 / template.scale, 100 / template.scale)] * 3)  # Pos
             # bounds.extend([(-1, 1)] * 4)  # Quaternion components
 
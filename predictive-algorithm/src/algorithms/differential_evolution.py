@@ -1,11 +1,20 @@
+import time
 from scipy.optimize import differential_evolution
 from . import CartesianSerialize
 from state import State
 from cost_functions import total_cost
 
 
-def optimize_de(initial_state: State, seed: int, verbose=False):
+def optimize_de(
+    initial_state: State,
+    seed: int,
+    verbose=False,
+    on_progress=None,
+    max_iter: int = 500,
+    time_limit: float | None = None,
+):
     template = initial_state
+    start_time = time.monotonic()
 
     num_cams = len(template.cameras)
 
@@ -31,11 +40,26 @@ def optimize_de(initial_state: State, seed: int, verbose=False):
         # render_from_state(None, state)
         return cost
 
+    iteration = 0
+
+    # Called by scipy after every generation with the best vector so far,
+    # returning True stops the optimization
+    def callback(intermediate_result):
+        nonlocal iteration
+        iteration += 1
+        if on_progress is not None:
+            on_progress(
+                iteration,
+                intermediate_result.fun,
+                cartesian.vector_to_state(intermediate_result.x, template),
+            )
+        return time_limit is not None and time.monotonic() - start_time > time_limit
+
     result = differential_evolution(
         objective,
         bounds,
         strategy="rand1bin",
-        maxiter=500,
+        maxiter=max_iter,
         init=init_pop,
         mutation=(0.2, 0.7),
         popsize=num_particles,
@@ -50,6 +74,7 @@ def optimize_de(initial_state: State, seed: int, verbose=False):
         # Note: SciPy's internal 'convergence' check varies slightly by version,
         # but setting polish=False ensures it stops strictly on these bounds.
         polish=False,
+        callback=callback,
     )
 
     print(f"Total generations used: {result.nit}")
@@ -1,67 +1,74 @@
-from typing import Union
-from pyvistaqt import BackgroundPlotter
+import time
 import numpy as np
+from . import CartesianSerialize
 from state import State
 from cost_functions import total_cost
 
 
-# def optimize_pso(
-#     initial_state: State,
-#     pl: Union[BackgroundPlotter, None],
-#     iterations=100,
-#     pop_size=30,
-# ):
-#     viz: Union[InteractiveOptimizerPlotter, None] = None
-#     if pl != None:
-#         viz = InteractiveOptimizerPlotter(pl, initial_state)
-
-#     num_cams = len(initial_state.cameras)
-#     dim = num_cams * state_cartesian_vector_dim()
-
-#     # Initialize particles
-#     particles = np.array(
-#         [state_to_cartesian_vector(initial_state) for _ in range(pop_size)]
-#     )
-#     # Add some noise to initial particles to explore
-#     particles += np.random.uniform(-0.5, 0.5, particles.shape)
-
-#     velocities = np.zeros_like(particles)
-#     p_best = particles.copy()
-#     p_best_cost = np.array(
-#         [total_cost(cartesian_vector_to_state(p, initial_state))[0] for p in particles]
-#     )
-
-#     g_best = p_best[np.argmin(p_best_cost)]
-#     g_best_cost = np.min(p_best_cost)
-
-#     # Hyperparameters
-#     w, c1, c2 = 0.5, 1.5, 1.5
-
-#     for iteration in range(iterations):
-#         for i in range(pop_size):
-#             # Update velocity and position
-#             r1, r2 = np.random.rand(dim), np.random.rand(dim)
-#             velocities[i] = (
-#                 w * velocities[i]
-#                 + c1 * r1 * (p_best[i] - particles[i])
-#                 + c2 * r2 * (g_best - particles[i])
-#             )
-#             particles[i] += velocities[i]
-
-#             # Evaluate
-#             current_state = cartesian_vector_to_state(particles[i], initial_state)
-#             current_cost = total_cost(current_state)
-
-#             if current_cost < p_best_cost[i]:
-#                 p_best[i] = particles[i]
-#                 p_best_cost[i] = current_cost
-
-#                 if current_cost < g_best_cost:
-#                     g_best = particles[i]
-#                     g_best_cost = current_cost
-#         if pl != None:
-#             best_state_so_far = cartesian_vector_to_state(g_best, initial_state)
-#             print("Best state cost: ", total_cost(best_state_so_far))
-#             viz.update(best_state_so_far, iteration)
-
-#     return cartesian_vector_to_state(g_best, initial_state)
+def optimize_pso(
+    initial_state: State,
+    seed: int,
+    on_progress=None,
+    max_iter: int = 100,
+    time_limit: float | None = None,
+    pop_size=30,
+):
+    template = initial_state
+    start_time = time.monotonic()
+
+    num_cams = len(template.cameras)
+
+    cartesian = CartesianSerialize(seed)
+    rng = np.random.default_rng(seed)
+
+    initial_vec = cartesian.state_to_vector(initial_state)
+    bounds = cartesian.init_bounds(template)
+    low_b = np.array([b[0] for b in bounds])
+    high_b = np.array([b[1] for b in bounds])
+
+    def objective(vec):
+        return total_cost(cartesian.vector_to_state(vec, template))
+
+    # Initialize particles around the initial layout
+    particles = cartesian.init_pop(pop_size, initial_vec, bounds, num_cams)
+    velocities = np.zeros_like(particles)
+    p_best = particles.copy()
+    p_best_cost = np.array([objective(p) for p in particles])
+
+    g_best = p_best[np.argmin(p_best_cost)].copy()
+    g_best_cost = np.min(p_best_cost)
+
+    # Hyperparameters
+    w, c1, c2 = 0.5, 1.5, 1.5
+
+    for iteration in range(1, max_iter + 1):
+        for i in range(pop_size):
+            # Update velocity and position
+            r1, r2 = rng.random(len(initial_vec)), rng.random(len(initial_vec))
+            velocities[i] = (
+                w * velocities[i]
+                + c1 * r1 * (p_best[i] - particles[i])
+                + c2 * r2 * (g_best - particles[i])
+            )
+            particles[i] = np.clip(particles[i] + velocities[i], low_b, high_b)
+
+            # Evaluate
+            current_cost = objective(particles[i])
+
+            if current_cost < p_best_cost[i]:
+                p_best[i] = particles[i]
+                p_best_cost[i] = current_cost
+
+                if current_cost < g_best_cost:
+                    g_best = particles[i].copy()
+                    g_best_cost = current_cost
+
+        if on_progress is not None:
+            on_progress(
+                iteration, g_best_cost, cartesian.vector_to_state(g_best, template)
+            )
+
+        if time_limit is not None and time.monotonic() - start_time > time_limit:
+            break
+
+    return cartesian.vector_to_state(g_best, template)
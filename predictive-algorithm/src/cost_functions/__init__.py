@@ -108,10 +108,13 @@ def total_cost(state: State, verbose: bool = False):
 def total_cost_pair(
     state: State, cam_state: CameraState, face: Array4x3, verbose=False
 ):
-    angle = 0.18 * angle_cost.cost_single_cam(state, cam_state, face, verbose)
-    resolution = 0.31 * resolution_cost.cost_single_cam(state, cam_state, face, verbose)
-    occlusion = 0.37 * occlusion_cost.cost_single_cam(state, cam_state, face)
-    mounting = 0.14 * mounting_cost.cost_single_cam(state, cam_state, face)
+    w = state.weights
+    angle = w.angle * angle_cost.cost_single_cam(state, cam_state, face, verbose)
+    resolution = w.res * resolution_cost.cost_single_cam(
+        state, cam_state, face, verbose
+    )
+    occlusion = w.occ * occlusion_cost.cost_single_cam(state, cam_state, face)
+    mounting = w.mount * mounting_cost.cost_single_cam(state, cam_state, face)
     return angle + resolution + occlusion + mounting, {
         "angle": angle,
         "res": resolution,
@@ -120,6 +123,17 @@ def total_cost_pair(
     }
 
 
+def total_cost_breakdown(state: State):
+    # Sum of each weighted term over every camera/face pair
+    breakdown = {"angle": 0, "res": 0, "occ": 0, "mount": 0}
+    for cam in state.cameras:
+        for face in cam.faces:
+            _, b = total_cost_pair(state, cam, face)
+            for key in breakdown:
+                breakdown[key] += b.get(key, 0)
+    return breakdown
+
+
 def log_detailed_distribution(stats):
     print(
         f"\n{'Cam':<5} | {'Faces':<5} | {'Mounting':<10} | {'Avg Angle':<10} | {'Avg Res':<10} | {'Occl.':<8}"
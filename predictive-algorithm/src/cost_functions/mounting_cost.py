@@ -33,6 +33,10 @@ def cost_single_cam(state: State, cam_state: CameraState, _face: Array4x3):
         # that leads the camera AWAY from the floor.
         cost += 20000 + (d_val * 1000)
 
+    # 4. Under the calibrated floor, outside of the building
+    if cam_state.pos[1] < state.floor_y:
+        cost += 20000 + ((state.floor_y - cam_state.pos[1]) * 1000)
+
     return cost
 
 
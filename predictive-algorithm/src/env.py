@@ -14,6 +14,12 @@ class Settings(BaseSettings):
 
     redis_res_topic: str
 
+    redis_progress_topic: str = "optimization_progress"
+
+    redis_cancel_prefix: str = "optimization_cancel:"
+
+    redis_heartbeat_key: str = "optimizer_heartbeat"
+
     model_file_path: str
 
 
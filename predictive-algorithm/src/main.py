@@ -1,20 +1,22 @@
 import asyncio
-import json
 import math
 from os import path
+import threading
 import time
 from typing import Any, Dict, List, Tuple
 import uuid
-from pydantic import BaseModel, ValidationError
+from google.protobuf.message import DecodeError
 import redis.asyncio as redis
+import redis as redis_sync
 from scipy.spatial.distance import cdist
-from cost_functions import total_cost
-from google.protobuf.json_format import MessageToJson
+from cost_functions import total_cost, total_cost_breakdown
 import messages.protobufs.camera_pb2 as cam_pb
 import messages.protobufs.optimization_pb2 as opt_pb
+import messages.protobufs.optimization_job_pb2 as opt_job_pb
 from algorithms.differential_evolution import optimize_de
+from algorithms.particle_swarm_opt import optimize_pso
 import numpy as np
-from state import CameraConfiguration, CameraState, State
+from state import CameraConfiguration, CameraState, CostWeights, State
 import quaternion
 from utils import (
     center_of_face,
@@ -32,21 +34,8 @@ logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
 
-# TODO: Use protobufs(?)
-class ReqCameraConfiguration(BaseModel):
-    pixels: Tuple[float, float]
-    vfov: float
-    name: str
-    amount: int
-
-
-class OptimizeRequest(BaseModel):
-    faces: List[List[Tuple[float, float, float]]]
-    cam_configs: List[ReqCameraConfiguration]
-    scale: float
-    job_id: str
-    model_id: str
-    project_id: str
+# Must match the schema version of the backend
+SCHEMA_VERSION = 1
 
 
 def create_arbitrary_face(center, width, height, normal):
@@ -80,7 +69,7 @@ def create_arbitrary_face(center, width, height, normal):
 
 def assign_faces(state: State, seed: int):
     num_faces = len(state.faces)
-    num_cameras = len(state.cameras)
+    num_cameras = len([cam for cam in state.cameras if not cam.fixed])
     if num_cameras == 0 or num_faces == 0:
         return
 
@@ -90,11 +79,27 @@ def assign_faces(state: State, seed: int):
     rng = np.random.default_rng(seed)
 
     # 1. K-Means++ Seed Initialization
-    seeds_idx = [rng.integers(0, num_faces)]
-    for _ in range(1, num_cameras):
-        dist_sq = np.min(cdist(face_centers, face_centers[seeds_idx]), axis=1) ** 2
-        probs = dist_sq / dist_sq.sum()
-        seeds_idx.append(rng.choice(num_faces, p=probs))
+    # Fixed cameras are seeded at the face closest to them
+    seeds_idx = [None] * num_cameras
+    for c_idx, cam in enumerate(state.cameras):
+        if cam.fixed:
+            dists = np.linalg.norm(face_centers - np.asarray(cam.pos), axis=1)
+            seeds_idx[c_idx] = int(np.argmin(dists))
+
+    chosen = [idx for idx in seeds_idx if idx is not None]
+    for c_idx, cam in enumerate(state.cameras):
+        if cam.fixed:
+            continue
+        if not chosen:
+            idx = rng.integers(0, num_faces)
+        else:
+            dist_sq = np.min(cdist(face_centers, face_centers[chosen]), axis=1) ** 2
+            if dist_sq.sum() == 0:
+                idx = rng.integers(0, num_faces)
+            else:
+                idx = rng.choice(num_faces, p=dist_sq / dist_sq.sum())
+        seeds_idx[c_idx] = idx
+        chosen.append(idx)
 
     seed_centers = face_centers[seeds_idx]
     seed_normals = face_normals[seeds_idx]
@@ -155,7 +160,8 @@ def assign_faces(state: State, seed: int):
             cam.center_of_faces = np.mean(
                 [center_of_face(f) for f in assignments[i]], axis=0
             )
-            cam.angle = look_at_quaternion(cam.center_of_faces - cam.pos)
+            if not cam.fixed:
+                cam.angle = look_at_quaternion(cam.center_of_faces - cam.pos)
 
     state.face_to_cam = face_to_cam_map
 
@@ -227,19 +233,22 @@ def assign_faces(state: State, seed: int):
 # )
 
 
-def transform_faces(faces: List[List[Tuple[float, float, float]]]) -> Array4x3:
-    return [np.array(face, dtype=np.float64) for face in faces]
+def transform_faces(faces) -> Array4x3:
+    return [
+        np.array([[p.x, p.y, p.z] for p in face.points], dtype=np.float64)
+        for face in faces
+    ]
 
 
-def transform_cameras(raw_cam_configs: List[ReqCameraConfiguration]):
+def transform_cameras(raw_cam_configs):
     cameras = []
     for raw_cam_config in raw_cam_configs:
         cam_config = CameraConfiguration(
-            pixels=raw_cam_config.pixels,
-            vfov=raw_cam_config.vfov,
+            pixels=(raw_cam_config.width_res, raw_cam_config.height_res),
+            vfov=raw_cam_config.fov,
             name=raw_cam_config.name,
         )
-        for _ in range(raw_cam_config.amount):
+        for _ in range(max(raw_cam_config.amount, 1)):
             cameras.append(
                 CameraState(
                     faces=None,
@@ -254,7 +263,49 @@ def transform_cameras(raw_cam_configs: List[ReqCameraConfiguration]):
     return cameras
 
 
-def optimize(req: OptimizeRequest, seed: int = 2000) -> State:
+def transform_fixed_cameras(raw_cameras):
+    cameras = []
+    for raw_cam in raw_cameras:
+        cameras.append(
+            CameraState(
+                faces=None,
+                pos=np.array(
+                    [raw_cam.pos_x, raw_cam.pos_y, raw_cam.pos_z], dtype=np.float64
+                ),
+                angle=quaternion.quaternion(
+                    raw_cam.angle_w, raw_cam.angle_x, raw_cam.angle_y, raw_cam.angle_z
+                ),
+                center_of_faces=None,
+                camera_config=CameraConfiguration(
+                    pixels=(raw_cam.width_res, raw_cam.height_res),
+                    vfov=raw_cam.fov,
+                    name=raw_cam.name,
+                ),
+                name=raw_cam.id,
+                fixed=True,
+            )
+        )
+
+    return cameras
+
+
+def model_path(req: opt_job_pb.OptimizeJob) -> str:
+    if req.HasField("mesh") and req.mesh.glb_path:
+        glb_path = path.normpath(req.mesh.glb_path)
+        if path.isabs(glb_path) or glb_path.startswith(".."):
+            raise ValueError(f"Invalid model path {req.mesh.glb_path}")
+        return path.join(env_settings.model_file_path, glb_path)
+
+    # Jobs sent before the mesh was part of the job
+    return path.join(
+        env_settings.model_file_path,
+        "3d_models",
+        req.project_id,
+        req.model_id + ".glb",
+    )
+
+
+def optimize(req: opt_job_pb.OptimizeJob, on_progress=None) -> State:
     pl = None
     if env_settings.dev_mode:
         from pyvistaqt import BackgroundPlotter
@@ -262,14 +313,7 @@ def optimize(req: OptimizeRequest, seed: int = 2000) -> State:
         pl = BackgroundPlotter()
 
     gltf = (
-        pv.read(
-            path.join(
-                env_settings.model_file_path,
-                "3d_models",
-                req.project_id,
-                req.model_id + ".glb",
-            )
-        )
+        pv.read(model_path(req))
         .combine()
         .extract_surface()
         .triangulate()
@@ -280,20 +324,46 @@ def optimize(req: OptimizeRequest, seed: int = 2000) -> State:
     gltf_locator.SetDataSet(gltf)
     gltf_locator.BuildLocator()
 
-    faces = transform_faces(req.faces)
-    cameras = transform_cameras(req.cam_configs)
+    params = req.params
+    seed = params.seed
+
+    weights = CostWeights()
+    if params.HasField("cost_weights"):
+        weights = CostWeights(
+            angle=params.cost_weights.angle,
+            res=params.cost_weights.resolution,
+            occ=params.cost_weights.occlusion,
+            mount=params.cost_weights.mounting,
+        )
+
+    time_limit = params.time_limit_seconds or None
+
+    faces = transform_faces(req.coverage_faces)
+    cameras = transform_fixed_cameras(req.fixed_cameras) + transform_cameras(
+        req.camera_configs
+    )
+    # The calibration of the workspace wins over the scale sent by the client
+    scale = req.scale
+    floor_y = -math.inf
+    if req.HasField("mesh"):
+        if req.mesh.scale_factor > 0:
+            scale = req.mesh.scale_factor
+        floor_y = -req.mesh.model_height
+
     state = State(
         faces=faces,
         face_to_cam=dict(),
         face_centers=list(map(center_of_face, faces)),
         cameras=cameras,
-        scale=req.scale,
+        scale=scale,
         gltf=gltf,
         gltf_locator=gltf_locator,
+        weights=weights,
+        floor_y=floor_y,
     )
 
     num_faces = len(state.faces)
-    num_cameras = len(state.cameras)
+    num_cameras = len([cam for cam in state.cameras if not cam.fixed])
 
     if num_cameras > num_faces:
         return
@@ -306,7 +376,7 @@ def optimize(req: OptimizeRequest, seed: int = 2000) -> State:
         pl.show()
 
     start_time = time.perf_counter()
-    # from cost_functions import total_cost
+    # from cost_functions import total_cost, total_cost_breakdown
     # print(total_cost(state))
     # breakpoint()
 
@@ -315,7 +385,22 @@ def optimize(req: OptimizeRequest, seed: int = 2000) -> State:
     #     # pl,
     #     None,
     # )
-    final_state, _res = optimize_de(state, seed)
+    if params.algorithm == opt_pb.OPTIMIZATION_ALGORITHM_PARTICLE_SWARM:
+        final_state = optimize_pso(
+            state,
+            seed,
+            on_progress=on_progress,
+            max_iter=params.max_iterations,
+            time_limit=time_limit,
+        )
+    else:
+        final_state, _res = optimize_de(
+            state,
+            seed,
+            on_progress=on_progress,
+            max_iter=params.max_iterations,
+            time_limit=time_limit,
+        )
 
     end_time = time.perf_counter()
     elapsed_time = end_time - start_time
@@ -361,11 +446,107 @@ def serialize_response(state: State):
     return cameras
 
 
-r = redis.Redis(
-    host=env_settings.redis_host, port=env_settings.redis_port, decode_responses=True
+# Messages are binary protobufs, so responses are not decoded
+r = redis.Redis(host=env_settings.redis_host, port=env_settings.redis_port)
+
+# The optimization runs synchronously, so progress is published with a blocking client
+progress_r = redis_sync.Redis(
+    host=env_settings.redis_host, port=env_settings.redis_port
 )
 
 
+def encode_result(resp: opt_pb.OptimizationEventResp) -> bytes:
+    return opt_job_pb.OptimizeJobResult(
+        schema_version=SCHEMA_VERSION, resp=resp
+    ).SerializeToString()
+
+
+def error_result(job_id: str, error: str) -> bytes:
+    return encode_result(
+        opt_pb.OptimizationEventResp(
+            job_id=job_id,
+            error_resp=opt_pb.ErrorOptimizationEventResp(error=error),
+        )
+    )
+
+# Minimum seconds between two progress updates of a job
+PROGRESS_INTERVAL = 1.0
+# Progress is only read live, older entries are trimmed
+PROGRESS_MAXLEN = 1000
+
+
+# The backend only sends jobs while the heartbeat key exists
+HEARTBEAT_INTERVAL = 10
+HEARTBEAT_TTL = 30
+
+
+def heartbeat():
+    # Runs in a thread, the worker loop is blocked while a job is optimized
+    while True:
+        try:
+            progress_r.set(env_settings.redis_heartbeat_key, 1, ex=HEARTBEAT_TTL)
+        except redis_sync.RedisError as e:
+            logger.warning("failed to send heartbeat: %s", e)
+        time.sleep(HEARTBEAT_INTERVAL)
+
+
+class OptimizationCancelled(Exception):
+    pass
+
+
+def is_cancelled(job_id: str) -> bool:
+    return bool(progress_r.exists(env_settings.redis_cancel_prefix + job_id))
+
+
+def progress_publisher(job_id: str):
+    last_sent = 0.0
+
+    def on_progress(iteration: int, best_cost: float, best_state: State):
+        nonlocal last_sent
+        # Checked every generation, so a cancelled job stops within one generation
+        if is_cancelled(job_id):
+            raise OptimizationCancelled()
+
+        now = time.monotonic()
+        if now - last_sent < PROGRESS_INTERVAL:
+            return
+        last_sent = now
+
+        breakdown = total_cost_breakdown(best_state)
+        progress = encode_result(
+            opt_pb.OptimizationEventResp(
+                job_id=job_id,
+                progress_resp=opt_pb.ProgressOptimizationEventResp(
+                    iteration=iteration,
+                    best_cost=best_cost,
+                    cost_breakdown=opt_pb.CostBreakdown(
+                        angle=breakdown["angle"],
+                        resolution=breakdown["res"],
+                        occlusion=breakdown["occ"],
+                        mounting=breakdown["mount"],
+                    ),
+                    cameras=[
+                        cam_state_to_proto(cam)
+                        for cam in best_state.cameras
+                        if not cam.fixed
+                    ],
+                ),
+            )
+        )
+
+        try:
+            progress_r.xadd(
+                env_settings.redis_progress_topic,
+                {"job_id": job_id, "data": progress},
+                maxlen=PROGRESS_MAXLEN,
+                approximate=True,
+            )
+        except redis_sync.RedisError as e:
+            logger.warning("failed to publish progress: %s", e)
+
+    return on_progress
+
+
 def cam_state_to_proto(cam_state: CameraState) -> cam_pb.Camera:
     # Initialize the proto message
     camera = cam_pb.Camera()
@@ -416,70 +597,85 @@ def cam_state_to_proto(cam_state: CameraState) -> cam_pb.Camera:
 
 async def worker():
     print("Work started")
+    threading.Thread(target=heartbeat, daemon=True).start()
 
     while True:
         # Read from the task stream (Blocking read)
         # 0 means wait indefinitely for a new message
-        messages: List[Tuple[str, List[Tuple[str, Dict[str, Any]]]]] = await r.xread(
-            {env_settings.redis_req_topic: "0"}, count=1, block=0
+        messages: List[Tuple[bytes, List[Tuple[bytes, Dict[bytes, Any]]]]] = (
+            await r.xread({env_settings.redis_req_topic: "0"}, count=1, block=0)
         )
 
         for _stream, msgs in messages:
             for msg_id, data in msgs:
-                print("Received message", data)
+                job_id = data.get(b"job_id", b"").decode()
+                print("Received message", job_id)
                 try:
-                    req: str = data.get("data")
+                    payload = opt_job_pb.OptimizeJob.FromString(data.get(b"data", b""))
+                    job_id = payload.job_id or job_id
+
+                    if payload.schema_version != SCHEMA_VERSION:
+                        await r.xadd(
+                            env_settings.redis_res_topic,
+                            {
+                                "job_id": job_id,
+                                "data": error_result(
+                                    job_id,
+                                    f"Unsupported schema version {payload.schema_version}",
+                                ),
+                            },
+                        )
+                        continue
 
-                    payload = OptimizeRequest.model_validate_json(req)
+                    if is_cancelled(job_id):
+                        raise OptimizationCancelled()
 
-                    result_state = optimize(payload)
+                    result_state = optimize(
+                        payload, on_progress=progress_publisher(job_id)
+                    )
 
-                    opti_res = MessageToJson(
+                    opti_res = encode_result(
                         opt_pb.OptimizationEventResp(
                             success_resp=opt_pb.SuccessOptimizationEventResp(
                                 cameras=[
                                     cam_state_to_proto(cam)
                                     for cam in result_state.cameras
+                                    if not cam.fixed
                                 ],
                             ),
-                            job_id=payload.job_id,
+                            job_id=job_id,
                         )
                     )
 
                     # Publish back to a result topic/stream
                     await r.xadd(
                         env_settings.redis_res_topic,
-                        {
-                            "job_id": payload.job_id,
-                            "status": "ok",
-                            "data": opti_res,
-                        },
+                        {"job_id": job_id, "data": opti_res},
                     )
-                except ValidationError as e:
-                    raw_data_field: str = data.get("data", "{}")
-
-                    try:
-                        parsed_inner_data: Dict[str, Any] = json.loads(raw_data_field)
-                    except (json.JSONDecodeError, TypeError):
-                        parsed_inner_data = {}
-
+                except OptimizationCancelled:
+                    print("Cancelled job", job_id)
                     await r.xadd(
                         env_settings.redis_res_topic,
                         {
-                            "job_id": parsed_inner_data.get("job_id", ""),
-                            "status": "error",
-                            "error": f"Bad request {e}",
+                            "job_id": job_id,
+                            "data": encode_result(
+                                opt_pb.OptimizationEventResp(
+                                    job_id=job_id,
+                                    cancelled_resp=opt_pb.CancelledOptimizationEventResp(),
+                                )
+                            ),
                         },
                     )
+                except DecodeError as e:
+                    await r.xadd(
+                        env_settings.redis_res_topic,
+                        {"job_id": job_id, "data": error_result(job_id, f"Bad request {e}")},
+                    )
                 except Exception as e:
                     print(e)
                     await r.xadd(
                         env_settings.redis_res_topic,
-                        {
-                            "job_id": payload.job_id,
-                            "status": "error",
-                            "error": "Internal error",
-                        },
+                        {"job_id": job_id, "data": error_result(job_id, "Internal error")},
                     )
                 finally:
                     await r.xdel(env_settings.redis_req_topic, msg_id)
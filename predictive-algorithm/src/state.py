@@ -56,11 +56,21 @@ class CameraState:
     center_of_faces: Array3 | None
     meshes: CameraMesh = field(default_factory=CameraMesh)
     camera_config: CameraConfiguration = field(default_factory=CameraConfiguration)
+    # Already installed camera, accounted for in the cost but never moved
+    fixed: bool = False
 
     def forward_vector(self) -> Array3:
         return quaternion.rotate_vectors(self.angle, np.array([1, 0, 0]))
 
 
+@dataclass
+class CostWeights:
+    angle: float = 0.18
+    res: float = 0.31
+    occ: float = 0.37
+    mount: float = 0.14
+
+
 @dataclass
 class State:
     faces: List[Array4x3]
@@ -70,3 +80,6 @@ class State:
     gltf: pv.PolyData
     gltf_locator: vtk.vtkStaticCellLocator
     scale: float  # real-life metre / virtual metre
+    weights: CostWeights = field(default_factory=CostWeights)
+    # Height of the floor from the calibration, cameras can't be mounted below it
+    floor_y: float = -math.inf